@@ -44,9 +44,16 @@ app.kubernetes.io/managed-by: {{ .Release.Service }}
 {{- end }}
 
 {{/*
-     Memberlist label query. Selects lbnodeagent pods. Used to bootstrap the memberlist/election.
+     Memberlist label query. Selects this release's lbnodeagent pods. Used to bootstrap the memberlist/election.
 */}}
-{{- define "purelb.memberlistLabels" -}}app.kubernetes.io/name={{ include "purelb.name" . }},app.kubernetes.io/component=lbnodeagent{{- end }}
+{{- define "purelb.memberlistLabels" -}}app.kubernetes.io/name={{ include "purelb.name" . }},app.kubernetes.io/instance={{ .Release.Name }},app.kubernetes.io/component=lbnodeagent{{- end }}
+
+{{/*
+Prefix for the names of cluster-scoped resources, so several releases can share a cluster.
+*/}}
+{{- define "purelb.clusterName" -}}
+{{- .Release.Name | trunc 40 | trimSuffix "-" }}
+{{- end }}
 
 {{/*
 Selector labels
@@ -15,11 +15,17 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
+	"k8s.io/apimachinery/pkg/util/wait"
+
 	"purelb.io/internal/allocator"
 	"purelb.io/internal/k8s"
 	"purelb.io/internal/logging"
@@ -29,8 +35,15 @@ func main() {
 	logger := logging.Init()
 
 	var (
-		port       = flag.Int("port", 7472, "HTTP listening port for Prometheus metrics")
-		kubeconfig = flag.String("kubeconfig", os.Getenv("KUBECONFIG"), "absolute path to the kubeconfig file (only needed when running outside of k8s)")
+		port          = flag.Int("port", 7472, "HTTP listening port for Prometheus metrics")
+		kubeconfig    = flag.String("kubeconfig", os.Getenv("KUBECONFIG"), "absolute path to the kubeconfig file (only needed when running outside of k8s)")
+		netboxPort    = flag.Int("netbox-webhook-port", 0, "HTTP listening port for Netbox webhook notifications (0 disables the receiver)")
+		admitPort     = flag.Int("admission-webhook-port", 0, "HTTPS listening port for Kubernetes admission reviews (0 disables the webhook)")
+		admitCerts    = flag.String("admission-webhook-cert-dir", "/etc/purelb/webhook", "directory that contains the admission webhook's tls.crt and tls.key")
+		leaderElect   = flag.Bool("leader-elect", false, "elect a leader so several allocator replicas can run (the others stand by)")
+		leaderNS      = flag.String("leader-elect-namespace", os.Getenv("PURELB_NAMESPACE"), "namespace of the leader election Lease")
+		podName       = flag.String("pod-name", os.Getenv("PURELB_POD_NAME"), "name of this allocator's pod, which identifies it in leader election")
+		auditInterval = flag.Duration("audit-interval", allocator.AuditInterval, "how often to check the services' addresses for conflicts")
 	)
 	flag.Parse()
 
@@ -50,6 +63,7 @@ func main() {
 
 	client, err := k8s.New(&k8s.Config{
 		ProcessName: "purelb-allocator",
+		ReadNodes:   true,
 		Logger:      logger,
 		Kubeconfig:  *kubeconfig,
 
@@ -66,8 +80,56 @@ func main() {
 
 	c.SetClient(client)
 
+	go wait.Until(c.CheckLeases, allocator.LeaseCheckInterval, stopCh)
+	go wait.Until(c.Audit, *auditInterval, stopCh)
+
 	go k8s.RunMetrics("", *port)
 
+	if *netboxPort != 0 {
+		secret := os.Getenv("NETBOX_WEBHOOK_SECRET")
+		if secret == "" {
+			logger.Log("op", "startup", "error", "NETBOX_WEBHOOK_SECRET not set", "msg", "can't check Netbox webhook signatures")
+			os.Exit(1)
+		}
+		go func() {
+			err := http.ListenAndServe(fmt.Sprintf(":%d", *netboxPort), allocator.NetboxWebhook(logger, []byte(secret), c))
+			logger.Log("op", "netboxWebhook", "error", err, "msg", "Netbox webhook receiver failed")
+		}()
+	}
+
+	if *admitPort != 0 {
+		go func() {
+			err := http.ListenAndServeTLS(fmt.Sprintf(":%d", *admitPort), filepath.Join(*admitCerts, "tls.crt"), filepath.Join(*admitCerts, "tls.key"), allocator.AdmissionWebhook(logger, c))
+			logger.Log("op", "admissionWebhook", "error", err, "msg", "admission webhook failed")
+		}()
+	}
+
+	// If we're one of several replicas then we stand by until we're
+	// elected leader
+	if *leaderElect {
+		c.Standby()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-stopCh
+			cancel()
+		}()
+		go client.RunLeaderElection(ctx, k8s.LeaderConfig{
+			Namespace: *leaderNS,
+			Name:      k8s.LeaseName(k8s.LBClass()),
+			Identity:  *podName,
+			Started:   c.Lead,
+			Stopped: func() {
+				// Our state might be stale, so the safest thing is to
+				// start over as a standby
+				if ctx.Err() == nil {
+					logger.Log("op", "leaderElection", "msg", "lost the leadership, restarting")
+					os.Exit(1)
+				}
+			},
+		})
+	}
+
 	// the k8s client doesn't return until it's time to shut down
 	if err := client.Run(stopCh); err != nil {
 		logger.Log("op", "startup", "error", err, "msg", "failed to run k8s client")
@@ -16,6 +16,8 @@
 package main
 
 import (
+	"strings"
+
 	"purelb.io/internal/election"
 	"purelb.io/internal/k8s"
 	"purelb.io/internal/lbnodeagent"
@@ -31,6 +33,18 @@ type controller struct {
 	logger     log.Logger
 	myNode     string
 	announcers []lbnodeagent.Announcer
+
+	// lbClass is the LoadBalancerClass that we handle, and isDefault
+	// indicates whether we also handle services with no class.
+	lbClass   string
+	isDefault bool
+
+	// election and memberlist are the memberlist that we're running
+	// and its configuration. labelsFlag is true if the user overrode
+	// the labels on the command line.
+	election   *election.Election
+	memberlist purelbv1.PureLBConfigMemberlistSpec
+	labelsFlag bool
 }
 
 // NewController configures a new controller. If error is non-nil then
@@ -59,6 +73,15 @@ func (c *controller) SetClient(client *k8s.Client) {
 func (c *controller) ServiceChanged(svc *v1.Service, endpoints *v1.Endpoints) k8s.SyncState {
 	nsName := svc.Namespace + "/" + svc.Name
 
+	// If the service belongs to another instance of PureLB then we
+	// leave it alone.
+	if svc.Spec.LoadBalancerClass != nil && *svc.Spec.LoadBalancerClass != c.lbClass {
+		return k8s.SyncStateSuccess
+	}
+	if !c.isDefault && svc.Spec.LoadBalancerClass == nil {
+		return k8s.SyncStateSuccess
+	}
+
 	// If the service isn't a LoadBalancer Type then we might need to
 	// clean up. It might have been a load balancer before and the user
 	// might have changed it (for example, to NodePort) to tell us to
@@ -68,10 +91,11 @@ func (c *controller) ServiceChanged(svc *v1.Service, endpoints *v1.Endpoints) k8
 		// Remove our annotations in case the user wants the service to be
 		// managed by something else
 		delete(svc.Annotations, purelbv1.BrandAnnotation)
-		delete(svc.Annotations, purelbv1.AnnounceAnnotation)
-		delete(svc.Annotations, purelbv1.AnnounceAnnotation+"-IPv4")
-		delete(svc.Annotations, purelbv1.AnnounceAnnotation+"-IPv6")
-		delete(svc.Annotations, purelbv1.AnnounceAnnotation+"-unknown")
+		for key := range svc.Annotations {
+			if strings.HasPrefix(key, purelbv1.AnnounceAnnotation) {
+				delete(svc.Annotations, key)
+			}
+		}
 
 		c.logger.Log("op", "withdraw", "reason", "notLoadBalancerType", "node", c.myNode, "service", nsName)
 		c.DeleteBalancer(nsName)
@@ -129,6 +153,10 @@ func (c *controller) DeleteBalancer(nsName string) k8s.SyncState {
 func (c *controller) SetConfig(cfg *purelbv1.Config) k8s.SyncState {
 	retval := k8s.SyncStateReprocessAll
 
+	c.lbClass = cfg.LBClass
+	c.isDefault = cfg.DefaultAnnouncer
+	c.setMemberlist(cfg.Memberlist)
+
 	for _, announcer := range c.announcers {
 		if err := announcer.SetConfig(cfg); err != nil {
 			c.logger.Log("op", "setConfig", "error", err)
@@ -139,7 +167,22 @@ func (c *controller) SetConfig(cfg *purelbv1.Config) k8s.SyncState {
 	return retval
 }
 
+// setMemberlist applies changes to the memberlist configuration. We
+// can change the labels on the fly but the memberlist reads its port
+// and key only when it starts, so we need to restart to change them.
+func (c *controller) setMemberlist(spec purelbv1.PureLBConfigMemberlistSpec) {
+	if spec.Labels != c.memberlist.Labels && !c.labelsFlag && c.election != nil {
+		c.logger.Log("op", "setConfig", "memberlist-labels", spec.Labels)
+		c.election.SetLabels(spec.Labels)
+		c.memberlist.Labels = spec.Labels
+	}
+	if spec.Port != c.memberlist.Port || spec.SecretKey != c.memberlist.SecretKey {
+		c.logger.Log("op", "setConfig", "msg", "memberlist port or secret key changed, restart the lbnodeagents to use them")
+	}
+}
+
 func (c *controller) SetElection(election *election.Election) {
+	c.election = election
 	for _, announcer := range c.announcers {
 		announcer.SetElection(election)
 	}
@@ -31,7 +31,7 @@ func main() {
 
 	var (
 		memberlistNS     = flag.String("memberlist-ns", os.Getenv("PURELB_ML_NAMESPACE"), "memberlist namespace (only needed when running outside of k8s)")
-		memberlistLabels = flag.String("memberlist-labels", os.Getenv("PURELB_ML_LABELS"), "Labels to match the lbnodeagent pods (for MemberList / fast dead node detection)")
+		memberlistLabels = flag.String("memberlist-labels", "", "Labels to match the lbnodeagent pods (for MemberList / fast dead node detection), overrides the PureLBConfig and PURELB_ML_LABELS")
 		kubeconfig       = flag.String("kubeconfig", os.Getenv("KUBECONFIG"), "absolute path to the kubeconfig file (only needed when running outside of k8s)")
 		host             = flag.String("host", os.Getenv("PURELB_HOST"), "HTTP host address for Prometheus metrics")
 		myNode           = flag.String("node-name", os.Getenv("PURELB_NODE_NAME"), "name of this Kubernetes node (spec.nodeName)")
@@ -84,13 +84,26 @@ func main() {
 
 	ctrl.SetClient(client)
 
+	// The memberlist needs its settings before the k8s client starts
+	// so we read them directly
+	globals, err := client.Globals()
+	if err != nil {
+		logger.Log("op", "startup", "error", err, "msg", "failed to read PureLBConfig")
+		os.Exit(1)
+	}
+	if *memberlistLabels != "" {
+		globals.Memberlist.Labels = *memberlistLabels
+		ctrl.labelsFlag = true
+	}
+	ctrl.memberlist = globals.Memberlist
+
 	election, err := election.New(&election.Config{
 		Namespace: *memberlistNS,
-		Labels:    *memberlistLabels,
+		Labels:    globals.Memberlist.Labels,
 		NodeName:  *myNode,
 		BindAddr:  os.Getenv("PURELB_HOST"),
-		BindPort:  7934,
-		Secret:    []byte(os.Getenv("ML_GROUP")),
+		BindPort:  globals.Memberlist.Port,
+		Secret:    []byte(globals.Memberlist.SecretKey),
 		Logger:    &logger,
 		StopCh:    stopCh,
 		Client:    client,
@@ -102,7 +115,7 @@ func main() {
 
 	ctrl.SetElection(&election)
 
-	iplist, err := client.GetPodsIPs(*memberlistNS, *memberlistLabels)
+	iplist, err := client.GetPodsIPs(*memberlistNS, globals.Memberlist.Labels)
 	if err != nil {
 		logger.Log("op", "startup", "error", err, "msg", "failed to get PodsIPs")
 		os.Exit(1)
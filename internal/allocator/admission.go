@@ -0,0 +1,372 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/vishvananda/netlink/nl"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// AdmissionWebhook returns an http.Handler that implements a
+// Kubernetes validating admission webhook. It rejects ServiceGroups
+// and LBNodeAgents that PureLB can't use, and LoadBalancer Services
+// that PureLB could never satisfy.
+func AdmissionWebhook(l log.Logger, c Controller) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		review := admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+			l.Log("op", "admissionWebhook", "error", err)
+			http.Error(w, "malformed AdmissionReview", http.StatusBadRequest)
+			return
+		}
+
+		response := &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: true}
+		if err := validate(c, review.Request); err != nil {
+			l.Log("op", "admissionWebhook", "kind", review.Request.Kind.Kind, "namespace", review.Request.Namespace, "name", review.Request.Name, "rejected", err)
+			response.Allowed = false
+			response.Result = &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: err.Error(),
+				Reason:  metav1.StatusReasonInvalid,
+				Code:    http.StatusUnprocessableEntity,
+			}
+		}
+
+		review.Request = nil
+		review.Response = response
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			l.Log("op", "admissionWebhook", "error", err)
+		}
+	})
+}
+
+// validate returns an error if the object in req is invalid.
+func validate(c Controller, req *admissionv1.AdmissionRequest) error {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return nil
+	}
+
+	switch req.Kind.Kind {
+	case "ServiceGroup":
+		group := &purelbv1.ServiceGroup{}
+		if err := json.Unmarshal(req.Object.Raw, group); err != nil {
+			return err
+		}
+		return c.ValidateServiceGroup(group)
+	case "LBNodeAgent":
+		agent := &purelbv1.LBNodeAgent{}
+		if err := json.Unmarshal(req.Object.Raw, agent); err != nil {
+			return err
+		}
+		return validateLBNodeAgent(agent)
+	case "PureLBConfig":
+		plc := &purelbv1.PureLBConfig{}
+		if err := json.Unmarshal(req.Object.Raw, plc); err != nil {
+			return err
+		}
+		return c.ValidatePureLBConfig(plc)
+	case "Service":
+		svc := &v1.Service{}
+		if err := json.Unmarshal(req.Object.Raw, svc); err != nil {
+			return err
+		}
+		// Edits that don't change what the service asks of us are OK
+		// even if we couldn't satisfy the service anymore, e.g.,
+		// because its group was removed
+		if req.Operation == admissionv1.Update {
+			old := &v1.Service{}
+			if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+				return err
+			}
+			if !serviceRequestChanged(old, svc) {
+				return nil
+			}
+		}
+		return c.ValidateService(svc)
+	}
+
+	return nil
+}
+
+// ValidateServiceGroup returns an error if group is invalid, or if it
+// overlaps one of the other groups. Groups that belong to other
+// instances of PureLB are left to them.
+func (c *controller) ValidateServiceGroup(group *purelbv1.ServiceGroup) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !purelbv1.InClass(group.Spec.LBClass, c.lbClass) {
+		return nil
+	}
+
+	return c.ips.validateGroup(group)
+}
+
+// ValidateService returns an error if svc asks for something that we
+// can never give it.
+func (c *controller) ValidateService(svc *v1.Service) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Services that are being deleted don't need addresses, and we
+	// have to let our own finalizer removal through even if the
+	// service asks for something that we can't give it anymore
+	if svc.DeletionTimestamp != nil {
+		return nil
+	}
+
+	// We only care about services that we'll allocate addresses for
+	if svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+		return nil
+	}
+	if svc.Spec.LoadBalancerClass != nil && *svc.Spec.LoadBalancerClass != c.lbClass {
+		return nil
+	}
+	if !c.isDefault && svc.Spec.LoadBalancerClass == nil {
+		return nil
+	}
+
+	return c.ips.validateService(svc)
+}
+
+// validateGroup returns an error if group is invalid, or if it
+// overlaps one of the other groups.
+func (a *Allocator) validateGroup(group *purelbv1.ServiceGroup) error {
+	spec := group.Spec
+
+	types := 0
+	for _, configured := range []bool{spec.Local != nil, spec.Netbox != nil, spec.Infoblox != nil, spec.PhpIPAM != nil, spec.DHCP != nil, spec.Nodes != nil, spec.Webhook != nil} {
+		if configured {
+			types++
+		}
+	}
+	if types != 1 {
+		return fmt.Errorf("a ServiceGroup needs exactly one of local, netbox, infoblox, phpipam, dhcp, nodes, or webhook")
+	}
+
+	if spec.Lease != nil {
+		if spec.Lease.MaxDuration != nil && spec.Lease.MaxDuration.Duration < 0 {
+			return fmt.Errorf("lease.maxDuration can't be negative")
+		}
+		if spec.Lease.WarningPeriod != nil && spec.Lease.WarningPeriod.Duration < 0 {
+			return fmt.Errorf("lease.warningPeriod can't be negative")
+		}
+	}
+
+	// Remote groups are checked by their IPAM systems, but we can
+	// check local groups completely
+	if spec.Local == nil {
+		return nil
+	}
+	if spec.Local.StickyWindow != nil && spec.Local.StickyWindow.Duration < 0 {
+		return fmt.Errorf("local.stickyWindow can't be negative")
+	}
+	if err := validateAggregations(spec.Local); err != nil {
+		return err
+	}
+	pool, err := NewLocalPool(a.logger, *spec.Local)
+	if err != nil {
+		return err
+	}
+	if len(pool.staticConflicts) > 0 {
+		return fmt.Errorf("invalid staticAssignments: %s", strings.Join(pool.staticConflicts, ", "))
+	}
+	for name, other := range a.pools {
+		if name != group.Name && pool.Overlaps(other) {
+			return fmt.Errorf("pool overlaps with group %q", name)
+		}
+	}
+
+	return nil
+}
+
+// validateAggregations returns an error if any of spec's aggregations
+// are invalid.
+func validateAggregations(spec *purelbv1.ServiceGroupLocalSpec) error {
+	if spec.V4Pool != nil {
+		if err := validAggregation(spec.V4Pool.Aggregation, nl.FAMILY_V4); err != nil {
+			return fmt.Errorf("v4pool: %w", err)
+		}
+	}
+	if spec.V6Pool != nil {
+		if err := validAggregation(spec.V6Pool.Aggregation, nl.FAMILY_V6); err != nil {
+			return fmt.Errorf("v6pool: %w", err)
+		}
+	}
+	if spec.Pool != "" {
+		family := nl.FAMILY_V4
+		if ip, _, err := net.ParseCIDR(spec.Subnet); err == nil && ip.To4() == nil {
+			family = nl.FAMILY_V6
+		}
+		if err := validAggregation(spec.Aggregation, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validAggregation returns an error if aggregation isn't "default" or
+// a mask like "/24" that's valid for family.
+func validAggregation(aggregation string, family int) error {
+	if aggregation == "default" {
+		return nil
+	}
+	maxBits := 32
+	if family == nl.FAMILY_V6 {
+		maxBits = 128
+	}
+	if strings.HasPrefix(aggregation, "/") {
+		if bits, err := strconv.Atoi(aggregation[1:]); err == nil && bits >= 8 && bits <= maxBits {
+			return nil
+		}
+	}
+	return fmt.Errorf("aggregation %q must be \"default\" or a mask from /8 to /%d", aggregation, maxBits)
+}
+
+// validateService returns an error if svc asks for something that we
+// can never give it.
+func (a *Allocator) validateService(svc *v1.Service) error {
+	count, err := AddressCount(svc)
+	if err != nil {
+		return err
+	}
+
+	// If we don't have a configuration yet then we can't tell
+	if len(a.pools) == 0 {
+		return nil
+	}
+
+	poolName := svc.Annotations[purelbv1.DesiredGroupAnnotation]
+	if svc.Spec.LoadBalancerIP != "" {
+		ip := net.ParseIP(svc.Spec.LoadBalancerIP)
+		if ip == nil {
+			return fmt.Errorf("invalid spec.loadBalancerIP %q", svc.Spec.LoadBalancerIP)
+		}
+		if count > 1 {
+			return fmt.Errorf("can't allocate %d addresses when spec.loadBalancerIP is set", count)
+		}
+		ipPool := poolFor(a.pools, ip)
+		if ipPool == "" {
+			return fmt.Errorf("%q does not belong to any group", ip)
+		}
+		if poolName != "" && poolName != ipPool {
+			return fmt.Errorf("%q belongs to group %s but desired group is %s", ip, ipPool, poolName)
+		}
+		if local, isLocal := a.pools[ipPool].(LocalPool); isLocal {
+			if resv, has := local.reservations[ip.String()]; has && resv.static && !resv.allows(svc) {
+				return fmt.Errorf("%s is statically assigned to %s", ip, resv)
+			}
+		}
+		poolName = ipPool
+	}
+
+	// Blocks are never shared so a sharing key can't be honored
+	if SharingKey(svc) != "" && poolName != "" {
+		if local, isLocal := a.pools[poolName].(LocalPool); isLocal && (local.v4Prefix != 0 || local.v6Prefix != 0) {
+			return fmt.Errorf("group %s allocates address blocks, which can't be shared", poolName)
+		}
+	}
+
+	return nil
+}
+
+// serviceRequestAnnotations are the annotations with which services
+// ask for addresses.
+var serviceRequestAnnotations = []string{
+	purelbv1.DesiredGroupAnnotation,
+	purelbv1.SharingAnnotation,
+	purelbv1.AddressCountAnnotation,
+	purelbv1.LeaseDurationAnnotation,
+}
+
+// serviceRequestChanged indicates whether the update from old to svc
+// changes what the service asks PureLB for.
+func serviceRequestChanged(old *v1.Service, svc *v1.Service) bool {
+	if old.Spec.Type != svc.Spec.Type || old.Spec.LoadBalancerIP != svc.Spec.LoadBalancerIP {
+		return true
+	}
+	if (old.Spec.LoadBalancerClass == nil) != (svc.Spec.LoadBalancerClass == nil) {
+		return true
+	}
+	if old.Spec.LoadBalancerClass != nil && *old.Spec.LoadBalancerClass != *svc.Spec.LoadBalancerClass {
+		return true
+	}
+	for _, annotation := range serviceRequestAnnotations {
+		if old.Annotations[annotation] != svc.Annotations[annotation] {
+			return true
+		}
+	}
+	return false
+}
+
+// validateLBNodeAgent returns an error if agent is invalid.
+func validateLBNodeAgent(agent *purelbv1.LBNodeAgent) error {
+	local := agent.Spec.Local
+	if local == nil {
+		return fmt.Errorf("an LBNodeAgent needs a local configuration")
+	}
+	if local.LocalInterface != "" && local.LocalInterface != "default" {
+		if _, err := regexp.Compile(local.LocalInterface); err != nil {
+			return fmt.Errorf("localint %q is not a valid regular expression: %w", local.LocalInterface, err)
+		}
+	}
+	if len(local.ExtLBInterface) > 15 || strings.ContainsAny(local.ExtLBInterface, "/ \t\n") {
+		return fmt.Errorf("extlbint %q is not a valid interface name", local.ExtLBInterface)
+	}
+	return nil
+}
+
+// ValidatePureLBConfig returns an error if plc is invalid.
+// PureLBConfigs that belong to other instances of PureLB are left to
+// them.
+func (c *controller) ValidatePureLBConfig(plc *purelbv1.PureLBConfig) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !purelbv1.InClass(plc.Spec.LBClass, c.lbClass) {
+		return nil
+	}
+
+	if ml := plc.Spec.Memberlist; ml != nil {
+		if ml.Port < 0 || ml.Port > 65535 {
+			return fmt.Errorf("memberlist port %d is invalid", ml.Port)
+		}
+		switch len(ml.SecretKey) {
+		case 0, 16, 24, 32:
+		default:
+			return fmt.Errorf("memberlist secretKey must be 16, 24, or 32 bytes long")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func admit(t *testing.T, handler http.Handler, kind string, obj runtime.Object) *admissionv1.AdmissionResponse {
+	return admitUpdate(t, handler, kind, nil, obj)
+}
+
+// admitUpdate sends handler a review of the update of old to obj, or
+// of the creation of obj if old is nil.
+func admitUpdate(t *testing.T, handler http.Handler, kind string, old runtime.Object, obj runtime.Object) *admissionv1.AdmissionResponse {
+	raw, err := json.Marshal(obj)
+	assert.NoError(t, err)
+	req := &admissionv1.AdmissionRequest{
+		UID:       "test",
+		Kind:      metav1.GroupVersionKind{Kind: kind},
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+	if old != nil {
+		oldRaw, err := json.Marshal(old)
+		assert.NoError(t, err)
+		req.Operation = admissionv1.Update
+		req.OldObject = runtime.RawExtension{Raw: oldRaw}
+	}
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request:  req,
+	})
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	review := admissionv1.AdmissionReview{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&review))
+	assert.Equal(t, "test", string(review.Response.UID))
+	return review.Response
+}
+
+func localGroup(name string, spec purelbv1.ServiceGroupLocalSpec) *purelbv1.ServiceGroup {
+	return &purelbv1.ServiceGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       purelbv1.ServiceGroupSpec{Local: &spec},
+	}
+}
+
+func TestAdmissionWebhook(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t}
+	a := New(l)
+	a.client = k
+	c := &controller{logger: l, ips: a, client: k, isDefault: true}
+	handler := AdmissionWebhook(l, c)
+
+	// Services are allowed before we have a configuration
+	svc := service("test", ports("tcp/80"), "")
+	svc.Spec.Type = v1.ServiceTypeLoadBalancer
+	svc.Spec.LoadBalancerIP = "1.2.3.4"
+	assert.True(t, admit(t, handler, "Service", &svc).Allowed)
+
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{DefaultAnnouncer: true, Groups: []*purelbv1.ServiceGroup{
+		localGroup("default", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/24", Pool: "1.2.3.0/24", Aggregation: "default"}),
+		localGroup("blocks", purelbv1.ServiceGroupLocalSpec{V6Pool: &purelbv1.ServiceGroupAddressPool{Subnet: "fd53::/64", Pool: "fd53::/112", Aggregation: "default", PrefixLength: 120}}),
+	}}))
+
+	for _, tc := range []struct {
+		name  string
+		group *purelbv1.ServiceGroup
+		ok    bool
+	}{
+		{"valid", localGroup("other", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.4.0/24", Pool: "1.2.4.0/24", Aggregation: "/25"}), true},
+		{"update", localGroup("default", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/24", Pool: "1.2.3.0/25", Aggregation: "default"}), true},
+		{"overlap", localGroup("other", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/24", Pool: "1.2.3.128/25", Aggregation: "default"}), false},
+		{"outside subnet", localGroup("other", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.4.0/24", Pool: "1.2.5.0/24", Aggregation: "default"}), false},
+		{"bad aggregation", localGroup("other", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.4.0/24", Pool: "1.2.4.0/24", Aggregation: "/33"}), false},
+		{"missing aggregation", localGroup("other", purelbv1.ServiceGroupLocalSpec{V4Pool: &purelbv1.ServiceGroupAddressPool{Subnet: "1.2.4.0/24", Pool: "1.2.4.0/24"}}), false},
+		{"bad static", localGroup("other", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.4.0/24", Pool: "1.2.4.0/24", Aggregation: "default", StaticAssignments: map[string][]string{"unit/web": {"1.2.5.1"}}}), false},
+		{"no type", &purelbv1.ServiceGroup{ObjectMeta: metav1.ObjectMeta{Name: "other"}}, false},
+	} {
+		assert.Equal(t, tc.ok, admit(t, handler, "ServiceGroup", tc.group).Allowed, tc.name)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		agent purelbv1.LBNodeAgentLocalSpec
+		ok    bool
+	}{
+		{"default", purelbv1.LBNodeAgentLocalSpec{LocalInterface: "default", ExtLBInterface: "kube-lb0"}, true},
+		{"regex", purelbv1.LBNodeAgentLocalSpec{LocalInterface: "^enp.*", ExtLBInterface: "kube-lb0"}, true},
+		{"bad regex", purelbv1.LBNodeAgentLocalSpec{LocalInterface: "enp(", ExtLBInterface: "kube-lb0"}, false},
+		{"bad interface", purelbv1.LBNodeAgentLocalSpec{LocalInterface: "default", ExtLBInterface: "a-very-long-interface"}, false},
+	} {
+		agent := tc.agent
+		obj := &purelbv1.LBNodeAgent{ObjectMeta: metav1.ObjectMeta{Name: "default"}, Spec: purelbv1.LBNodeAgentSpec{Local: &agent}}
+		assert.Equal(t, tc.ok, admit(t, handler, "LBNodeAgent", obj).Allowed, tc.name)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		class      string
+		memberlist purelbv1.PureLBConfigMemberlistSpec
+		ok         bool
+	}{
+		{"valid", "", purelbv1.PureLBConfigMemberlistSpec{Port: 7946, SecretKey: "0123456789abcdef"}, true},
+		{"bad port", "", purelbv1.PureLBConfigMemberlistSpec{Port: 65536}, false},
+		{"bad key", purelbv1.ServiceLBClass, purelbv1.PureLBConfigMemberlistSpec{SecretKey: "short"}, false},
+		{"other class", "example.com/dmz", purelbv1.PureLBConfigMemberlistSpec{Port: 65536}, true},
+	} {
+		memberlist := tc.memberlist
+		obj := &purelbv1.PureLBConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}, Spec: purelbv1.PureLBConfigSpec{LBClass: tc.class, Memberlist: &memberlist}}
+		assert.Equal(t, tc.ok, admit(t, handler, "PureLBConfig", obj).Allowed, tc.name)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(*v1.Service)
+		ok     bool
+	}{
+		{"specific IP", func(svc *v1.Service) { svc.Spec.LoadBalancerIP = "1.2.3.4" }, true},
+		{"IP outside groups", func(svc *v1.Service) { svc.Spec.LoadBalancerIP = "1.2.9.4" }, false},
+		{"IP in another group", func(svc *v1.Service) {
+			svc.Spec.LoadBalancerIP = "1.2.3.4"
+			svc.Annotations[purelbv1.DesiredGroupAnnotation] = "blocks"
+		}, false},
+		{"bad IP", func(svc *v1.Service) { svc.Spec.LoadBalancerIP = "1.2.3" }, false},
+		{"bad count", func(svc *v1.Service) { svc.Annotations[purelbv1.AddressCountAnnotation] = "many" }, false},
+		{"shared block", func(svc *v1.Service) {
+			svc.Annotations[purelbv1.DesiredGroupAnnotation] = "blocks"
+			svc.Annotations[purelbv1.SharingAnnotation] = "key"
+		}, false},
+		{"deleting", func(svc *v1.Service) {
+			// e.g., when we remove our finalizer after the group was
+			// removed
+			now := metav1.Now()
+			svc.DeletionTimestamp = &now
+			svc.Finalizers = []string{purelbv1.AddressFinalizer}
+			svc.Spec.LoadBalancerIP = "9.9.9.9"
+		}, true},
+		{"not a load balancer", func(svc *v1.Service) {
+			svc.Spec.Type = v1.ServiceTypeClusterIP
+			svc.Spec.LoadBalancerIP = "1.2.9.4"
+		}, true},
+	} {
+		svc := service("test", ports("tcp/80"), "")
+		svc.Spec.Type = v1.ServiceTypeLoadBalancer
+		tc.modify(&svc)
+		assert.Equal(t, tc.ok, admit(t, handler, "Service", &svc).Allowed, tc.name)
+	}
+
+	// Updates are only checked if they change what the service asks
+	// for, so services whose address is outside the groups, e.g.,
+	// because their group was removed, can still be edited
+	old := service("test", ports("tcp/80"), "")
+	old.Spec.Type = v1.ServiceTypeLoadBalancer
+	old.Spec.LoadBalancerIP = "1.2.9.4"
+	for _, tc := range []struct {
+		name   string
+		modify func(*v1.Service)
+		ok     bool
+	}{
+		{"labels", func(svc *v1.Service) { svc.Labels = map[string]string{"app": "web"} }, true},
+		{"finalizers", func(svc *v1.Service) { svc.Finalizers = []string{purelbv1.AddressFinalizer} }, true},
+		{"other annotation", func(svc *v1.Service) { svc.Annotations["example.com/owner"] = "me" }, true},
+		{"ports", func(svc *v1.Service) { svc.Spec.Ports = ports("tcp/443") }, true},
+		{"loadBalancerIP", func(svc *v1.Service) { svc.Spec.LoadBalancerIP = "1.2.9.5" }, false},
+		{"desired group", func(svc *v1.Service) { svc.Annotations[purelbv1.DesiredGroupAnnotation] = "default" }, false},
+		{"class", func(svc *v1.Service) {
+			class := purelbv1.ServiceLBClass
+			svc.Spec.LoadBalancerClass = &class
+		}, false},
+	} {
+		svc := old.DeepCopy()
+		tc.modify(svc)
+		assert.Equal(t, tc.ok, admitUpdate(t, handler, "Service", &old, svc).Allowed, tc.name)
+	}
+}
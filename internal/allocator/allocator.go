@@ -15,8 +15,10 @@
 package allocator
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	v1 "k8s.io/api/core/v1"
@@ -34,6 +36,34 @@ type Allocator struct {
 	client k8s.ServiceEvent
 	logger log.Logger
 	pools  map[string]Pool
+
+	// groups contains the ServiceGroups from which the pools were
+	// parsed. The key is the group/pool name.
+	groups map[string]*purelbv1.ServiceGroup
+
+	// quotas limit the number of addresses that each namespace can
+	// hold.
+	quotas []*purelbv1.PureLBQuota
+
+	// holdings tracks the addresses that each service holds so we can
+	// enforce the quotas. The key is the service's namespaced name.
+	holdings map[string]holding
+
+	// quotaStatus is the status that we last wrote to each quota. The
+	// key is the quota's namespaced name.
+	quotaStatus map[string]purelbv1.PureLBQuotaStatus
+
+	// groupStatus is the status that we last wrote to each group. The
+	// key is the group name.
+	groupStatus map[string]purelbv1.ServiceGroupStatus
+
+	// sticky contains the addresses that are kept for deleted
+	// services. The key is the service's namespaced name.
+	sticky map[string]stickyAddresses
+
+	// defaultGroup is the pool from which services that don't ask for
+	// one get their addresses.
+	defaultGroup string
 }
 
 // New returns an Allocator managing no pools.
@@ -41,6 +71,14 @@ func New(log log.Logger) *Allocator {
 	return &Allocator{
 		logger: log,
 		pools:  map[string]Pool{},
+		groups: map[string]*purelbv1.ServiceGroup{},
+
+		holdings:    map[string]holding{},
+		quotaStatus: map[string]purelbv1.PureLBQuotaStatus{},
+		groupStatus: map[string]purelbv1.ServiceGroupStatus{},
+		sticky:      map[string]stickyAddresses{},
+
+		defaultGroup: defaultPoolName,
 	}
 }
 
@@ -58,19 +96,37 @@ func (a *Allocator) SetPools(groups []*purelbv1.ServiceGroup) error {
 		return fmt.Errorf("No valid pools found")
 	}
 
-	for n := range a.pools {
+	for n, p := range a.pools {
+		// Stop any pollers that belong to the old pools. The new pools
+		// will start their own.
+		if poller, ok := p.(Poller); ok {
+			poller.Stop()
+		}
 		if pools[n] == nil {
 			poolCapacity.DeleteLabelValues(n)
 			poolActive.DeleteLabelValues(n)
+			poolPollAge.DeleteLabelValues(n)
 		}
 	}
 
 	a.pools = pools
+	a.groups = map[string]*purelbv1.ServiceGroup{}
+	a.groupStatus = map[string]purelbv1.ServiceGroupStatus{}
+	for _, group := range groups {
+		// If there are duplicates then parseGroups used the first one
+		if pools[group.Name] != nil && a.groups[group.Name] == nil {
+			a.groups[group.Name] = group
+			a.groupStatus[group.Name] = group.Status
+		}
+	}
 
 	// Refresh or initiate stats
 	for n, p := range a.pools {
 		poolCapacity.WithLabelValues(n).Set(float64(p.Size()))
 		poolActive.WithLabelValues(n).Set(float64(p.InUse()))
+		if poller, ok := p.(Poller); ok {
+			poller.Start(n)
+		}
 	}
 
 	return nil
@@ -102,16 +158,32 @@ func (a *Allocator) NotifyExisting(svc *v1.Service) error {
 		if err := pool.Notify(svc); err != nil {
 			return err
 		}
+		a.hold(svc, poolName)
+		a.unstick(svc)
+		a.reportQuotas()
+		a.reportGroups()
 		return a.updateStats(svc, poolName)
 	}
 }
 
+// SetDefaultGroup sets the pool from which services that don't ask
+// for one get their addresses. If name is empty then we use
+// "default".
+func (a *Allocator) SetDefaultGroup(name string) {
+	if name == "" {
+		name = defaultPoolName
+	}
+	a.defaultGroup = name
+}
+
 // AllocateAnyIP allocates an IP address for svc based on svc's
 // annotations and current configuration. If the user asks for a
 // specific IP then we'll attempt to use that, and if not we'll use
+// svc's static assignment if a group has one. Otherwise we'll use
 // the pool specified in the purelbv1.DesiredGroupAnnotation
-// annotation. If neither is specified then we will attempt to
-// allocate from a pool named "default", if it exists.
+// annotation. If none of those are specified then we will attempt to
+// allocate from the default pool, if it exists. The default pool is
+// "default" unless the PureLBConfig says otherwise.
 func (a *Allocator) AllocateAnyIP(svc *v1.Service) (string, error) {
 	var (
 		poolName string
@@ -123,13 +195,28 @@ func (a *Allocator) AllocateAnyIP(svc *v1.Service) (string, error) {
 		if poolName, err = a.allocateSpecificIP(svc); err != nil {
 			return "", err
 		}
+	} else if poolName = a.staticPool(svc); poolName != "" {
+		// A group has addresses set aside for this service
+		if err = a.checkQuotas(svc, poolName, 1); err != nil {
+			return "", err
+		}
+		if err = a.pools[poolName].(LocalPool).AssignStatic(svc); err != nil {
+			a.Unassign(namespacedName(svc))
+			svc.Status.LoadBalancer.Ingress = nil
+			delete(svc.Annotations, purelbv1.PrefixAnnotation)
+			return "", err
+		}
 	} else {
 		// The user didn't ask for a specific IP so we can allocate one
 		// ourselves
 
-		// If no desiredGroup was specified, then try "default"
-		if poolName = svc.Annotations[purelbv1.DesiredGroupAnnotation]; poolName == "" {
-			poolName = defaultPoolName
+		poolName = a.dynamicPool(svc)
+
+		// Don't ask the pool for addresses if the namespace has no room
+		// for them. Remote pools would have to allocate and then release
+		// them.
+		if err = a.checkQuotas(svc, poolName, 1); err != nil {
+			return "", err
 		}
 
 		// Otherwise, allocate from the pool that the user specified
@@ -138,6 +225,25 @@ func (a *Allocator) AllocateAnyIP(svc *v1.Service) (string, error) {
 		}
 	}
 
+	// Services can get more than one address, e.g., from local pools
+	// or nodes pools, so back out the allocation if it takes the
+	// service's namespace over its quota
+	if err = a.checkQuotas(svc, poolName, len(svc.Status.LoadBalancer.Ingress)); err != nil {
+		if unassignErr := a.Unassign(namespacedName(svc)); unassignErr != nil {
+			// The pool still holds the addresses so count them until
+			// the next attempt releases them
+			a.hold(svc, poolName)
+			err = fmt.Errorf("%s, and %s", err, unassignErr)
+		}
+		svc.Status.LoadBalancer.Ingress = nil
+		delete(svc.Annotations, purelbv1.PrefixAnnotation)
+		return "", err
+	}
+	a.hold(svc, poolName)
+	a.unstick(svc)
+	a.reportQuotas()
+	a.reportGroups()
+
 	if err = a.updateStats(svc, poolName); err != nil {
 		return "", err
 	}
@@ -145,6 +251,36 @@ func (a *Allocator) AllocateAnyIP(svc *v1.Service) (string, error) {
 	return poolName, nil
 }
 
+// dynamicPool returns the name of the pool from which svc gets its
+// address if it didn't ask for a specific address and no group has a
+// static address for it. If svc didn't ask for a group then that's the
+// pool that's keeping its old address, or the default pool.
+func (a *Allocator) dynamicPool(svc *v1.Service) string {
+	if poolName := svc.Annotations[purelbv1.DesiredGroupAnnotation]; poolName != "" {
+		return poolName
+	}
+	if sticky, has := a.sticky[namespacedName(svc)]; has && time.Now().Before(sticky.expires) {
+		return sticky.pool
+	}
+	return a.defaultGroup
+}
+
+// requestedPool returns the name of the pool from which
+// AllocateAnyIP will try to allocate svc's addresses, or "" if it
+// can't tell.
+func (a *Allocator) requestedPool(svc *v1.Service) string {
+	if svc.Spec.LoadBalancerIP != "" {
+		if ip := net.ParseIP(svc.Spec.LoadBalancerIP); ip != nil {
+			return poolFor(a.pools, ip)
+		}
+		return ""
+	}
+	if poolName := a.staticPool(svc); poolName != "" {
+		return poolName
+	}
+	return a.dynamicPool(svc)
+}
+
 // allocateSpecificIP assigns the requested ip to svc, if the assignment is
 // permissible by sharingKey.
 func (a *Allocator) allocateSpecificIP(svc *v1.Service) (string, error) {
@@ -152,6 +288,11 @@ func (a *Allocator) allocateSpecificIP(svc *v1.Service) (string, error) {
 	if ip == nil {
 		return "", fmt.Errorf("invalid spec.loadBalancerIP %q", svc.Spec.LoadBalancerIP)
 	}
+	if count, err := AddressCount(svc); err != nil {
+		return "", err
+	} else if count > 1 {
+		return "", fmt.Errorf("can't allocate %d addresses when spec.loadBalancerIP is set", count)
+	}
 
 	// Check that the address belongs to a pool
 	pool := poolFor(a.pools, ip)
@@ -165,6 +306,13 @@ func (a *Allocator) allocateSpecificIP(svc *v1.Service) (string, error) {
 		return "", fmt.Errorf("%q belongs to group %s but desired group is %s", ip, pool, desiredGroup)
 	}
 
+	if err := a.authorize(svc, pool); err != nil {
+		return "", err
+	}
+	if err := a.checkQuotas(svc, pool, 1); err != nil {
+		return "", err
+	}
+
 	// If the service had an IP before, release it
 	if err := a.Unassign(namespacedName(svc)); err != nil {
 		return "", err
@@ -188,6 +336,19 @@ func (a *Allocator) allocateFromPool(svc *v1.Service, poolName string) error {
 		return fmt.Errorf("unknown pool %q", poolName)
 	}
 
+	if err := a.authorize(svc, poolName); err != nil {
+		return err
+	}
+
+	// Only local pools can allocate more than one address per family
+	count, err := AddressCount(svc)
+	if err != nil {
+		return err
+	}
+	if _, isLocal := pool.(LocalPool); count > 1 && !isLocal {
+		return fmt.Errorf("pool %q can't allocate %d addresses per family, only local pools can", poolName, count)
+	}
+
 	// If the service had an IP before, release it
 	if err := a.Unassign(namespacedName(svc)); err != nil {
 		return err
@@ -201,18 +362,71 @@ func (a *Allocator) allocateFromPool(svc *v1.Service, poolName string) error {
 	return nil
 }
 
-// Unassign frees the IP associated with service, if any.
-func (a *Allocator) Unassign(svc string) error {
-	var err error
+// authorize checks whether svc's namespace is allowed to use the
+// group named poolName. It returns nil if the group doesn't require
+// authorization or if the namespace is allowed.
+func (a *Allocator) authorize(svc *v1.Service, poolName string) error {
+	group := a.groups[poolName]
+	if group == nil || !group.Spec.RequireAuthorization {
+		return nil
+	}
 
+	allowed, err := a.client.CanUseGroup(svc.Namespace, poolName)
+	if err != nil {
+		return fmt.Errorf("can't check whether namespace %s can use group %s: %w", svc.Namespace, poolName, err)
+	}
+	if !allowed {
+		return fmt.Errorf("namespace %s is not allowed to use group %s", svc.Namespace, poolName)
+	}
+	return nil
+}
+
+// SharingBlocked returns an explanation if the sharing policy of the
+// pool named poolName kept svc from sharing an address with services
+// that have the same sharing key, or "" if it didn't.
+func (a *Allocator) SharingBlocked(svc *v1.Service, poolName string) string {
+	if pool, isLocal := a.pools[poolName].(LocalPool); isLocal {
+		return pool.SharingBlocked(svc)
+	}
+	return ""
+}
+
+// staticPool returns the name of the pool that has static addresses
+// for svc, or "" if none.
+func (a *Allocator) staticPool(svc *v1.Service) string {
+	for pname, p := range a.pools {
+		if local, ok := p.(LocalPool); ok && len(local.StaticAddresses(svc)) > 0 {
+			return pname
+		}
+	}
+	return ""
+}
+
+// Unassign frees the IP associated with service, if any. If a pool
+// couldn't release the addresses, e.g., because its IPAM system is
+// down, then the error will be non-nil and the service will still
+// hold them.
+func (a *Allocator) Unassign(svc string) error {
 	// tell the pools that the address has been released. there might
 	// not be a pool, e.g., in the case of a config change that moves
 	// addresses from one pool to another
 	for pname, p := range a.pools {
-		if err = p.Release(svc); err == nil {
-			// This pool released the address
-			poolActive.WithLabelValues(pname).Set(float64(p.InUse()))
+		err := p.Release(svc)
+		if errors.Is(err, errUnknownService) {
+			continue
 		}
+		if err != nil {
+			// The pool still holds the address so the caller can retry
+			return fmt.Errorf("pool %s didn't release %s's addresses: %w", pname, svc, err)
+		}
+		// This pool released the address
+		poolActive.WithLabelValues(pname).Set(float64(p.InUse()))
+	}
+
+	if _, held := a.holdings[svc]; held {
+		delete(a.holdings, svc)
+		a.reportQuotas()
+		a.reportGroups()
 	}
 
 	return nil
@@ -238,7 +452,7 @@ func (a *Allocator) parseGroups(groups []*purelbv1.ServiceGroup) map[string]Pool
 
 Group:
 	for _, group := range groups {
-		pool, err := parsePool(a.logger, group.Name, group.Spec)
+		pool, err := parsePool(a.logger, a.client, group.Name, group.Spec)
 		if err != nil {
 			a.client.Errorf(group, "ParseFailed", "Failed to parse: %s", err)
 			a.logger.Log("failure", "parsing ServiceGroup address pool", "service-group", group.Name, "message", err)
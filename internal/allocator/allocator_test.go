@@ -605,6 +605,47 @@ func TestAllocateAnyIP(t *testing.T) {
 	assert.Nil(t, err, "default pool IP allocation failed")
 	assert.Equal(t, defaultPoolName, pool, "IP allocated from wrong pool")
 	assert.Equal(t, "1.2.3.4", svc.Status.LoadBalancer.Ingress[0].IP, "IP wasn't assigned to service ingress")
+
+	// The PureLBConfig can choose a different default pool
+	alloc.SetDefaultGroup("test1V6")
+	svc = service("t7", ports("tcp/80"), "")
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "allocation from exhausted default pool should have failed")
+	alloc.SetDefaultGroup("")
+	svc = service("t8", ports("tcp/80"), "")
+	pool, err = alloc.AllocateAnyIP(&svc)
+	assert.Nil(t, err, "default pool IP allocation failed")
+	assert.Equal(t, defaultPoolName, pool, "IP allocated from wrong pool")
+}
+
+func TestAuthorization(t *testing.T) {
+	k := &testK8S{t: t, canUse: map[string]bool{"unit/private": true}}
+	alloc := New(allocatorTestLogger)
+	alloc.SetClient(k)
+	assert.NoError(t, alloc.SetPools([]*purelbv1.ServiceGroup{
+		serviceGroup("private", purelbv1.ServiceGroupSpec{
+			Local:                &purelbv1.ServiceGroupLocalSpec{Pool: "1.2.3.4/30", Subnet: "1.2.3.4/30"},
+			RequireAuthorization: true,
+		}),
+	}))
+
+	// The "unit" namespace is allowed to use the group
+	svc := service("t1", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "private"
+	_, err := alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err, "allowed namespace allocation failed")
+
+	// Other namespaces aren't, either from the pool or by address
+	svc = service("t2", ports("tcp/80"), "")
+	svc.Namespace = "other"
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "private"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "disallowed namespace allocation should have failed")
+	delete(svc.Annotations, purelbv1.DesiredGroupAnnotation)
+	svc.Spec.LoadBalancerIP = "1.2.3.5"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "disallowed namespace specific IP allocation should have failed")
+	assert.Empty(t, svc.Status.LoadBalancer.Ingress)
 }
 
 func TestPoolMetrics(t *testing.T) {
@@ -0,0 +1,90 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// AuditInterval is how often the controller checks the services'
+// addresses for conflicts.
+const AuditInterval = 5 * time.Minute
+
+// Audit cross-checks the addresses of all of the services that PureLB
+// has allocated against each other and against the pools. Services
+// that hold an address that they can't share, or that hold an address
+// that doesn't belong to their pool, get Warning events. If the newer
+// service's group allows it then we move it to a new address.
+func (c *controller) Audit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.synced || c.standby || c.client == nil {
+		return
+	}
+
+	svcs := heldServices(c.client.Services())
+	current := map[string]bool{}
+
+	// Addresses that their pools don't contain
+	for _, svc := range svcs {
+		poolName := svc.Annotations[purelbv1.PoolAnnotation]
+		pool := c.ips.pools[poolName]
+		if pool == nil {
+			continue
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			ip := net.ParseIP(ingress.IP)
+			if ip == nil || pool.Contains(ip) {
+				continue
+			}
+			key := fmt.Sprintf("%s %s", ingress.IP, namespacedName(svc))
+			current[key] = true
+			if !c.conflicts[key] {
+				c.logger.Log("op", "audit", "service", namespacedName(svc), "ip", ingress.IP, "error", "address not in pool", "pool", poolName)
+				c.client.Errorf(svc, "AddressConflict", "Address %s doesn't belong to group %s", ingress.IP, poolName)
+			}
+		}
+	}
+
+	// Addresses that are held by services that can't share them
+	dups := c.ips.duplicates(svcs)
+	for _, dup := range dups {
+		current[dup.key()] = true
+		if c.conflicts[dup.key()] {
+			continue
+		}
+
+		older, newer := namespacedName(dup.older), namespacedName(dup.newer)
+		c.logger.Log("op", "audit", "service", newer, "ip", dup.ip, "duplicate-of", older)
+		c.client.Errorf(dup.older, "AddressConflict", "Address %s is also held by %s", dup.ip, newer)
+		c.client.Errorf(dup.newer, "AddressConflict", "Address %s is also held by %s", dup.ip, older)
+
+		if group := c.ips.groups[dup.newer.Annotations[purelbv1.PoolAnnotation]]; group != nil && group.Spec.Local != nil && group.Spec.Local.ReallocateConflicts {
+			if c.reallocate == nil {
+				c.reallocate = map[string]string{}
+			}
+			c.reallocate[newer] = fmt.Sprintf("address %s is also held by %s", dup.ip, older)
+			c.client.ResyncService(newer)
+		}
+	}
+
+	addressConflicts.Set(float64(len(current)))
+	c.conflicts = current
+}
@@ -0,0 +1,193 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func TestAudit(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t}
+	a := New(l)
+	a.client = k
+	c := &controller{logger: l, ips: a, client: k}
+
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups: []*purelbv1.ServiceGroup{
+			localGroup("default", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/30", Pool: "1.2.3.0/30"}),
+		},
+	}))
+
+	now := time.Now()
+	k.services = []*v1.Service{
+		existingService("shared-a", ports("tcp/80"), "key", "1.2.3.1", now.Add(-3*time.Hour)),
+		existingService("shared-b", ports("tcp/443"), "key", "1.2.3.1", now.Add(-2*time.Hour)),
+		existingService("old", ports("tcp/80"), "", "1.2.3.0", now.Add(-time.Hour)),
+	}
+
+	// The audit doesn't run until the caches have synced
+	c.Audit()
+	assert.Nil(t, c.conflicts)
+
+	// Services that share properly aren't conflicts
+	c.MarkSynced()
+	c.Audit()
+	assert.False(t, k.loggedWarning, "shared address reported as a conflict")
+	assert.Equal(t, 0.0, testutil.ToFloat64(addressConflicts))
+
+	// Services that hold the same address but can't share it are
+	// reported, but not moved because the group doesn't allow it
+	k.services = append(k.services, existingService("dup", ports("tcp/8080"), "", "1.2.3.0", now))
+	c.Audit()
+	assert.True(t, k.loggedWarning, "conflict not reported")
+	assert.Equal(t, 1.0, testutil.ToFloat64(addressConflicts))
+	assert.Empty(t, k.resynced)
+
+	// Conflicts are only reported once
+	k.reset()
+	c.Audit()
+	assert.False(t, k.loggedWarning, "conflict reported twice")
+	assert.Equal(t, 1.0, testutil.ToFloat64(addressConflicts))
+
+	// Addresses that don't belong to the service's pool are reported
+	k.services = append(k.services, existingService("stray", ports("tcp/80"), "", "4.3.2.1", now))
+	c.Audit()
+	assert.True(t, k.loggedWarning, "stray address not reported")
+	assert.Equal(t, 2.0, testutil.ToFloat64(addressConflicts))
+
+	// If the group allows it then the newer service is moved
+	k.services = k.services[:4]
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups: []*purelbv1.ServiceGroup{
+			localGroup("default", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/30", Pool: "1.2.3.0/30", ReallocateConflicts: true}),
+		},
+	}))
+	c.seed() // the services are reprocessed after a config change
+	c.conflicts = nil
+	c.Audit()
+	assert.Equal(t, []string{"unit/dup"}, k.resynced)
+	assert.Contains(t, c.reallocate, "unit/dup")
+
+	dup := k.services[3]
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(dup, nil))
+	assert.Equal(t, "1.2.3.2", dup.Status.LoadBalancer.Ingress[0].IP)
+	assert.NotContains(t, c.reallocate, "unit/dup")
+
+	// Once the conflict is fixed the audit is clean
+	k.reset()
+	c.Audit()
+	assert.False(t, k.loggedWarning, "fixed conflict reported")
+	assert.Equal(t, 0.0, testutil.ToFloat64(addressConflicts))
+}
+
+func TestAuditSharing(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t}
+	a := New(l)
+	a.client = k
+	c := &controller{logger: l, ips: a, client: k}
+
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups: []*purelbv1.ServiceGroup{
+			localGroup("default", purelbv1.ServiceGroupLocalSpec{
+				Subnet:              "1.2.3.0/30",
+				Pool:                "1.2.3.0/30",
+				AutoShare:           true,
+				ReallocateConflicts: true,
+				Sharing:             &purelbv1.ServiceGroupSharingSpec{NamespaceGroups: [][]string{{"team-a", "team-b"}}},
+			}),
+		},
+	}))
+
+	inNamespace := func(svc *v1.Service, namespace string) *v1.Service {
+		svc.Namespace = namespace
+		return svc
+	}
+	now := time.Now()
+	k.services = []*v1.Service{
+		// Services without keys that the pool shared automatically,
+		// even across namespaces
+		existingService("auto-a", ports("tcp/80"), "", "1.2.3.0", now.Add(-3*time.Hour)),
+		inNamespace(existingService("auto-b", ports("tcp/443"), "", "1.2.3.0", now.Add(-2*time.Hour)), "other"),
+		// Services with the same key in namespaces that the policy lets
+		// share
+		inNamespace(existingService("team-a", ports("tcp/80"), "key", "1.2.3.1", now.Add(-3*time.Hour)), "team-a"),
+		inNamespace(existingService("team-b", ports("tcp/443"), "key", "1.2.3.1", now.Add(-2*time.Hour)), "team-b"),
+	}
+
+	// The audit doesn't report the services that share properly, or
+	// move them
+	c.MarkSynced()
+	c.Audit()
+	assert.False(t, k.loggedWarning, "shared address reported as a conflict")
+	assert.Empty(t, k.resynced)
+	assert.Equal(t, 0.0, testutil.ToFloat64(addressConflicts))
+
+	// A service with the same key in a namespace that the policy keeps
+	// separate can't share the address
+	k.services = append(k.services, inNamespace(existingService("other", ports("tcp/8080"), "key", "1.2.3.1", now), "other"))
+	c.Audit()
+	assert.True(t, k.loggedWarning, "conflict not reported")
+	assert.Equal(t, []string{"other/other"}, k.resynced)
+	assert.Equal(t, 1.0, testutil.ToFloat64(addressConflicts))
+}
+
+func TestAuditNodesPool(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t, nodes: []v1.Node{
+		node("n1", true, v1.NodeAddress{Type: v1.NodeInternalIP, Address: "10.0.0.1"}),
+	}}
+	a := New(l)
+	a.client = k
+	c := &controller{logger: l, ips: a, client: k}
+
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups: []*purelbv1.ServiceGroup{
+			serviceGroup("default", purelbv1.ServiceGroupSpec{Nodes: &purelbv1.ServiceGroupNodesSpec{}}),
+		},
+	}))
+
+	now := time.Now()
+	k.services = []*v1.Service{
+		existingService("svc1", ports("tcp/80"), "", "10.0.0.1", now.Add(-time.Hour)),
+		existingService("svc2", ports("tcp/443"), "", "10.0.0.1", now),
+	}
+
+	// Services share node addresses without sharing keys
+	c.MarkSynced()
+	c.Audit()
+	assert.False(t, k.loggedWarning, "shared node address reported as a conflict")
+	assert.Equal(t, 0.0, testutil.ToFloat64(addressConflicts))
+
+	// ...as long as their ports don't clash
+	k.services = append(k.services, existingService("svc3", ports("tcp/80"), "", "10.0.0.1", now))
+	c.Audit()
+	assert.True(t, k.loggedWarning, "clashing ports weren't reported")
+	assert.Equal(t, 1.0, testutil.ToFloat64(addressConflicts))
+}
@@ -16,9 +16,12 @@
 package allocator
 
 import (
+	"sync"
+
 	v1 "k8s.io/api/core/v1"
 
 	"purelb.io/internal/k8s"
+	"purelb.io/internal/netbox"
 	purelbv1 "purelb.io/pkg/apis/v1"
 
 	"github.com/go-kit/kit/log"
@@ -32,24 +35,63 @@ type Controller interface {
 	SetBalancer(*v1.Service, *v1.Endpoints) k8s.SyncState
 	DeleteBalancer(string) k8s.SyncState
 	MarkSynced()
+	CheckLeases()
+	Standby()
+	Lead()
+	Audit()
+	ValidateServiceGroup(*purelbv1.ServiceGroup) error
+	ValidatePureLBConfig(*purelbv1.PureLBConfig) error
+	ValidateService(*v1.Service) error
 	Shutdown()
+	NetboxAddressChanged(*netbox.WebhookEvent) error
 }
 
 type controller struct {
+	// mu serializes calls into the controller. They can come from the
+	// k8s client, the custom resource controller, and the webhook
+	// receivers, each of which runs in its own goroutine.
+	mu sync.Mutex
+
 	client    k8s.ServiceEvent
 	synced    bool
 	ips       *Allocator
 	groupURL  *string
 	logger    log.Logger
 	isDefault bool
+	lbClass   string
+
+	// reallocate contains the services that need to be moved to new
+	// addresses the next time that we see them. The key is the
+	// service's namespaced name and the value is the reason.
+	reallocate map[string]string
+
+	// leaseWarned contains the services whose owners we've warned that
+	// their leases are about to expire. The key is the service's
+	// namespaced name and the value is the expiry time that we warned
+	// about.
+	leaseWarned map[string]string
+
+	// standby is true while another allocator is the leader. The
+	// controller tracks the configuration but doesn't allocate.
+	standby bool
+
+	// conflicts contains the conflicts that the last audit found, so
+	// we only send events about new ones.
+	conflicts map[string]bool
+
+	// config is the most recent configuration, which a new leader
+	// uses to rebuild its pools.
+	config *purelbv1.Config
 }
 
 // NewController configures a new controller. If error is non-nil then
 // the controller object shouldn't be used.
 func NewController(l log.Logger, ips *Allocator) (Controller, error) {
 	con := &controller{
-		logger: l,
-		ips:    ips,
+		logger:      l,
+		ips:         ips,
+		reallocate:  map[string]string{},
+		leaseWarned: map[string]string{},
 	}
 
 	return con, nil
@@ -61,7 +103,13 @@ func (c *controller) SetClient(client *k8s.Client) {
 }
 
 func (c *controller) DeleteBalancer(name string) k8s.SyncState {
-	if err := c.ips.Unassign(name); err != nil {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.reallocate, name)
+	delete(c.leaseWarned, name)
+
+	if err := c.ips.Delete(name); err != nil {
 		c.logger.Log("event", "serviceDelete", "error", err)
 		return k8s.SyncStateError
 	}
@@ -71,6 +119,9 @@ func (c *controller) DeleteBalancer(name string) k8s.SyncState {
 }
 
 func (c *controller) SetConfig(cfg *purelbv1.Config) k8s.SyncState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	defer c.logger.Log("event", "configUpdated")
 
 	if cfg == nil {
@@ -78,20 +129,47 @@ func (c *controller) SetConfig(cfg *purelbv1.Config) k8s.SyncState {
 		return k8s.SyncStateError
 	}
 
-	if err := c.ips.SetPools(cfg.Groups); err != nil {
+	if err := c.applyConfig(cfg); err != nil {
 		c.logger.Log("op", "setConfig", "error", err)
 		return k8s.SyncStateError
 	}
+	c.config = cfg
+
+	return k8s.SyncStateReprocessAll
+}
+
+// applyConfig configures the allocator with cfg.
+func (c *controller) applyConfig(cfg *purelbv1.Config) error {
+	if err := c.ips.SetPools(cfg.Groups); err != nil {
+		return err
+	}
+	c.ips.SetQuotas(cfg.Quotas)
+	c.ips.SetReservations(cfg.Reservations)
+	c.ips.SetDefaultGroup(cfg.DefaultGroup)
 
 	// Cache the config that indicates if we are the default Service
 	// announcer.
 	c.isDefault = cfg.DefaultAnnouncer
 
-	return k8s.SyncStateReprocessAll
+	// Cache the LoadBalancerClass that we handle
+	c.lbClass = cfg.LBClass
+	if c.lbClass == "" {
+		c.lbClass = purelbv1.ServiceLBClass
+	}
+
+	return nil
 }
 
 func (c *controller) MarkSynced() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.synced = true
+	if c.standby {
+		c.logger.Log("event", "stateSynced", "msg", "controller synced, standing by")
+		return
+	}
+	c.seed()
 	c.logger.Log("event", "stateSynced", "msg", "controller synced, can allocate IPs now")
 }
 
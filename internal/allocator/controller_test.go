@@ -25,6 +25,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -48,6 +49,13 @@ func statusAssigned(ip string) v1.ServiceStatus {
 // to do to k8s.
 type testK8S struct {
 	loggedWarning bool
+	warnings      []string
+	resynced      []string
+	services      []*v1.Service
+	nodes         []v1.Node
+	canUse        map[string]bool // namespace/group -> allowed
+	quotas        map[string]purelbv1.PureLBQuotaStatus
+	groups        map[string]purelbv1.ServiceGroupStatus
 	t             *testing.T
 }
 
@@ -58,12 +66,52 @@ func (s *testK8S) Infof(_ runtime.Object, evtType string, msg string, args ...in
 func (s *testK8S) Errorf(_ runtime.Object, evtType string, msg string, args ...interface{}) {
 	s.t.Logf("k8s Warning event %q: %s", evtType, fmt.Sprintf(msg, args...))
 	s.loggedWarning = true
+	s.warnings = append(s.warnings, evtType+": "+fmt.Sprintf(msg, args...))
 }
 
 func (s *testK8S) ForceSync() {}
 
+func (s *testK8S) ResyncService(nsName string) {
+	s.resynced = append(s.resynced, nsName)
+}
+
+func (s *testK8S) Services() []*v1.Service {
+	return s.services
+}
+
+func (s *testK8S) Nodes(selector labels.Selector) ([]v1.Node, error) {
+	nodes := []v1.Node{}
+	for _, node := range s.nodes {
+		if selector.Matches(labels.Set(node.Labels)) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+func (s *testK8S) CanUseGroup(namespace string, group string) (bool, error) {
+	return s.canUse[namespace+"/"+group], nil
+}
+
+func (s *testK8S) UpdateQuotaStatus(quota *purelbv1.PureLBQuota) error {
+	if s.quotas == nil {
+		s.quotas = map[string]purelbv1.PureLBQuotaStatus{}
+	}
+	s.quotas[quota.Namespace+"/"+quota.Name] = quota.Status
+	return nil
+}
+
+func (s *testK8S) UpdateGroupStatus(group *purelbv1.ServiceGroup) error {
+	if s.groups == nil {
+		s.groups = map[string]purelbv1.ServiceGroupStatus{}
+	}
+	s.groups[group.Name] = group.Status
+	return nil
+}
+
 func (s *testK8S) reset() {
 	s.loggedWarning = false
+	s.warnings = nil
 }
 
 func TestControllerConfig(t *testing.T) {
@@ -137,6 +185,7 @@ func TestControllerConfig(t *testing.T) {
 			purelbv1.BrandAnnotation:        purelbv1.Brand,
 			purelbv1.PoolAnnotation:         defaultPoolName,
 		},
+		Finalizers: []string{purelbv1.AddressFinalizer},
 	}
 
 	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(svc, nil), "SetBalancer failed")
@@ -219,3 +268,48 @@ func TestDeleteRecyclesIP(t *testing.T) {
 	assert.NotEmpty(t, svc2.Status.LoadBalancer.Ingress, "svc2 didn't get an IP")
 	assert.Equal(t, "1.2.3.0", svc2.Status.LoadBalancer.Ingress[0].IP, "svc2 got the wrong IP")
 }
+
+func TestSharingBlockedEvents(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t}
+	a := New(l)
+	a.client = k
+	c := &controller{
+		logger: l,
+		ips:    a,
+		client: k,
+	}
+
+	cfg := &purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups: []*purelbv1.ServiceGroup{
+			localGroup(defaultPoolName, purelbv1.ServiceGroupLocalSpec{
+				Subnet:  "1.2.3.0/24",
+				Pool:    "1.2.3.0/32",
+				Sharing: &purelbv1.ServiceGroupSharingSpec{NamespaceGroups: [][]string{{"unit", "friend"}}},
+			}),
+		},
+	}
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(cfg), "SetConfig failed")
+	c.MarkSynced()
+
+	svc1 := service("svc1", ports("tcp/80"), "key")
+	svc1.Spec.Type = "LoadBalancer"
+	svc1.Spec.ClusterIP = "1.2.3.4"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc1, nil), "SetBalancer svc1 failed")
+	assert.Equal(t, "1.2.3.0", svc1.Status.LoadBalancer.Ingress[0].IP, "svc1 got the wrong IP")
+	assert.Empty(t, k.warnings)
+
+	// If the policy keeps a service from sharing the only address
+	// then the allocation failure says why
+	svc2 := service("svc2", ports("tcp/443"), "key")
+	svc2.Namespace = "stranger"
+	svc2.Spec.Type = "LoadBalancer"
+	svc2.Spec.ClusterIP = "1.2.3.5"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc2, nil), "SetBalancer svc2 failed")
+	assert.Empty(t, svc2.Status.LoadBalancer.Ingress, "svc2 shouldn't have gotten an IP")
+	assert.Equal(t, 1, len(k.warnings))
+	assert.Contains(t, k.warnings[0], "AllocationFailed")
+	assert.Contains(t, k.warnings[0], "sharing policy")
+	assert.Contains(t, k.warnings[0], "unit/svc1")
+}
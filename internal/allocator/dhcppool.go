@@ -0,0 +1,408 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"purelb.io/internal/dhcp"
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+const (
+	// dhcpRenewCheckInterval is how often we check for leases that
+	// need to be renewed.
+	dhcpRenewCheckInterval = 10 * time.Second
+
+	// dhcpRetryInterval is how long we wait to retry a failed
+	// renewal.
+	dhcpRetryInterval = 30 * time.Second
+
+	// dhcpClaimTimeout is how long a lease that we acquired can wait
+	// for its service to claim it before we give it back.
+	dhcpClaimTimeout = time.Minute
+)
+
+// newDHCPClient creates the pool's DHCP client. Tests can replace it
+// with one that returns a fake client.
+var newDHCPClient = dhcp.NewClient
+
+// DHCPPool is the IP address pool that leases one address per service
+// from the DHCP servers on a network interface.
+//
+// The lease state that needs to survive restarts lives in the
+// service: its address is in the service's status, the server's
+// address is in the DHCPServerAnnotation, and the client ID is
+// derived from the service. When we're notified of an existing
+// service we renew its lease right away since we don't know when it
+// expires.
+//
+// A DHCP exchange can take several seconds so AssignNext doesn't wait
+// for it. It starts the exchange in the background and returns
+// errAllocationPending, and when the exchange finishes the pool asks
+// the k8s client to sync the service again so the next AssignNext can
+// claim the lease.
+type DHCPPool struct {
+	logger log.Logger
+
+	k8s    k8s.ServiceEvent
+	client dhcp.Client
+	leases *dhcpLeases
+	stopCh chan struct{}
+}
+
+// dhcpLeases contains the pool's leases. The renewal goroutine and
+// the allocator both use them so they need a lock.
+type dhcpLeases struct {
+	sync.Mutex
+
+	services  map[string]*dhcpLease       // svc name -> lease
+	acquiring map[string]*dhcpAcquisition // svc name -> acquisition
+}
+
+// dhcpAcquisition is a lease that we've asked the server for but
+// that its service hasn't claimed yet.
+type dhcpAcquisition struct {
+	clientID string
+
+	// finished is when the exchange finished, or the zero value if
+	// it's still running. lease and err are valid once it's finished.
+	finished time.Time
+	lease    dhcp.Lease
+	err      error
+}
+
+// dhcpLease is one service's lease.
+type dhcpLease struct {
+	clientID string
+	hostname string
+	ip       net.IP
+	server   net.IP
+
+	// renewAt is when we should next renew the lease, and expiry is
+	// when it will expire if we don't. The zero value means
+	// "unknown".
+	renewAt time.Time
+	expiry  time.Time
+}
+
+// NewDHCPPool initializes a new instance of DHCPPool. If error is
+// non-nil then the returned DHCPPool should not be used.
+func NewDHCPPool(log log.Logger, k8s k8s.ServiceEvent, spec purelbv1.ServiceGroupDHCPSpec) (*DHCPPool, error) {
+	if spec.Interface == "" {
+		return nil, fmt.Errorf("DHCP group needs an interface")
+	}
+	client, err := newDHCPClient(spec.Interface)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DHCPPool{
+		logger: log,
+		k8s:    k8s,
+		client: client,
+		leases: &dhcpLeases{services: map[string]*dhcpLease{}, acquiring: map[string]*dhcpAcquisition{}},
+		stopCh: make(chan struct{}),
+	}, nil
+}
+
+// Notify tells the pool about a service's existing lease so we'll
+// renew it.
+func (p DHCPPool) Notify(service *v1.Service) error {
+	nsName := namespacedName(service)
+
+	if len(service.Status.LoadBalancer.Ingress) == 0 {
+		return nil
+	}
+	ipstr := service.Status.LoadBalancer.Ingress[0].IP
+	ip := net.ParseIP(ipstr)
+	if ip == nil {
+		return fmt.Errorf("Service %s has unparseable IP %s", nsName, ipstr)
+	}
+
+	p.leases.Lock()
+	defer p.leases.Unlock()
+
+	// We're notified every time the service syncs so don't reset a
+	// lease that we already know about
+	if lease, exists := p.leases.services[nsName]; exists && lease.ip.Equal(ip) {
+		return nil
+	}
+	p.leases.services[nsName] = &dhcpLease{
+		clientID: dhcpClientID(service),
+		hostname: dhcpHostname(service),
+		ip:       ip,
+		server:   net.ParseIP(service.Annotations[purelbv1.DHCPServerAnnotation]),
+	}
+
+	return nil
+}
+
+// AssignNext leases an address for service. The first call starts
+// the DHCP exchange and returns errAllocationPending, and a later call
+// claims the lease once the exchange has finished.
+func (p DHCPPool) AssignNext(service *v1.Service) error {
+	nsName := namespacedName(service)
+
+	// DHCP only leases IPV4 addresses
+	if !wantsIPV4(service) {
+		return fmt.Errorf("DHCP groups can allocate only IPV4 addresses but service %s wants %v", nsName, service.Spec.IPFamilies)
+	}
+
+	p.leases.Lock()
+	defer p.leases.Unlock()
+
+	acq, exists := p.leases.acquiring[nsName]
+	if !exists {
+		acq = &dhcpAcquisition{clientID: dhcpClientID(service)}
+		p.leases.acquiring[nsName] = acq
+		go p.acquire(nsName, dhcpHostname(service), acq)
+		return errAllocationPending
+	}
+	if acq.finished.IsZero() {
+		return errAllocationPending
+	}
+	delete(p.leases.acquiring, nsName)
+	if acq.err != nil {
+		return fmt.Errorf("no available IPs: %s", acq.err)
+	}
+
+	addIngress(p.logger, service, acq.lease.IP)
+	if service.Annotations == nil {
+		service.Annotations = map[string]string{}
+	}
+	service.Annotations[purelbv1.DHCPServerAnnotation] = acq.lease.Server.String()
+
+	p.leases.services[nsName] = &dhcpLease{
+		clientID: dhcpClientID(service),
+		hostname: dhcpHostname(service),
+		ip:       acq.lease.IP,
+		server:   acq.lease.Server,
+		renewAt:  acq.finished.Add(acq.lease.RenewAfter),
+		expiry:   acq.finished.Add(acq.lease.Duration),
+	}
+
+	return nil
+}
+
+// acquire runs a DHCP exchange for the service nsName and then asks
+// the k8s client to sync the service so it can claim the lease. It
+// doesn't hold the lock while it talks to the server.
+func (p DHCPPool) acquire(nsName string, hostname string, acq *dhcpAcquisition) {
+	lease, err := p.client.Acquire(acq.clientID, hostname)
+
+	p.leases.Lock()
+	defer p.leases.Unlock()
+	acq.lease, acq.err, acq.finished = lease, err, time.Now()
+	p.k8s.ResyncService(nsName)
+}
+
+// Assign assigns a service to an IP. We can only lease addresses that
+// the server offers us so this works only if the address is already
+// leased to the service.
+func (p DHCPPool) Assign(ip net.IP, service *v1.Service) error {
+	p.leases.Lock()
+	lease, exists := p.leases.services[namespacedName(service)]
+	p.leases.Unlock()
+	if !exists || !lease.ip.Equal(ip) {
+		return fmt.Errorf("%s is not leased to %s", ip, namespacedName(service))
+	}
+
+	addIngress(p.logger, service, ip)
+	return nil
+}
+
+// Release releases a service's lease.
+func (p DHCPPool) Release(service string) error {
+	p.leases.Lock()
+	lease, exists := p.leases.services[service]
+	p.leases.Unlock()
+
+	if !exists {
+		return fmt.Errorf("trying to release an IP from %w %s", errUnknownService, service)
+	}
+
+	// DHCP servers don't acknowledge releases so the best we can do
+	// is to make sure that we sent it.
+	if err := p.client.Release(lease.clientID, lease.ip, lease.server); err != nil {
+		p.logger.Log("op", "releaseDHCP", "service", service, "ip", lease.ip, "error", err)
+		return err
+	}
+
+	p.leases.Lock()
+	delete(p.leases.services, service)
+	p.leases.Unlock()
+
+	return nil
+}
+
+// InUse returns the count of addresses that we've leased.
+func (p DHCPPool) InUse() int {
+	p.leases.Lock()
+	defer p.leases.Unlock()
+
+	return len(p.leases.services)
+}
+
+// Size returns 0 because we can't tell how many addresses the DHCP
+// server has.
+func (p DHCPPool) Size() uint64 {
+	return 0
+}
+
+// Start starts renewing this pool's leases. It implements the Poller
+// interface.
+func (p DHCPPool) Start(name string) {
+	go wait.Until(func() { p.renewDue(name) }, dhcpRenewCheckInterval, p.stopCh)
+}
+
+// Stop stops renewing leases. It implements the Poller interface.
+func (p DHCPPool) Stop() {
+	close(p.stopCh)
+}
+
+// renewDue renews the leases that are due for renewal.
+func (p DHCPPool) renewDue(name string) {
+	now := time.Now()
+
+	// Find the leases that need renewal, but don't hold the lock while
+	// we talk to the server
+	due := map[string]dhcpLease{}
+	p.leases.Lock()
+	for nsName, lease := range p.leases.services {
+		if !now.Before(lease.renewAt) {
+			due[nsName] = *lease
+		}
+	}
+	p.leases.Unlock()
+
+	p.dropUnclaimed(name, now)
+
+	for nsName, old := range due {
+		renewed, err := p.client.Renew(old.clientID, old.hostname, old.ip)
+
+		p.leases.Lock()
+		lease, exists := p.leases.services[nsName]
+		if !exists || !lease.ip.Equal(old.ip) {
+			// The service was released while we were renewing
+			p.leases.Unlock()
+			continue
+		}
+		if err != nil {
+			p.logger.Log("op", "renewDHCP", "pool", name, "service", nsName, "ip", old.ip, "error", err)
+			if !lease.expiry.IsZero() && now.After(lease.expiry) {
+				p.logger.Log("op", "renewDHCP", "pool", name, "service", nsName, "ip", old.ip, "msg", "lease has expired")
+			}
+			lease.renewAt = now.Add(dhcpRetryInterval)
+		} else {
+			lease.server = renewed.Server
+			lease.renewAt = now.Add(renewed.RenewAfter)
+			lease.expiry = now.Add(renewed.Duration)
+		}
+		p.leases.Unlock()
+	}
+}
+
+// dropUnclaimed gives back the leases that we acquired for services
+// that never claimed them, e.g., because they were deleted while we
+// were talking to the server.
+func (p DHCPPool) dropUnclaimed(name string, now time.Time) {
+	unclaimed := map[string]dhcpAcquisition{}
+	p.leases.Lock()
+	for nsName, acq := range p.leases.acquiring {
+		if !acq.finished.IsZero() && now.Sub(acq.finished) > dhcpClaimTimeout {
+			if acq.err == nil {
+				unclaimed[nsName] = *acq
+			}
+			delete(p.leases.acquiring, nsName)
+		}
+	}
+	p.leases.Unlock()
+
+	for nsName, acq := range unclaimed {
+		p.logger.Log("op", "releaseDHCP", "pool", name, "service", nsName, "ip", acq.lease.IP, "msg", "lease was never claimed")
+		if err := p.client.Release(acq.clientID, acq.lease.IP, acq.lease.Server); err != nil {
+			p.logger.Log("op", "releaseDHCP", "pool", name, "service", nsName, "ip", acq.lease.IP, "error", err)
+		}
+	}
+}
+
+// CanShare indicates whether svc1 and svc2 can share an
+// address. This pool has no sharing rules of its own so they need the
+// same sharing key and ports that don't clash.
+func (p DHCPPool) CanShare(svc1 *v1.Service, svc2 *v1.Service) bool {
+	return canShareByKey(svc1, svc2)
+}
+
+// Overlaps indicates whether the other Pool overlaps with this one
+// (i.e., has any addresses in common).  It returns true if there are
+// any common addresses and false if there aren't. This implementation
+// always returns false since the pool is managed by a remote system.
+func (p DHCPPool) Overlaps(other Pool) bool {
+	return false
+}
+
+// Contains indicates whether the provided net.IP represents an
+// address within this Pool.  It returns true if so, false
+// otherwise. In this case the pool is owned by a remote system so
+// "address within this Pool" means that we've leased the address.
+func (p DHCPPool) Contains(ip net.IP) bool {
+	p.leases.Lock()
+	defer p.leases.Unlock()
+
+	for _, lease := range p.leases.services {
+		if lease.ip.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// dhcpClientID returns the DHCP client ID for service's lease. The
+// service's UID makes it unique even if several clusters share the
+// network.
+func dhcpClientID(service *v1.Service) string {
+	if service.UID != "" {
+		return "purelb-" + string(service.UID)
+	}
+	return "purelb-" + namespacedName(service)
+}
+
+// wantsIPV4 indicates whether service can use an IPV4 address.
+func wantsIPV4(service *v1.Service) bool {
+	if len(service.Spec.IPFamilies) == 0 {
+		return true
+	}
+	for _, family := range service.Spec.IPFamilies {
+		if family == v1.IPv4Protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// dhcpHostname returns the hostname that we send with service's
+// requests, which some servers show in their lease lists.
+func dhcpHostname(service *v1.Service) string {
+	return service.Name + "-" + service.Namespace
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Acnodal Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package allocator
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+
+	"purelb.io/internal/dhcp"
+	"purelb.io/internal/dhcp/fake"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func TestDHCPPool(t *testing.T) {
+	server := fake.NewClient("192.168.1.100", "192.168.1.101")
+	newDHCPClient = func(string) (dhcp.Client, error) { return server, nil }
+	defer func() { newDHCPClient = dhcp.NewClient }()
+
+	k := &testK8S{t: t}
+
+	_, err := NewDHCPPool(log.NewNopLogger(), k, purelbv1.ServiceGroupDHCPSpec{})
+	assert.Error(t, err, "NewDHCPPool() accepted a group with no interface")
+
+	p, err := NewDHCPPool(log.NewNopLogger(), k, purelbv1.ServiceGroupDHCPSpec{Interface: "eth0"})
+	assert.Nil(t, err, "NewDHCPPool()")
+
+	// Each service gets its own lease and remembers its server. The
+	// first AssignNext() starts the exchange and the service claims
+	// the lease when it's resynced.
+	svc1 := service("svc1", ports("tcp/80"), "")
+	svc1.UID = "8c1b6f3e-0d6a-4d8e-9a55-6f3c2a3c1d11"
+	assert.True(t, errors.Is(p.AssignNext(&svc1), errAllocationPending), "AssignNext() should be pending")
+	waitForDHCP(t, p)
+	assert.Equal(t, []string{"unit/svc1"}, k.resynced, "service wasn't resynced")
+	assert.Nil(t, p.AssignNext(&svc1), "AssignNext() failed")
+	assert.Equal(t, "192.168.1.100", svc1.Status.LoadBalancer.Ingress[0].IP)
+	assert.Equal(t, fake.Server.String(), svc1.Annotations[purelbv1.DHCPServerAnnotation])
+	assert.Equal(t, "192.168.1.100", server.Leases["purelb-8c1b6f3e-0d6a-4d8e-9a55-6f3c2a3c1d11"])
+	assert.True(t, p.Contains(net.ParseIP("192.168.1.100")), "pool should contain 192.168.1.100")
+	assert.Equal(t, 1, p.InUse())
+
+	// Leases aren't renewed until they're due
+	p.renewDue("dhcp")
+	assert.Equal(t, 0, server.Renewals[dhcpClientID(&svc1)], "lease renewed too early")
+
+	// After a restart we learn about the lease from the service and
+	// renew it right away
+	p, err = NewDHCPPool(log.NewNopLogger(), k, purelbv1.ServiceGroupDHCPSpec{Interface: "eth0"})
+	assert.Nil(t, err, "NewDHCPPool()")
+	assert.Nil(t, p.Notify(&svc1), "Notify() failed")
+	p.renewDue("dhcp")
+	assert.Equal(t, 1, server.Renewals[dhcpClientID(&svc1)], "lease wasn't renewed after restart")
+	p.renewDue("dhcp")
+	assert.Equal(t, 1, server.Renewals[dhcpClientID(&svc1)], "lease renewed too early")
+
+	// Repeated notifications don't reset the lease
+	assert.Nil(t, p.Notify(&svc1), "Notify() failed")
+	p.renewDue("dhcp")
+	assert.Equal(t, 1, server.Renewals[dhcpClientID(&svc1)], "notification reset the lease")
+
+	// Releasing gives the address back to the server
+	assert.Nil(t, p.Release(namespacedName(&svc1)), "Release() failed")
+	assert.Empty(t, server.Leases, "lease wasn't released")
+	assert.False(t, p.Contains(net.ParseIP("192.168.1.100")), "pool should not contain 192.168.1.100")
+	assert.Error(t, p.Release(namespacedName(&svc1)), "Release() of unknown service succeeded")
+
+	// Failed exchanges are reported when the service claims them
+	svc2 := service("svc2", ports("tcp/80"), "")
+	empty := fake.NewClient()
+	p.client = empty
+	assert.True(t, errors.Is(p.AssignNext(&svc2), errAllocationPending), "AssignNext() should be pending")
+	waitForDHCP(t, p)
+	assert.Error(t, p.AssignNext(&svc2), "AssignNext() succeeded with no addresses")
+	assert.Empty(t, p.leases.acquiring, "failed acquisition wasn't cleared")
+
+	// Leases that their services never claim are given back
+	p.client = server
+	assert.True(t, errors.Is(p.AssignNext(&svc2), errAllocationPending), "AssignNext() should be pending")
+	waitForDHCP(t, p)
+	assert.Equal(t, "192.168.1.100", server.Leases[dhcpClientID(&svc2)])
+	p.dropUnclaimed("dhcp", time.Now())
+	assert.NotEmpty(t, server.Leases, "lease given back too early")
+	p.dropUnclaimed("dhcp", time.Now().Add(2*dhcpClaimTimeout))
+	assert.Empty(t, server.Leases, "unclaimed lease wasn't given back")
+	assert.Empty(t, p.leases.acquiring, "unclaimed lease wasn't cleared")
+
+	// DHCP can't lease IPV6 addresses
+	svc3 := service("svc3", ports("tcp/80"), "")
+	svc3.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol}
+	err = p.AssignNext(&svc3)
+	assert.Error(t, err, "AssignNext() accepted an IPV6 service")
+	assert.False(t, errors.Is(err, errAllocationPending), "AssignNext() of an IPV6 service is pending")
+	svc3.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol}
+	assert.True(t, errors.Is(p.AssignNext(&svc3), errAllocationPending), "AssignNext() should be pending")
+	waitForDHCP(t, p)
+}
+
+// waitForDHCP waits for p's DHCP exchanges to finish.
+func waitForDHCP(t *testing.T, p *DHCPPool) {
+	assert.Eventually(t, func() bool {
+		p.leases.Lock()
+		defer p.leases.Unlock()
+		for _, acq := range p.leases.acquiring {
+			if acq.finished.IsZero() {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"github.com/go-kit/kit/log"
+	v1 "k8s.io/api/core/v1"
+
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// hasFinalizer indicates whether svc has our address finalizer.
+func hasFinalizer(svc *v1.Service) bool {
+	for _, finalizer := range svc.Finalizers {
+		if finalizer == purelbv1.AddressFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// addFinalizer adds our address finalizer to svc so it can't be
+// deleted until we've released its addresses.
+func addFinalizer(svc *v1.Service) {
+	if !hasFinalizer(svc) {
+		svc.Finalizers = append(svc.Finalizers, purelbv1.AddressFinalizer)
+	}
+}
+
+// removeFinalizer removes our address finalizer from svc.
+func removeFinalizer(svc *v1.Service) {
+	finalizers := []string{}
+	for _, finalizer := range svc.Finalizers {
+		if finalizer != purelbv1.AddressFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	if len(finalizers) == 0 {
+		finalizers = nil
+	}
+	svc.Finalizers = finalizers
+}
+
+// releaseDeleted releases the addresses of svc, which is being
+// deleted. Once its pool has confirmed the release we remove our
+// finalizer so Kubernetes can finish deleting svc. If the release
+// fails then we keep the finalizer and try again later.
+func (c *controller) releaseDeleted(l log.Logger, svc *v1.Service) k8s.SyncState {
+	l.Log("event", "unassign", "ingress-address", svc.Status.LoadBalancer.Ingress, "reason", "service deleted")
+	if err := c.ips.Delete(namespacedName(svc)); err != nil {
+		l.Log("event", "unassign", "error", err)
+		c.client.Errorf(svc, "ReleaseFailed", "Failed to release addresses, will retry: %s", err)
+		return k8s.SyncStateError
+	}
+	removeFinalizer(svc)
+	return k8s.SyncStateSuccess
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"purelb.io/internal/k8s"
+	"purelb.io/internal/netbox"
+	"purelb.io/internal/netbox/fake"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// flakyNetbox is a fake Netbox that can refuse to release addresses.
+type flakyNetbox struct {
+	netbox.Netbox
+	down bool
+}
+
+func (n *flakyNetbox) Release(ip string) error {
+	if n.down {
+		return fmt.Errorf("Netbox is down")
+	}
+	return n.Netbox.Release(ip)
+}
+
+func TestFinalizer(t *testing.T) {
+	k := &testK8S{t: t}
+	c := netboxController(t, k, false)
+	nb := &flakyNetbox{Netbox: fake.NewNetbox("base", "tenant", "token")}
+	nbp := c.ips.pools["netbox"].(NetboxPool)
+	nbp.netbox = nb
+	c.ips.pools["netbox"] = nbp
+
+	// Allocating an address adds the finalizer
+	svc := service("svc1", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "netbox"
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "1.2.3.4"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.Equal(t, []string{purelbv1.AddressFinalizer}, svc.Finalizers)
+	ip := net.ParseIP(svc.Status.LoadBalancer.Ingress[0].IP)
+
+	// If Netbox doesn't confirm the release then we keep the finalizer
+	// and the address, and retry
+	now := metav1.Now()
+	svc.DeletionTimestamp = &now
+	nb.down = true
+	assert.Equal(t, k8s.SyncStateError, c.SetBalancer(&svc, nil))
+	assert.True(t, k.loggedWarning, "failed release not reported")
+	assert.True(t, hasFinalizer(&svc), "finalizer removed before release")
+	assert.True(t, nbp.Contains(ip), "pool forgot the address before release")
+
+	// Once Netbox confirms the release we remove the finalizer
+	nb.down = false
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.False(t, hasFinalizer(&svc), "finalizer not removed")
+	assert.False(t, nbp.Contains(ip), "pool still has the address")
+
+	// Deleted services without the finalizer are left alone
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+
+	// Other instances' services keep their finalizers until their own
+	// instance releases their addresses
+	other := service("other", ports("tcp/80"), "")
+	class := "example.com/dmz"
+	other.Spec.LoadBalancerClass = &class
+	other.Spec.Type = "LoadBalancer"
+	other.DeletionTimestamp = &now
+	other.Finalizers = []string{purelbv1.AddressFinalizer}
+	other.Status = statusAssigned("10.9.9.9")
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&other, nil))
+	assert.True(t, hasFinalizer(&other), "another instance's finalizer was removed")
+
+	// Services that we allocated before we used finalizers get one
+	svc2 := existingService("svc2", ports("tcp/80"), "", "10.1.2.3", now.Time)
+	svc2.Annotations[purelbv1.PoolAnnotation] = "netbox"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(svc2, nil))
+	assert.True(t, hasFinalizer(svc2), "existing service didn't get the finalizer")
+
+	// Services that stop being LoadBalancers lose the finalizer once
+	// their addresses are released
+	svc2.Spec.Type = "ClusterIP"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(svc2, nil))
+	assert.False(t, hasFinalizer(svc2), "finalizer not removed")
+	assert.Empty(t, svc2.Status.LoadBalancer.Ingress)
+}
@@ -0,0 +1,361 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/vishvananda/netlink/nl"
+	v1 "k8s.io/api/core/v1"
+
+	"purelb.io/internal/infoblox"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+const (
+	infobloxFixedAddress = "fixedaddress"
+	infobloxHost         = "host"
+)
+
+// InfobloxPool is the IP address pool that requests IP addresses from
+// an Infoblox grid.
+type InfobloxPool struct {
+	logger log.Logger
+
+	infoblox   infoblox.Infoblox
+	v4Pool     string
+	v6Pool     string
+	recordType string
+	domain     string
+	extAttrs   map[string]string
+
+	// services caches the addresses that we've allocated to a specific
+	// service. It's used so we can release addresses when we're given
+	// only the service name. The key is the service's namespaced name,
+	// and the value is an array of the addresses assigned to that
+	// service.
+	services map[string][]net.IP
+
+	// refs caches the Infoblox records that we've created for each
+	// service. It's keyed by the service's namespaced name. If we
+	// learned about a service from Notify() then it won't have an
+	// entry here so we'll look its records up by address.
+	refs map[string][]string
+
+	// Map of the addresses that have been assigned.
+	addressesInUse map[string]string // ip.String() -> svc name
+}
+
+// NewInfobloxPool initializes a new instance of InfobloxPool. If
+// error is non-nil then the returned InfobloxPool should not be used.
+func NewInfobloxPool(log log.Logger, spec purelbv1.ServiceGroupInfobloxSpec) (*InfobloxPool, error) {
+	// Make sure that we've got credentials for Infoblox
+	username, ok := os.LookupEnv("INFOBLOX_USERNAME")
+	if !ok {
+		return nil, fmt.Errorf("INFOBLOX_USERNAME not set, can't connect to Infoblox")
+	}
+	password, ok := os.LookupEnv("INFOBLOX_PASSWORD")
+	if !ok {
+		return nil, fmt.Errorf("INFOBLOX_PASSWORD not set, can't connect to Infoblox")
+	}
+
+	// Validate the url from the service group
+	url, err := url.Parse(spec.URL)
+	if err != nil || url.Scheme == "" || url.Host == "" {
+		return nil, fmt.Errorf("Infoblox URL %q invalid", spec.URL)
+	}
+
+	// Validate the pools
+	if spec.V4Pool == "" && spec.V6Pool == "" {
+		return nil, fmt.Errorf("Infoblox group needs a v4pool or a v6pool")
+	}
+	for family, pool := range map[int]string{nl.FAMILY_V4: spec.V4Pool, nl.FAMILY_V6: spec.V6Pool} {
+		if pool == "" {
+			continue
+		}
+		iprange, err := NewIPRange(pool)
+		if err != nil {
+			return nil, err
+		}
+		if iprange.Family() != family {
+			return nil, fmt.Errorf("Infoblox pool %q is the wrong address family", pool)
+		}
+	}
+
+	recordType := spec.RecordType
+	if recordType == "" {
+		recordType = infobloxFixedAddress
+	}
+	if recordType != infobloxFixedAddress && recordType != infobloxHost {
+		return nil, fmt.Errorf("Infoblox recordType %q must be %q or %q", recordType, infobloxFixedAddress, infobloxHost)
+	}
+	if recordType == infobloxHost && spec.Domain == "" {
+		return nil, fmt.Errorf("Infoblox host records need a domain")
+	}
+
+	view := spec.NetworkView
+	if view == "" {
+		view = "default"
+	}
+
+	return &InfobloxPool{
+		logger:         log,
+		infoblox:       infoblox.NewInfoblox(url.String(), view, username, password),
+		v4Pool:         spec.V4Pool,
+		v6Pool:         spec.V6Pool,
+		recordType:     recordType,
+		domain:         spec.Domain,
+		extAttrs:       spec.ExtAttrs,
+		services:       map[string][]net.IP{},
+		refs:           map[string][]string{},
+		addressesInUse: map[string]string{},
+	}, nil
+}
+
+func (p InfobloxPool) Notify(service *v1.Service) error {
+	nsName := namespacedName(service)
+
+	ips := []net.IP{}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		ipstr := ingress.IP
+		ip := net.ParseIP(ipstr)
+		if ip == nil {
+			return fmt.Errorf("Service %s has unparseable IP %s", nsName, ipstr)
+		}
+		ips = append(ips, ip)
+	}
+
+	for _, ip := range ips {
+		p.addressesInUse[ip.String()] = nsName
+	}
+	p.services[nsName] = ips
+
+	return nil
+}
+
+// AssignNext asks Infoblox for addresses for service, one for each of
+// the service's IP families, and creates records that hold them.
+func (p InfobloxPool) AssignNext(service *v1.Service) error {
+	pools, err := p.familyPools(service)
+	if err != nil {
+		return err
+	}
+
+	nsName := namespacedName(service)
+	comment := "PureLB service " + nsName
+	records := []infoblox.Record{}
+
+	if p.recordType == infobloxHost {
+		// One host record can hold both families
+		v4Pool, v6Pool := "", ""
+		for _, pool := range pools {
+			if infoblox.IsV6(pool) {
+				v6Pool = pool
+			} else {
+				v4Pool = pool
+			}
+		}
+		record, err := p.infoblox.CreateHost(fmt.Sprintf("%s.%s.%s", service.Name, service.Namespace, p.domain), v4Pool, v6Pool, comment, p.extAttrs)
+		if err != nil {
+			return fmt.Errorf("no available IPs in pool: %s", err)
+		}
+		records = append(records, record)
+	} else {
+		for _, pool := range pools {
+			record, err := p.infoblox.CreateFixedAddress(pool, service.Name+"."+service.Namespace, serviceDUID(service), comment, p.extAttrs)
+			if err != nil {
+				// Don't leave half of a dual-stack allocation behind
+				p.deleteRecords(nsName, records)
+				return fmt.Errorf("no available IPs in pool: %s", err)
+			}
+			records = append(records, record)
+		}
+	}
+
+	ips := []net.IP{}
+	refs := []string{}
+	for _, record := range records {
+		refs = append(refs, record.Ref)
+		for _, addr := range record.Addresses {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				p.deleteRecords(nsName, records)
+				return fmt.Errorf("Infoblox returned unparseable IP %q", addr)
+			}
+			ips = append(ips, ip)
+		}
+	}
+
+	for _, ip := range ips {
+		addIngress(p.logger, service, ip)
+	}
+	p.refs[nsName] = refs
+
+	// Update our internal allocation data structures
+	return p.Notify(service)
+}
+
+// Assign assigns a service to an IP.
+func (p InfobloxPool) Assign(ip net.IP, service *v1.Service) error {
+	// we have an IP selected somehow, so program the data plane
+	addIngress(p.logger, service, ip)
+
+	// Update our internal allocation data structures
+	return p.Notify(service)
+}
+
+// Release releases a service's addresses and deletes the Infoblox
+// records that hold them.
+func (p InfobloxPool) Release(service string) error {
+	ips, haveIp := p.services[service]
+	if !haveIp {
+		return fmt.Errorf("trying to release an IP from %w %s", errUnknownService, service)
+	}
+
+	refs, haveRefs := p.refs[service]
+	if !haveRefs {
+		// We didn't create the records (at least not since we started)
+		// so we need to ask Infoblox for them.
+		for _, ip := range ips {
+			found, err := p.infoblox.Lookup(ip.String())
+			if err != nil {
+				p.logger.Log("op", "releaseInfoblox", "service", service, "ip", ip, "error", err)
+				return err
+			}
+			refs = append(refs, found...)
+		}
+	}
+
+	// We keep the records that we couldn't delete so we can try again.
+	records := []infoblox.Record{}
+	for _, ref := range refs {
+		records = append(records, infoblox.Record{Ref: ref})
+	}
+	if failed := p.deleteRecords(service, records); len(failed) > 0 {
+		p.refs[service] = failed
+		return fmt.Errorf("Infoblox didn't delete %d of %s's records", len(failed), service)
+	}
+
+	delete(p.refs, service)
+	delete(p.services, service)
+	for _, ip := range ips {
+		delete(p.addressesInUse, ip.String())
+	}
+
+	return nil
+}
+
+// InUse returns the count of addresses that currently have services
+// assigned.
+func (p InfobloxPool) InUse() int {
+	return len(p.addressesInUse)
+}
+
+// Size returns the total number of addresses in this pool's networks
+// or ranges. Infoblox might use some of them for other purposes.
+func (p InfobloxPool) Size() (size uint64) {
+	for _, pool := range []string{p.v4Pool, p.v6Pool} {
+		if iprange, err := NewIPRange(pool); err == nil {
+			size += iprange.Size()
+		}
+	}
+	return
+}
+
+// CanShare indicates whether svc1 and svc2 can share an
+// address. This pool has no sharing rules of its own so they need the
+// same sharing key and ports that don't clash.
+func (p InfobloxPool) CanShare(svc1 *v1.Service, svc2 *v1.Service) bool {
+	return canShareByKey(svc1, svc2)
+}
+
+// Overlaps indicates whether the other Pool overlaps with this one
+// (i.e., has any addresses in common).  It returns true if there are
+// any common addresses and false if there aren't. This implementation
+// always returns false since the pool is managed by a remote system.
+func (p InfobloxPool) Overlaps(other Pool) bool {
+	return false
+}
+
+// Contains indicates whether the provided net.IP represents an
+// address within this Pool.  It returns true if so, false
+// otherwise. In this case the pool is owned by a remote system so
+// "address within this Pool" means that the address has been
+// allocated by a previous call to AssignNext().
+func (p InfobloxPool) Contains(ip net.IP) bool {
+	_, allocated := p.addressesInUse[ip.String()]
+	return allocated
+}
+
+// familyPools returns the pools from which to allocate service's
+// addresses, in the order of the service's IP families.
+func (p InfobloxPool) familyPools(service *v1.Service) ([]string, error) {
+	if len(service.Spec.IPFamilies) == 0 {
+		// Any family is OK so use the first that we've got
+		if p.v4Pool != "" {
+			return []string{p.v4Pool}, nil
+		}
+		return []string{p.v6Pool}, nil
+	}
+
+	pools := []string{}
+	for _, family := range service.Spec.IPFamilies {
+		pool := p.v4Pool
+		if family == v1.IPv6Protocol {
+			pool = p.v6Pool
+		}
+		if pool == "" {
+			return nil, fmt.Errorf("no %s pool configured", family)
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// deleteRecords deletes records from Infoblox, logging any
+// errors. It returns the refs of the records that it couldn't delete.
+func (p InfobloxPool) deleteRecords(service string, records []infoblox.Record) []string {
+	failed := []string{}
+	for _, record := range records {
+		if err := p.infoblox.Delete(record.Ref); err != nil {
+			p.logger.Log("op", "releaseInfoblox", "service", service, "ref", record.Ref, "error", err)
+			failed = append(failed, record.Ref)
+		}
+	}
+	return failed
+}
+
+// serviceDUID returns a DHCPv6 DUID for service's IPV6 fixed address
+// record. Infoblox requires a unique DUID so we use a DUID-UUID (RFC
+// 6355) made from the service's UID.
+func serviceDUID(service *v1.Service) string {
+	uuid := strings.ReplaceAll(string(service.UID), "-", "")
+	if _, err := hex.DecodeString(uuid); err != nil || len(uuid) != 32 {
+		// No usable UID (e.g., in tests) so make one from the name
+		uuid = hex.EncodeToString([]byte(fmt.Sprintf("%-16.16s", namespacedName(service))))
+	}
+
+	duid := []string{"00", "04"}
+	for i := 0; i < len(uuid); i += 2 {
+		duid = append(duid, uuid[i:i+2])
+	}
+	return strings.Join(duid, ":")
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 Acnodal Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package allocator
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+
+	"purelb.io/internal/infoblox/fake"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func infobloxSpec(url string) purelbv1.ServiceGroupInfobloxSpec {
+	return purelbv1.ServiceGroupInfobloxSpec{
+		URL:      url,
+		V4Pool:   "10.1.2.0/30",
+		V6Pool:   "fd00::10-fd00::1f",
+		ExtAttrs: map[string]string{"Owner": "purelb"},
+	}
+}
+
+func TestInfobloxFixedAddress(t *testing.T) {
+	os.Setenv("INFOBLOX_USERNAME", "admin")
+	os.Setenv("INFOBLOX_PASSWORD", "infoblox")
+	server := fake.NewServer()
+	wapi := httptest.NewServer(server)
+	defer wapi.Close()
+
+	p, err := NewInfobloxPool(log.NewNopLogger(), infobloxSpec(wapi.URL+"/wapi/v2.11"))
+	assert.Nil(t, err, "NewInfobloxPool()")
+	assert.Equal(t, uint64(4+16), p.Size(), "pool has wrong size")
+
+	// A dual-stack service gets one record per family
+	svc1 := service("svc1", ports("tcp/80"), "")
+	svc1.Spec.IPFamilies = []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}
+	assert.Nil(t, p.AssignNext(&svc1), "dual-stack AssignNext() failed")
+	assert.Equal(t, []v1.LoadBalancerIngress{{IP: "10.1.2.1"}, {IP: "fd00::10"}}, svc1.Status.LoadBalancer.Ingress)
+	record, exists := server.Record("10.1.2.1")
+	assert.True(t, exists, "no record for 10.1.2.1")
+	assert.Equal(t, "fixedaddress", record.Type)
+	assert.Equal(t, "svc1.unit", record.Name)
+	assert.Equal(t, map[string]string{"Owner": "purelb"}, record.ExtAttrs)
+	record, exists = server.Record("fd00::10")
+	assert.True(t, exists, "no record for fd00::10")
+	assert.Equal(t, "ipv6fixedaddress", record.Type)
+	assert.Equal(t, 2, p.InUse())
+
+	// Releasing deletes the records
+	assert.Nil(t, p.Release(namespacedName(&svc1)), "Release() failed")
+	_, exists = server.Record("10.1.2.1")
+	assert.False(t, exists, "record for 10.1.2.1 wasn't deleted")
+	_, exists = server.Record("fd00::10")
+	assert.False(t, exists, "record for fd00::10 wasn't deleted")
+
+	// If we were restarted then we find the records by address
+	svc2 := service("svc2", ports("tcp/80"), "")
+	assert.Nil(t, p.AssignNext(&svc2), "AssignNext() failed")
+	assert.Equal(t, "10.1.2.1", svc2.Status.LoadBalancer.Ingress[0].IP)
+	p, err = NewInfobloxPool(log.NewNopLogger(), infobloxSpec(wapi.URL+"/wapi/v2.11"))
+	assert.Nil(t, err, "NewInfobloxPool()")
+	assert.Nil(t, p.Notify(&svc2), "Notify() failed")
+	assert.Nil(t, p.Release(namespacedName(&svc2)), "Release() failed")
+	_, exists = server.Record("10.1.2.1")
+	assert.False(t, exists, "record for 10.1.2.1 wasn't deleted")
+
+	// The v4 network has two usable addresses
+	for _, name := range []string{"svc3", "svc4"} {
+		svc := service(name, ports("tcp/80"), "")
+		assert.Nil(t, p.AssignNext(&svc), "AssignNext() failed")
+	}
+	svc5 := service("svc5", ports("tcp/80"), "")
+	assert.Error(t, p.AssignNext(&svc5), "AssignNext() from a full network succeeded")
+}
+
+func TestInfobloxHost(t *testing.T) {
+	os.Setenv("INFOBLOX_USERNAME", "admin")
+	os.Setenv("INFOBLOX_PASSWORD", "infoblox")
+	server := fake.NewServer()
+	wapi := httptest.NewServer(server)
+	defer wapi.Close()
+
+	spec := infobloxSpec(wapi.URL + "/wapi/v2.11")
+	spec.RecordType = "host"
+	_, err := NewInfobloxPool(log.NewNopLogger(), spec)
+	assert.Error(t, err, "NewInfobloxPool() accepted a host record with no domain")
+
+	spec.Domain = "lb.example.com"
+	p, err := NewInfobloxPool(log.NewNopLogger(), spec)
+	assert.Nil(t, err, "NewInfobloxPool()")
+
+	// A dual-stack service gets one host record with both addresses
+	svc1 := service("svc1", ports("tcp/80"), "")
+	svc1.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol}
+	assert.Nil(t, p.AssignNext(&svc1), "dual-stack AssignNext() failed")
+	assert.Equal(t, 2, len(svc1.Status.LoadBalancer.Ingress))
+	record, exists := server.Record("fd00::10")
+	assert.True(t, exists, "no record for fd00::10")
+	assert.Equal(t, "record:host", record.Type)
+	assert.Equal(t, "svc1.unit.lb.example.com", record.Name)
+	assert.ElementsMatch(t, []string{"10.1.2.1", "fd00::10"}, record.Addresses)
+
+	assert.Nil(t, p.Release(namespacedName(&svc1)), "Release() failed")
+	_, exists = server.Record("10.1.2.1")
+	assert.False(t, exists, "host record wasn't deleted")
+
+	// Pools have to be the right family
+	spec.V4Pool = "fd00::/120"
+	_, err = NewInfobloxPool(log.NewNopLogger(), spec)
+	assert.Error(t, err, "NewInfobloxPool() accepted an IPV6 v4pool")
+}
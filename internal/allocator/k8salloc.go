@@ -15,6 +15,9 @@
 package allocator
 
 import (
+	"fmt"
+	"strconv"
+
 	v1 "k8s.io/api/core/v1"
 
 	purelbv1 "purelb.io/pkg/apis/v1"
@@ -37,6 +40,20 @@ func SharingKey(svc *v1.Service) string {
 	return svc.Annotations[purelbv1.SharingAnnotation]
 }
 
+// AddressCount extracts the number of addresses per IP family that a
+// service has asked for. It's 1 if the service hasn't asked.
+func AddressCount(svc *v1.Service) (int, error) {
+	raw, has := svc.Annotations[purelbv1.AddressCountAnnotation]
+	if !has {
+		return 1, nil
+	}
+	count, err := strconv.Atoi(raw)
+	if err != nil || count < 1 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive integer", purelbv1.AddressCountAnnotation, raw)
+	}
+	return count, nil
+}
+
 func namespacedName(svc *v1.Service) string {
 	return svc.Namespace + "/" + svc.Name
 }
@@ -0,0 +1,70 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func TestLBClass(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t}
+	a := New(l)
+	a.client = k
+	c := &controller{logger: l, ips: a, client: k}
+
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		LBClass: "purelb.io/dmz",
+		Groups: []*purelbv1.ServiceGroup{
+			localGroup("default", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/30", Pool: "1.2.3.0/30"}),
+		},
+	}))
+	c.MarkSynced()
+
+	// Services in our class get addresses
+	dmz := "purelb.io/dmz"
+	svc := service("dmz", ports("tcp/80"), "")
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "10.0.0.1"
+	svc.Spec.LoadBalancerClass = &dmz
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.Equal(t, "1.2.3.0", svc.Status.LoadBalancer.Ingress[0].IP)
+
+	// Services in other classes, including the default, don't
+	other := purelbv1.ServiceLBClass
+	svc = service("other", ports("tcp/80"), "")
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "10.0.0.2"
+	svc.Spec.LoadBalancerClass = &other
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.Empty(t, svc.Status.LoadBalancer.Ingress)
+
+	// We leave other classes' groups to their own webhooks
+	group := localGroup("overlap", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/30", Pool: "1.2.3.0/30"})
+	assert.NoError(t, c.ValidateServiceGroup(group))
+	group.Spec.LBClass = dmz
+	assert.Error(t, c.ValidateServiceGroup(group))
+
+	// Resources with no class belong to the default class
+	assert.True(t, purelbv1.InClass("", purelbv1.ServiceLBClass))
+	assert.False(t, purelbv1.InClass("", dmz))
+	assert.True(t, purelbv1.InClass(dmz, dmz))
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// standbyClient is the allocator's client while the controller is
+// on standby. It reads from the cluster but doesn't write, so
+// standbys can parse the configuration without duplicating the
+// leader's events and status updates.
+type standbyClient struct {
+	k8s.ServiceEvent
+}
+
+func (standbyClient) Infof(runtime.Object, string, string, ...interface{})  {}
+func (standbyClient) Errorf(runtime.Object, string, string, ...interface{}) {}
+func (standbyClient) UpdateQuotaStatus(*purelbv1.PureLBQuota) error         { return nil }
+func (standbyClient) UpdateGroupStatus(*purelbv1.ServiceGroup) error        { return nil }
+
+// Standby puts the controller on standby. It keeps track of the
+// configuration, but it doesn't allocate addresses or change anything
+// in the cluster until Lead is called.
+func (c *controller) Standby() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.standby = true
+	c.ips.SetClient(standbyClient{c.client})
+	c.logger.Log("event", "standby", "msg", "waiting to become the leader")
+}
+
+// Lead takes the controller off standby. Once the caches have synced
+// it rebuilds the allocator's state from the existing services, and
+// then it starts allocating.
+func (c *controller) Lead() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.standby = false
+	c.ips.SetClient(c.client)
+	c.logger.Log("event", "leading", "msg", "became the leader")
+
+	if c.synced {
+		c.rebuild()
+	}
+}
+
+// rebuild starts the allocator over with fresh pools and seeds them
+// so a new leader doesn't allocate addresses that are already in
+// use. Then it reprocesses all of the services.
+func (c *controller) rebuild() {
+	if c.config != nil {
+		c.applyConfig(c.config)
+	}
+	c.seed()
+	c.client.ForceSync()
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func TestStandby(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t}
+	a := New(l)
+	a.client = k
+	c := &controller{logger: l, ips: a, client: k}
+
+	c.Standby()
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups: []*purelbv1.ServiceGroup{
+			localGroup("default", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/31", Pool: "1.2.3.0/31"}),
+			localGroup("broken", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.4.0/24", Pool: "1.2.5.0/24"}),
+		},
+	}))
+	assert.False(t, k.loggedWarning, "standby sent an event")
+	c.MarkSynced()
+
+	// Standbys don't allocate
+	svc := service("new", ports("tcp/80"), "")
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "10.0.0.1"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.Empty(t, svc.Status.LoadBalancer.Ingress)
+
+	// The old leader allocated an address that a standby doesn't know
+	// about
+	existing := service("existing", ports("tcp/80"), "")
+	existing.Spec.Type = "LoadBalancer"
+	existing.Spec.ClusterIP = "10.0.0.2"
+	existing.Annotations[purelbv1.BrandAnnotation] = purelbv1.Brand
+	existing.Annotations[purelbv1.PoolAnnotation] = "default"
+	existing.Status = statusAssigned("1.2.3.0")
+	k.services = []*v1.Service{&existing}
+
+	// The new leader learns about it before it allocates
+	c.Lead()
+	assert.Equal(t, 1, a.pools["default"].InUse())
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.Equal(t, "1.2.3.1", svc.Status.LoadBalancer.Ingress[0].IP)
+
+	// The leader sends events
+	assert.True(t, k.loggedWarning, "leader didn't send an event")
+}
@@ -0,0 +1,255 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+const (
+	// LeaseCheckInterval is how often the controller checks for
+	// address leases that are about to expire.
+	LeaseCheckInterval = time.Minute
+
+	// defaultLeaseWarningPeriod is how long before a lease expires
+	// that we start warning the service's owner, unless the group
+	// says otherwise.
+	defaultLeaseWarningPeriod = time.Hour
+)
+
+// leaseSpec returns the lease configuration of the group named
+// poolName, or nil if it doesn't have one.
+func (a *Allocator) leaseSpec(poolName string) *purelbv1.ServiceGroupLeaseSpec {
+	if group := a.groups[poolName]; group != nil {
+		return group.Spec.Lease
+	}
+	return nil
+}
+
+// LeaseDuration returns how long svc can keep the addresses that it
+// got from the pool named poolName, or 0 if it can keep them
+// forever. If svc's lease annotation is invalid then the error will
+// be non-nil and the duration will be the group's maximum.
+func (a *Allocator) LeaseDuration(svc *v1.Service, poolName string) (time.Duration, error) {
+	var max time.Duration
+	if spec := a.leaseSpec(poolName); spec != nil && spec.MaxDuration != nil {
+		max = spec.MaxDuration.Duration
+	}
+
+	requested, has := svc.Annotations[purelbv1.LeaseDurationAnnotation]
+	if !has {
+		return max, nil
+	}
+	duration, err := time.ParseDuration(requested)
+	if err != nil || duration <= 0 {
+		return max, fmt.Errorf("invalid %s %q", purelbv1.LeaseDurationAnnotation, requested)
+	}
+	if max > 0 && duration > max {
+		return max, nil
+	}
+	return duration, nil
+}
+
+// LeaseWarningPeriod returns how long before a lease from the pool
+// named poolName expires that we warn the service's owner.
+func (a *Allocator) LeaseWarningPeriod(poolName string) time.Duration {
+	if spec := a.leaseSpec(poolName); spec != nil && spec.WarningPeriod != nil {
+		return spec.WarningPeriod.Duration
+	}
+	return defaultLeaseWarningPeriod
+}
+
+// SwitchToClusterIP indicates whether services whose leases from the
+// pool named poolName have expired should be changed to ClusterIP.
+func (a *Allocator) SwitchToClusterIP(poolName string) bool {
+	spec := a.leaseSpec(poolName)
+	return spec != nil && spec.SwitchToClusterIP
+}
+
+// annotatedExpiry returns the expiry time in svc's lease annotation.
+// If svc doesn't have a valid one then has will be false.
+func annotatedExpiry(svc *v1.Service) (expires time.Time, has bool) {
+	value, has := svc.Annotations[purelbv1.LeaseExpiresAnnotation]
+	if !has {
+		return time.Time{}, false
+	}
+	expires, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expires, true
+}
+
+// leaseStart returns the time at which svc's leased address was
+// allocated. If svc doesn't have a lease then has will be false.
+func leaseStart(svc *v1.Service) (start time.Time, has bool) {
+	condition := meta.FindStatusCondition(svc.Status.Conditions, purelbv1.LeaseCondition)
+	if condition == nil {
+		return time.Time{}, false
+	}
+	return condition.LastTransitionTime.Time, true
+}
+
+// startLease records on svc that its lease started at start.
+func startLease(svc *v1.Service, start time.Time) {
+	meta.RemoveStatusCondition(&svc.Status.Conditions, purelbv1.LeaseCondition)
+	meta.SetStatusCondition(&svc.Status.Conditions, metav1.Condition{
+		Type:               purelbv1.LeaseCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             "AddressAllocated",
+		Message:            "The address lease started when the address was allocated",
+		LastTransitionTime: metav1.NewTime(start),
+	})
+}
+
+// leaseExpires returns the time at which svc's lease expires. Users
+// can edit the lease annotation, so if svc's group has a maximum lease
+// then the lease expires no later than the maximum after the address
+// was allocated. If svc doesn't have a lease then has will be false.
+func (a *Allocator) leaseExpires(svc *v1.Service) (expires time.Time, has bool) {
+	expires, has = annotatedExpiry(svc)
+
+	start, started := leaseStart(svc)
+	spec := a.leaseSpec(svc.Annotations[purelbv1.PoolAnnotation])
+	if !started || spec == nil || spec.MaxDuration == nil || spec.MaxDuration.Duration <= 0 {
+		return expires, has
+	}
+	if limit := start.Add(spec.MaxDuration.Duration); !has || limit.Before(expires) {
+		return limit, true
+	}
+	return expires, true
+}
+
+// setLease records the expiry time of svc's lease, if it has one, on
+// svc.
+func (c *controller) setLease(svc *v1.Service, pool string) {
+	meta.RemoveStatusCondition(&svc.Status.Conditions, purelbv1.LeaseCondition)
+	duration, err := c.ips.LeaseDuration(svc, pool)
+	if err != nil {
+		c.client.Errorf(svc, "InvalidLease", "%s, using the group's maximum", err)
+	}
+	if duration > 0 {
+		now := time.Now()
+		startLease(svc, now)
+		svc.Annotations[purelbv1.LeaseExpiresAnnotation] = now.Add(duration).UTC().Format(time.RFC3339)
+	}
+}
+
+// checkLease applies the lease rules to svc, which already has an
+// address. Services that got their addresses before their group had
+// a lease get one now, and expiry times that users have pushed past
+// the group's maximum are pulled back.
+func (c *controller) checkLease(svc *v1.Service) {
+	if _, started := leaseStart(svc); !started {
+		if _, has := annotatedExpiry(svc); !has {
+			c.setLease(svc, svc.Annotations[purelbv1.PoolAnnotation])
+			return
+		}
+		// We don't know when the address was allocated so the lease
+		// starts now
+		startLease(svc, time.Now())
+	}
+
+	expires, has := c.ips.leaseExpires(svc)
+	if !has {
+		return
+	}
+	if formatted := expires.UTC().Format(time.RFC3339); svc.Annotations[purelbv1.LeaseExpiresAnnotation] != formatted {
+		c.client.Errorf(svc, "InvalidLease", "%s %q is invalid or after the group's maximum, using %s", purelbv1.LeaseExpiresAnnotation, svc.Annotations[purelbv1.LeaseExpiresAnnotation], formatted)
+		svc.Annotations[purelbv1.LeaseExpiresAnnotation] = formatted
+	}
+}
+
+// expireLease releases svc's addresses because its lease has
+// expired.
+func (c *controller) expireLease(l log.Logger, svc *v1.Service) k8s.SyncState {
+	nsName := namespacedName(svc)
+
+	l.Log("event", "unassign", "ingress-address", svc.Status.LoadBalancer.Ingress, "reason", "lease expired")
+	if err := c.ips.Unassign(nsName); err != nil {
+		l.Log("event", "unassign", "error", err)
+		return k8s.SyncStateError
+	}
+	svc.Status.LoadBalancer.Ingress = nil
+	delete(svc.Annotations, purelbv1.PrefixAnnotation)
+	delete(svc.Annotations, purelbv1.LeaseExpiresAnnotation)
+	meta.RemoveStatusCondition(&svc.Status.Conditions, purelbv1.LeaseCondition)
+	delete(c.leaseWarned, nsName)
+	removeFinalizer(svc)
+
+	if c.ips.SwitchToClusterIP(svc.Annotations[purelbv1.PoolAnnotation]) {
+		c.client.Infof(svc, "AddressReleased", "Lease expired, changing Type to ClusterIP")
+		svc.Spec.Type = v1.ServiceTypeClusterIP
+		svc.Spec.ExternalTrafficPolicy = ""
+		svc.Spec.HealthCheckNodePort = 0
+		svc.Spec.AllocateLoadBalancerNodePorts = nil
+		svc.Spec.LoadBalancerClass = nil
+		for i := range svc.Spec.Ports {
+			svc.Spec.Ports[i].NodePort = 0
+		}
+		return k8s.SyncStateSuccess
+	}
+
+	c.client.Infof(svc, "AddressReleased", "Lease expired, remove the %s annotation to get a new address", purelbv1.LeaseExpiredAnnotation)
+	svc.Annotations[purelbv1.LeaseExpiredAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	return k8s.SyncStateSuccess
+}
+
+// CheckLeases warns the owners of services whose leases are about to
+// expire, and reprocesses the services whose leases have expired so
+// their addresses are released. It also forgets the sticky addresses
+// of deleted services that have expired.
+func (c *controller) CheckLeases() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.synced || c.standby || c.client == nil {
+		return
+	}
+
+	c.ips.pruneSticky()
+
+	now := time.Now()
+	for _, svc := range c.client.Services() {
+		expires, has := c.ips.leaseExpires(svc)
+		if !has {
+			continue
+		}
+		nsName := namespacedName(svc)
+
+		if !now.Before(expires) {
+			c.client.ResyncService(nsName)
+			continue
+		}
+
+		warnAt := expires.Add(-c.ips.LeaseWarningPeriod(svc.Annotations[purelbv1.PoolAnnotation]))
+		if !now.Before(warnAt) && c.leaseWarned[nsName] != svc.Annotations[purelbv1.LeaseExpiresAnnotation] {
+			c.client.Errorf(svc, "LeaseExpiring", "Address lease expires at %s", expires.Format(time.RFC3339))
+			if c.leaseWarned == nil {
+				c.leaseWarned = map[string]string{}
+			}
+			c.leaseWarned[nsName] = svc.Annotations[purelbv1.LeaseExpiresAnnotation]
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func TestLeases(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t}
+	a := New(l)
+	a.client = k
+	c := &controller{
+		logger: l,
+		ips:    a,
+		client: k,
+	}
+	max := metav1.Duration{Duration: 48 * time.Hour}
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups: []*purelbv1.ServiceGroup{{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Spec: purelbv1.ServiceGroupSpec{
+				Local: &purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/24", Pool: "1.2.3.0/24"},
+				Lease: &purelbv1.ServiceGroupLeaseSpec{MaxDuration: &max},
+			},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "switch"},
+			Spec: purelbv1.ServiceGroupSpec{
+				Local: &purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.4.0/24", Pool: "1.2.4.0/24"},
+				Lease: &purelbv1.ServiceGroupLeaseSpec{SwitchToClusterIP: true},
+			},
+		}},
+	}))
+	c.MarkSynced()
+
+	// The group's maximum limits the requested lease
+	svc := service("long", ports("tcp/80"), "")
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "10.0.0.1"
+	svc.Annotations[purelbv1.LeaseDurationAnnotation] = "720h"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	expires, has := a.leaseExpires(&svc)
+	assert.True(t, has)
+	assert.WithinDuration(t, time.Now().Add(max.Duration), expires, time.Minute)
+
+	// Users can shorten the lease but they can't extend it past the
+	// group's maximum, even by removing the annotation
+	svc.Annotations[purelbv1.LeaseExpiresAnnotation] = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	expires, _ = a.leaseExpires(&svc)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Minute)
+	assert.False(t, k.loggedWarning, "shortened lease was rejected")
+	for _, value := range []string{"9999-12-31T23:59:59Z", "forever", ""} {
+		if value == "" {
+			delete(svc.Annotations, purelbv1.LeaseExpiresAnnotation)
+		} else {
+			svc.Annotations[purelbv1.LeaseExpiresAnnotation] = value
+		}
+		k.reset()
+		assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+		assert.True(t, k.loggedWarning, "invalid lease %q wasn't reported", value)
+		expires, has = annotatedExpiry(&svc)
+		assert.True(t, has)
+		assert.WithinDuration(t, time.Now().Add(max.Duration), expires, time.Minute, value)
+	}
+	k.reset()
+
+	// A short lease gets a warning
+	svc = service("short", ports("tcp/80"), "")
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "10.0.0.2"
+	svc.Annotations[purelbv1.LeaseDurationAnnotation] = "10m"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	k.services = []*v1.Service{&svc}
+	c.CheckLeases()
+	assert.True(t, k.loggedWarning, "lease expiry warning wasn't sent")
+	assert.Empty(t, k.resynced)
+
+	// We only warn once per lease
+	k.reset()
+	c.CheckLeases()
+	assert.False(t, k.loggedWarning, "lease expiry warning was sent twice")
+
+	// Once the lease expires the service is reprocessed and its
+	// address is released
+	svc.Annotations[purelbv1.LeaseExpiresAnnotation] = time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+	c.CheckLeases()
+	assert.Equal(t, []string{"unit/short"}, k.resynced)
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.Empty(t, svc.Status.LoadBalancer.Ingress)
+	assert.Contains(t, svc.Annotations, purelbv1.LeaseExpiredAnnotation)
+	assert.NotContains(t, svc.Annotations, purelbv1.LeaseExpiresAnnotation)
+	assert.Equal(t, 1, a.pools["default"].InUse())
+
+	// It doesn't get a new address until the user asks
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.Empty(t, svc.Status.LoadBalancer.Ingress)
+	delete(svc.Annotations, purelbv1.LeaseExpiredAnnotation)
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.NotEmpty(t, svc.Status.LoadBalancer.Ingress)
+
+	// Services in groups that switch to ClusterIP are switched
+	svc = service("switch", ports("tcp/80"), "")
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "10.0.0.3"
+	svc.Spec.Ports[0].NodePort = 30080
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "switch"
+	svc.Annotations[purelbv1.LeaseDurationAnnotation] = "1h"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	svc.Annotations[purelbv1.LeaseExpiresAnnotation] = time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.Equal(t, v1.ServiceTypeClusterIP, svc.Spec.Type)
+	assert.Equal(t, int32(0), svc.Spec.Ports[0].NodePort)
+	assert.Empty(t, svc.Status.LoadBalancer.Ingress)
+	assert.Equal(t, 0, a.pools["switch"].InUse())
+
+	// Services without a lease keep their addresses
+	svc = service("forever", ports("tcp/80"), "")
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "10.0.0.4"
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "switch"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.NotContains(t, svc.Annotations, purelbv1.LeaseExpiresAnnotation)
+
+	// Services that got their addresses before their group had a lease
+	// get one
+	existing := existingService("existing", ports("tcp/80"), "", "1.2.3.200", time.Now().Add(-time.Hour))
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(existing, nil))
+	assert.NotEmpty(t, existing.Status.LoadBalancer.Ingress)
+	expires, has = annotatedExpiry(existing)
+	assert.True(t, has)
+	assert.WithinDuration(t, time.Now().Add(max.Duration), expires, time.Minute)
+	_, has = leaseStart(existing)
+	assert.True(t, has)
+}
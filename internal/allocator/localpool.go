@@ -16,18 +16,28 @@
 package allocator
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 	"net"
+	"sort"
 	"strings"
+	"time"
 
+	go_cidr "github.com/apparentlymart/go-cidr/cidr"
 	"github.com/go-kit/kit/log"
 	"github.com/vishvananda/netlink/nl"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
 
 	"purelb.io/internal/local"
 	purelbv1 "purelb.io/pkg/apis/v1"
 )
 
+// autoSharingKey is the implicit sharing key of services in an
+// AutoShare pool that don't have a key of their own.
+const autoSharingKey = "purelb auto-share"
+
 // Pool is the configuration of an IP address pool.
 type LocalPool struct {
 	logger log.Logger
@@ -42,6 +52,37 @@ type LocalPool struct {
 	// both within and between pools.
 	v6Range *IPRange
 
+	// v4Prefix and v6Prefix are the lengths of the address blocks
+	// that this pool allocates to each service, or 0 if each service
+	// gets a single address.
+	v4Prefix int
+	v6Prefix int
+
+	// autoShare is true if services without a sharing key can share
+	// addresses once the pool is full.
+	autoShare bool
+
+	// namespaceGroups lists the groups of namespaces whose services
+	// can share addresses with each other. Services can always share
+	// with services in their own namespace.
+	namespaceGroups [][]string
+
+	// reservations contains the addresses that are set aside for
+	// particular namespaces or services.
+	reservations map[string]reservation // ip.String() -> reservation
+
+	// stickyWindow is how long the addresses of deleted services stay
+	// reserved for them.
+	stickyWindow time.Duration
+
+	// staticConflicts describes the static assignments in the spec
+	// that we couldn't accept.
+	staticConflicts []string
+
+	// Map of the address blocks that have been assigned, indexed by
+	// the first address in the block.
+	blocks map[string]net.IPNet // ip.String() -> block
+
 	// Map of the addresses that have been assigned.
 	addressesInUse map[string]map[string]bool // ip.String() -> svc name -> true
 
@@ -57,6 +98,15 @@ func NewLocalPool(log log.Logger, spec purelbv1.ServiceGroupLocalSpec) (*LocalPo
 		addressesInUse: map[string]map[string]bool{},
 		sharingKeys:    map[string]*Key{},
 		portsInUse:     map[string]map[Port]string{},
+		blocks:         map[string]net.IPNet{},
+		reservations:   map[string]reservation{},
+		autoShare:      spec.AutoShare,
+	}
+	if spec.Sharing != nil {
+		pool.namespaceGroups = spec.Sharing.NamespaceGroups
+	}
+	if spec.StickyWindow != nil {
+		pool.stickyWindow = spec.StickyWindow.Duration
 	}
 
 	// See if there's an IPV6 range in the spec
@@ -76,6 +126,9 @@ func NewLocalPool(log log.Logger, spec purelbv1.ServiceGroupLocalSpec) (*LocalPo
 		}
 
 		pool.v6Range = &iprange
+		if pool.v6Prefix, err = validPrefixLength(spec.V6Pool.PrefixLength, *subnet); err != nil {
+			return nil, err
+		}
 	}
 
 	// See if there's an IPV4 range in the spec
@@ -95,6 +148,9 @@ func NewLocalPool(log log.Logger, spec purelbv1.ServiceGroupLocalSpec) (*LocalPo
 		}
 
 		pool.v4Range = &iprange
+		if pool.v4Prefix, err = validPrefixLength(spec.V4Pool.PrefixLength, *subnet); err != nil {
+			return nil, err
+		}
 	}
 
 	// See if there's a top-level range in the spec
@@ -135,12 +191,39 @@ func NewLocalPool(log log.Logger, spec purelbv1.ServiceGroupLocalSpec) (*LocalPo
 		return nil, fmt.Errorf("no valid address range found")
 	}
 
+	// Static assignments are permanent reservations for a single
+	// service
+	svcNames := make([]string, 0, len(spec.StaticAssignments))
+	for svcName := range spec.StaticAssignments {
+		svcNames = append(svcNames, svcName)
+	}
+	sort.Strings(svcNames)
+	for _, svcName := range svcNames {
+		namespace, name, err := cache.SplitMetaNamespaceKey(svcName)
+		if err != nil || namespace == "" {
+			pool.staticConflicts = append(pool.staticConflicts, fmt.Sprintf("%q is not a namespace/name", svcName))
+			continue
+		}
+		for _, ipstr := range spec.StaticAssignments[svcName] {
+			ip := net.ParseIP(ipstr)
+			if ip == nil || !pool.Contains(ip) {
+				pool.staticConflicts = append(pool.staticConflicts, fmt.Sprintf("%s for %s is not in the group", ipstr, svcName))
+				continue
+			}
+			if other, has := pool.reservations[ip.String()]; has {
+				pool.staticConflicts = append(pool.staticConflicts, fmt.Sprintf("%s for %s is already assigned to %s", ipstr, svcName, other))
+				continue
+			}
+			pool.reservations[ip.String()] = reservation{namespace: namespace, service: name, static: true}
+		}
+	}
+
 	return &pool, nil
 }
 
 func (p LocalPool) Notify(service *v1.Service) error {
 	nsName := namespacedName(service)
-	sharingKey := &Key{Sharing: SharingKey(service)}
+	sharingKey := p.sharingKey(service)
 	ports := Ports(service)
 
 	for _, ingress := range service.Status.LoadBalancer.Ingress {
@@ -152,6 +235,9 @@ func (p LocalPool) Notify(service *v1.Service) error {
 		}
 		p.logger.Log("localpool", "notify-existing", "service", nsName, "ip", ipstr)
 
+		if block := p.serviceBlock(service, ip); block != nil {
+			p.blocks[ipstr] = *block
+		}
 		p.sharingKeys[ipstr] = sharingKey
 		if p.addressesInUse[ipstr] == nil {
 			p.addressesInUse[ipstr] = map[string]bool{}
@@ -174,12 +260,42 @@ func (p LocalPool) Notify(service *v1.Service) error {
 // nil if the ip is available, and will contain an explanation if not.
 func (p LocalPool) available(ip net.IP, service *v1.Service) error {
 	nsName := namespacedName(service)
-	key := &Key{Sharing: SharingKey(service)}
+	key := p.sharingKey(service)
 	ports := Ports(service)
 
-	// No key: no sharing
-	if key == nil {
-		key = &Key{}
+	// Reserved addresses can only go to the services for which they're
+	// reserved
+	if resv, reserved := p.reserved(ip); reserved && !resv.allows(service) {
+		return fmt.Errorf("%s is reserved for %s", ip, resv)
+	}
+
+	// If this pool allocates blocks then the address needs to be the
+	// start of a block that fits in the range
+	if block := p.block(ip); block != nil {
+		if !block.IP.Equal(ip) {
+			return fmt.Errorf("%s is not the start of a /%d block", ip, p.prefixLength(local.AddrFamily(ip)))
+		}
+		if !p.Contains(lastAddress(*block)) {
+			return fmt.Errorf("block %s extends beyond the pool", block)
+		}
+	}
+
+	// Blocks can't be shared, so the address can't overlap a block
+	// that belongs to another service, and if we're allocating a
+	// block it can't contain another service's address.
+	for ipstr, svcs := range p.addressesInUse {
+		if len(p.blocks) == 0 && p.block(ip) == nil {
+			break
+		}
+		if svcs[nsName] {
+			continue
+		}
+		if inUse, has := p.blocks[ipstr]; has && inUse.Contains(ip) {
+			return fmt.Errorf("%s is in block %s which is in use by %s", ip, inUse.String(), strings.Join(p.servicesOnIP(inUse.IP), ","))
+		}
+		if block := p.block(ip); block != nil && block.Contains(net.ParseIP(ipstr)) {
+			return fmt.Errorf("block %s contains %s which is in use by %s", block, ipstr, strings.Join(p.servicesOnIP(net.ParseIP(ipstr)), ","))
+		}
 	}
 
 	// Does the IP already have allocs? If so, needs to be the same
@@ -202,6 +318,17 @@ func (p LocalPool) available(ip net.IP, service *v1.Service) error {
 			}
 		}
 
+		// Sharing keys are scoped to the service's namespace unless the
+		// policy lets the namespaces share. Services that PureLB shares
+		// automatically don't choose their key so they're not scoped.
+		if key.Sharing != autoSharingKey {
+			for _, otherSvc := range p.servicesOnIP(ip) {
+				if !p.namespacesCanShare(nsName, otherSvc) {
+					return &sharingBlockedError{service: nsName, other: otherSvc, ip: ip}
+				}
+			}
+		}
+
 		for _, port := range ports {
 			if curSvc, ok := p.portsInUse[ip.String()][port]; ok && curSvc != nsName {
 				return fmt.Errorf("port %s on %q is already in use by %s", port, ip, curSvc)
@@ -212,45 +339,145 @@ func (p LocalPool) available(ip net.IP, service *v1.Service) error {
 	return nil
 }
 
-// AssignNext assigns the next available IP to service.
+// AssignNext assigns the next available IP to service. If the
+// service has an AddressCountAnnotation then it assigns that many
+// addresses per family, or none at all if they're not all available.
 func (p LocalPool) AssignNext(service *v1.Service) error {
 	families, err := p.whichFamilies(service)
 	if err != nil {
 		return err
 	}
+	count, err := AddressCount(service)
+	if err != nil {
+		return err
+	}
 
 	if len(families) == 0 {
 		// Any address is OK so try V6 first then V4 and assign the first
 		// one that succeeds
-		if err := p.assignFamily(nl.FAMILY_V6, service); err == nil {
+		if err := p.assignFamilies([]int{nl.FAMILY_V6}, count, service); err == nil {
 			return err
 		}
-		if err := p.assignFamily(nl.FAMILY_V4, service); err == nil {
+		if err := p.assignFamilies([]int{nl.FAMILY_V4}, count, service); err == nil {
 			return err
 		}
 		return fmt.Errorf("no available addresses in pool")
 	}
 
 	// We have a specific set of families to assign
+	return p.assignFamilies(families, count, service)
+}
+
+// assignFamilies assigns count addresses in each of families to
+// service. If any of them can't be assigned then it undoes the ones
+// that were so the service is left as it was.
+func (p LocalPool) assignFamilies(families []int, count int, service *v1.Service) error {
+	ingress := service.Status.LoadBalancer.Ingress
+	prefixes, hadPrefixes := service.Annotations[purelbv1.PrefixAnnotation]
+
 	for _, family := range families {
-		if err := p.assignFamily(family, service); err != nil {
-			return err
+		for i := 0; i < count; i++ {
+			if err := p.assignFamily(family, service); err != nil {
+				for _, assigned := range service.Status.LoadBalancer.Ingress[len(ingress):] {
+					p.releaseIP(assigned.IP, namespacedName(service))
+				}
+				service.Status.LoadBalancer.Ingress = ingress
+				if hadPrefixes {
+					service.Annotations[purelbv1.PrefixAnnotation] = prefixes
+				} else {
+					delete(service.Annotations, purelbv1.PrefixAnnotation)
+				}
+				return err
+			}
 		}
 	}
 	return nil
 }
 
 func (p LocalPool) assignFamily(family int, service *v1.Service) error {
+	// If an address has been reserved for this service then it gets
+	// that one
+	for ipstr, resv := range p.reservations {
+		ip := net.ParseIP(ipstr)
+		if resv.service == "" || local.AddrFamily(ip) != family || p.addressesInUse[ipstr][namespacedName(service)] {
+			continue
+		}
+		if _, reserved := p.reserved(ip); reserved && resv.allows(service) {
+			if err := p.Assign(ip, service); err == nil {
+				return nil
+			}
+		}
+	}
+
+	if p.prefixLength(family) != 0 {
+		return p.assignBlock(family, service)
+	}
+
+	// Services that share automatically only get an address that's
+	// in use if there are no free ones, so we'll come back to them
+	autoShared := p.sharingKey(service).Sharing == autoSharingKey
+	shareable := []net.IP{}
+
 	for pos := p.first(family); pos != nil; pos = p.next(pos) {
+		// Skip addresses that this service already has, and reserved
+		// addresses which are only allocated on request
+		if p.addressesInUse[pos.String()][namespacedName(service)] {
+			continue
+		}
+		if _, reserved := p.reserved(pos); reserved {
+			continue
+		}
+		if autoShared && len(p.addressesInUse[pos.String()]) > 0 {
+			shareable = append(shareable, pos)
+			continue
+		}
 		if err := p.Assign(pos, service); err == nil {
 			// we found an available address
 			return err
 		}
 	}
 
+	// The pool is full so pack the service onto the address with the
+	// fewest services that it can share with
+	sort.SliceStable(shareable, func(i, j int) bool {
+		return len(p.addressesInUse[shareable[i].String()]) < len(p.addressesInUse[shareable[j].String()])
+	})
+	for _, pos := range shareable {
+		if err := p.Assign(pos, service); err == nil {
+			p.logger.Log("localpool", "auto-share", "service", namespacedName(service), "ip", pos, "services", len(p.addressesInUse[pos.String()]))
+			return err
+		}
+	}
+
 	return fmt.Errorf("no available addresses for service %s in family %d", namespacedName(service), family)
 }
 
+// assignBlock assigns the first free aligned address block in family
+// to service.
+func (p LocalPool) assignBlock(family int, service *v1.Service) error {
+	for block := p.block(p.first(family)); block != nil; block = p.block(nextBlock(*block)) {
+		// The first block might start before the range so skip it
+		if !p.Contains(block.IP) {
+			continue
+		}
+		if !p.Contains(lastAddress(*block)) {
+			break
+		}
+		if p.addressesInUse[block.IP.String()][namespacedName(service)] {
+			continue
+		}
+		if _, reserved := p.reserved(block.IP); reserved {
+			continue
+		}
+		if err := p.Assign(block.IP, service); err == nil {
+			// we found an available block
+			return err
+		}
+	}
+
+	return fmt.Errorf("no available /%d blocks for service %s in family %d", p.prefixLength(family), namespacedName(service), family)
+}
+
 // Assign assigns a service to an IP.
 func (p LocalPool) Assign(ip net.IP, service *v1.Service) error {
 	if err := p.available(ip, service); err != nil {
@@ -259,6 +486,9 @@ func (p LocalPool) Assign(ip net.IP, service *v1.Service) error {
 
 	// we have an IP selected somehow, so program the data plane
 	addIngress(p.logger, service, ip)
+	if block := p.block(ip); block != nil {
+		setPrefixAnnotation(service, *block)
+	}
 
 	// Update our internal allocation data structures
 	return p.Notify(service)
@@ -266,22 +496,30 @@ func (p LocalPool) Assign(ip net.IP, service *v1.Service) error {
 
 // Release releases an IP so it can be assigned again.
 func (p LocalPool) Release(service string) error {
-	for ipstr, allocs := range p.addressesInUse {
+	for ipstr := range p.addressesInUse {
+		p.releaseIP(ipstr, service)
+	}
+	return nil
+}
+
+// releaseIP releases one of service's addresses.
+func (p LocalPool) releaseIP(ipstr string, service string) {
+	if allocs, has := p.addressesInUse[ipstr]; has {
 		delete(allocs, service)
 		if len(allocs) == 0 {
 			delete(p.addressesInUse, ipstr)
 			delete(p.sharingKeys, ipstr)
+			delete(p.blocks, ipstr)
 		}
-		for port, svc := range p.portsInUse[ipstr] {
-			if svc == service {
-				delete(p.portsInUse[ipstr], port)
-			}
-		}
-		if len(p.portsInUse[ipstr]) == 0 {
-			delete(p.portsInUse, ipstr)
+	}
+	for port, svc := range p.portsInUse[ipstr] {
+		if svc == service {
+			delete(p.portsInUse[ipstr], port)
 		}
 	}
-	return nil
+	if len(p.portsInUse[ipstr]) == 0 {
+		delete(p.portsInUse, ipstr)
+	}
 }
 
 // InUse returns the count of addresses that currently have services
@@ -335,17 +573,392 @@ func (p LocalPool) next(ip net.IP) net.IP {
 }
 
 // Size returns the total number of addresses in this pool if it's a
-// local pool, or 0 if it's a remote pool.
+// local pool, or 0 if it's a remote pool. If the pool allocates
+// address blocks then each block counts as one.
 func (p LocalPool) Size() (size uint64) {
 	if p.v6Range != nil {
-		size += p.v6Range.Size()
+		size += blockCount(p.v6Range.Size(), 128-p.v6Prefix, p.v6Prefix)
 	}
 	if p.v4Range != nil {
-		size += p.v4Range.Size()
+		size += blockCount(p.v4Range.Size(), 32-p.v4Prefix, p.v4Prefix)
+	}
+	return
+}
+
+// blockCount returns the number of blocks of 2^hostBits addresses
+// that fit in size addresses. If prefix is 0 then the pool allocates
+// single addresses and the count is size.
+func blockCount(size uint64, hostBits int, prefix int) uint64 {
+	if prefix == 0 || size == math.MaxUint64 {
+		return size
+	}
+	if hostBits >= 64 {
+		return 0
+	}
+	return size >> hostBits
+}
+
+// sharingKey returns the key that determines which services can
+// share an address with service. Services that don't have a sharing
+// key get the implicit autoSharingKey if the pool allows automatic
+// sharing.
+func (p LocalPool) sharingKey(service *v1.Service) *Key {
+	key := SharingKey(service)
+	if key == "" && p.autoShare {
+		key = autoSharingKey
+	}
+	return &Key{Sharing: key}
+}
+
+// reservation records the namespace, and optionally the service, for
+// which an address is reserved.
+type reservation struct {
+	namespace string
+	service   string
+	expires   time.Time
+
+	// static is true if the reservation comes from the pool's
+	// StaticAssignments rather than an AddressReservation.
+	static bool
+
+	// sticky is true if the reservation holds a deleted service's
+	// address for the pool's StickyWindow.
+	sticky bool
+}
+
+// allows indicates whether service can have the reserved address.
+func (r reservation) allows(service *v1.Service) bool {
+	return service.Namespace == r.namespace && (r.service == "" || service.Name == r.service)
+}
+
+func (r reservation) String() string {
+	if r.service == "" {
+		return "namespace " + r.namespace
+	}
+	return r.namespace + "/" + r.service
+}
+
+// Reserve sets ip aside for the services in namespace, or for the
+// service named service if it's not "". The reservation lapses at
+// expires unless it's zero. It replaces a sticky reservation but not
+// another active reservation.
+func (p LocalPool) Reserve(ip net.IP, namespace string, service string, expires time.Time) error {
+	if !p.Contains(ip) {
+		return fmt.Errorf("%s is not in the pool", ip)
+	}
+	if resv, has := p.reservations[ip.String()]; has && resv.static {
+		return fmt.Errorf("%s is statically assigned to %s", ip, resv)
+	}
+	if resv, active := p.reserved(ip); active && !resv.sticky {
+		return fmt.Errorf("%s is already reserved for %s", ip, resv)
+	}
+	p.reservations[ip.String()] = reservation{namespace: namespace, service: service, expires: expires}
+	return nil
+}
+
+// Stick reserves ip for the deleted service named service in
+// namespace until expires.
+func (p LocalPool) Stick(ip net.IP, namespace string, service string, expires time.Time) {
+	if resv, has := p.reservations[ip.String()]; has && resv.static {
+		return
+	}
+	p.reservations[ip.String()] = reservation{namespace: namespace, service: service, expires: expires, sticky: true}
+}
+
+// Unstick removes ip's sticky reservation if it belongs to the
+// service named service in namespace.
+func (p LocalPool) Unstick(ip net.IP, namespace string, service string) {
+	if resv, has := p.reservations[ip.String()]; has && resv.sticky && resv.namespace == namespace && resv.service == service {
+		delete(p.reservations, ip.String())
+	}
+}
+
+// StickyAddresses returns the addresses that service holds by itself
+// if this pool keeps them after the service is deleted.
+func (p LocalPool) StickyAddresses(service string) []net.IP {
+	ips := []net.IP{}
+	if p.stickyWindow <= 0 {
+		return ips
+	}
+	for ipstr, svcs := range p.addressesInUse {
+		if svcs[service] && len(svcs) == 1 {
+			ips = append(ips, net.ParseIP(ipstr))
+		}
+	}
+	return ips
+}
+
+// StickyWindow returns how long this pool keeps deleted services'
+// addresses for them.
+func (p LocalPool) StickyWindow() time.Duration {
+	return p.stickyWindow
+}
+
+// ClearReservations removes all of the pool's reservations. Static
+// assignments are part of the pool's spec so they stay.
+func (p LocalPool) ClearReservations() {
+	for ipstr, resv := range p.reservations {
+		if !resv.static {
+			delete(p.reservations, ipstr)
+		}
+	}
+}
+
+// StaticAddresses returns the addresses that are statically assigned
+// to service.
+func (p LocalPool) StaticAddresses(service *v1.Service) []net.IP {
+	ips := []net.IP{}
+	for ipstr, resv := range p.reservations {
+		if resv.static && resv.allows(service) {
+			ips = append(ips, net.ParseIP(ipstr))
+		}
+	}
+	sort.Slice(ips, func(i, j int) bool { return bytes.Compare(ips[i], ips[j]) < 0 })
+	return ips
+}
+
+// AssignStatic assigns service's static addresses to it.
+func (p LocalPool) AssignStatic(service *v1.Service) error {
+	for _, ip := range p.StaticAddresses(service) {
+		if p.addressesInUse[ip.String()][namespacedName(service)] {
+			continue
+		}
+		if err := p.Assign(ip, service); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StaticConflicts describes the static assignments that the pool
+// can't honor.
+func (p LocalPool) StaticConflicts() []string {
+	conflicts := append([]string{}, p.staticConflicts...)
+	for ipstr, resv := range p.reservations {
+		if !resv.static {
+			continue
+		}
+		for _, svc := range p.servicesOnIP(net.ParseIP(ipstr)) {
+			if svc != resv.String() {
+				conflicts = append(conflicts, fmt.Sprintf("%s for %s is in use by %s", ipstr, resv, svc))
+			}
+		}
+	}
+	sort.Strings(conflicts[len(p.staticConflicts):])
+	return conflicts
+}
+
+// reserved returns ip's reservation, and true if ip has a reservation
+// that hasn't expired.
+func (p LocalPool) reserved(ip net.IP) (reservation, bool) {
+	resv, has := p.reservations[ip.String()]
+	if !has || (!resv.expires.IsZero() && time.Now().After(resv.expires)) {
+		return resv, false
+	}
+	return resv, true
+}
+
+// ReservedCounts returns the number of unexpired reservations in this
+// pool, and the number of those addresses that have been claimed by
+// the services for which they're reserved.
+func (p LocalPool) ReservedCounts() (reserved int, claimed int) {
+	for ipstr := range p.reservations {
+		resv, active := p.reserved(net.ParseIP(ipstr))
+		if !active || resv.static || resv.sticky {
+			continue
+		}
+		reserved++
+		for svc := range p.addressesInUse[ipstr] {
+			namespace, name, _ := cache.SplitMetaNamespaceKey(svc)
+			if namespace == resv.namespace && (resv.service == "" || name == resv.service) {
+				claimed++
+				break
+			}
+		}
 	}
 	return
 }
 
+// sharingBlockedError indicates that a service had the same sharing
+// key as the services on an address but the sharing policy doesn't
+// let their namespaces share.
+type sharingBlockedError struct {
+	service string
+	other   string
+	ip      net.IP
+}
+
+func (e *sharingBlockedError) Error() string {
+	return fmt.Sprintf("sharing policy doesn't let %q share %s with %s", e.service, e.ip, e.other)
+}
+
+// namespacesCanShare indicates whether the sharing policy lets the
+// services named svc1 and svc2 share an address. Services in the same
+// namespace can always share, and services in different namespaces
+// can share if both namespaces are in one of the namespaceGroups.
+func (p LocalPool) namespacesCanShare(svc1 string, svc2 string) bool {
+	ns1, _, _ := cache.SplitMetaNamespaceKey(svc1)
+	ns2, _, _ := cache.SplitMetaNamespaceKey(svc2)
+	if ns1 == ns2 {
+		return true
+	}
+
+	for _, group := range p.namespaceGroups {
+		has1, has2 := false, false
+		for _, ns := range group {
+			has1 = has1 || ns == ns1
+			has2 = has2 || ns == ns2
+		}
+		if has1 && has2 {
+			return true
+		}
+	}
+	return false
+}
+
+// CanShare indicates whether this pool's rules let svc1 and svc2
+// share an address: their sharing keys, including the implicit
+// autoSharingKey, have to match, the sharing policy has to let their
+// namespaces share, and their ports can't clash.
+func (p LocalPool) CanShare(svc1 *v1.Service, svc2 *v1.Service) bool {
+	key1, key2 := p.sharingKey(svc1), p.sharingKey(svc2)
+	if err := sharingOK(key1, key2); err != nil {
+		return false
+	}
+	if key2.Sharing != autoSharingKey && !p.namespacesCanShare(namespacedName(svc1), namespacedName(svc2)) {
+		return false
+	}
+	return !portsClash(svc1, svc2)
+}
+
+// SharingBlocked returns an explanation if the sharing policy kept
+// service off of an address whose services have the same sharing
+// key, or "" if it didn't.
+func (p LocalPool) SharingBlocked(service *v1.Service) string {
+	key := p.sharingKey(service)
+	if key.Sharing == "" || key.Sharing == autoSharingKey {
+		return ""
+	}
+
+	for ipstr, existing := range p.sharingKeys {
+		if existing.Sharing != key.Sharing || p.addressesInUse[ipstr][namespacedName(service)] {
+			continue
+		}
+		if err := p.available(net.ParseIP(ipstr), service); err != nil {
+			if _, blocked := err.(*sharingBlockedError); blocked {
+				return err.Error()
+			}
+		}
+	}
+	return ""
+}
+
+// prefixLength returns the length of the blocks that this pool
+// allocates in family, or 0 if it allocates single addresses.
+func (p LocalPool) prefixLength(family int) int {
+	if family == nl.FAMILY_V6 {
+		return p.v6Prefix
+	}
+	if family == nl.FAMILY_V4 {
+		return p.v4Prefix
+	}
+	return 0
+}
+
+// block returns the address block of this pool's prefix length that
+// contains ip, or nil if ip is nil or the pool allocates single
+// addresses in ip's family.
+func (p LocalPool) block(ip net.IP) *net.IPNet {
+	if ip == nil {
+		return nil
+	}
+	family := local.AddrFamily(ip)
+	prefix := p.prefixLength(family)
+	if prefix == 0 {
+		return nil
+	}
+	bits := 128
+	if family == nl.FAMILY_V4 {
+		bits = 32
+		ip = ip.To4()
+	}
+	mask := net.CIDRMask(prefix, bits)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+// serviceBlock returns the address block that starts at ip. It uses
+// the service's PrefixAnnotation if it has one so blocks allocated
+// with a different prefix length are remembered correctly, and falls
+// back to the pool's prefix length. It returns nil if ip isn't the
+// start of a block.
+func (p LocalPool) serviceBlock(service *v1.Service, ip net.IP) *net.IPNet {
+	for _, cidr := range strings.Split(service.Annotations[purelbv1.PrefixAnnotation], ",") {
+		if _, block, err := net.ParseCIDR(cidr); err == nil && block.IP.Equal(ip) {
+			return block
+		}
+	}
+	if block := p.block(ip); block != nil && block.IP.Equal(ip) {
+		return block
+	}
+	return nil
+}
+
+// setPrefixAnnotation adds block to the service's PrefixAnnotation,
+// dropping any blocks that don't start at one of the service's
+// current ingress addresses.
+func setPrefixAnnotation(service *v1.Service, block net.IPNet) {
+	blocks := []string{}
+	for _, cidr := range strings.Split(service.Annotations[purelbv1.PrefixAnnotation], ",") {
+		ip, _, err := net.ParseCIDR(cidr)
+		if err != nil || cidr == block.String() {
+			continue
+		}
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			if ip.Equal(net.ParseIP(ingress.IP)) {
+				blocks = append(blocks, cidr)
+			}
+		}
+	}
+	blocks = append(blocks, block.String())
+
+	if service.Annotations == nil {
+		service.Annotations = map[string]string{}
+	}
+	service.Annotations[purelbv1.PrefixAnnotation] = strings.Join(blocks, ",")
+}
+
+// validPrefixLength checks that a per-service prefix length fits
+// within subnet. It returns the prefix length, which is 0 if each
+// service gets a single address.
+func validPrefixLength(prefix int, subnet net.IPNet) (int, error) {
+	if prefix == 0 {
+		return 0, nil
+	}
+	ones, bits := subnet.Mask.Size()
+	if prefix < ones || prefix > bits {
+		return 0, fmt.Errorf("prefix length %d must be between %d and %d for subnet %s", prefix, ones, bits, subnet.String())
+	}
+	return prefix, nil
+}
+
+// lastAddress returns the highest address in block.
+func lastAddress(block net.IPNet) net.IP {
+	_, last := go_cidr.AddressRange(&block)
+	return last
+}
+
+// nextBlock returns the first address after block, or nil if block
+// is at the end of the address space.
+func nextBlock(block net.IPNet) net.IP {
+	last := lastAddress(block)
+	next := dup(last)
+	inc(next)
+	if bytes.Compare(next, last) < 0 {
+		return nil
+	}
+	return next
+}
+
 // Overlaps indicates whether the other Pool overlaps with this one
 // (i.e., has any addresses in common).  It returns true if there are
 // any common addresses and false if there aren't.
@@ -29,7 +29,7 @@ import (
 var (
 	key1                = Key{Sharing: "sharing1"}
 	key2                = Key{Sharing: "sharing2"}
-	http                = Port{Proto: v1.ProtocolTCP, Port: 80}
+	httpPort            = Port{Proto: v1.ProtocolTCP, Port: 80}
 	smtp                = Port{Proto: v1.ProtocolTCP, Port: 25}
 	localPoolTestLogger = log.NewNopLogger()
 )
@@ -336,3 +336,167 @@ func mustDualStackPool(t *testing.T, pool4 string, subnet4 string, pool6 string,
 	}
 	return *p
 }
+
+func TestPrefixAllocation(t *testing.T) {
+	spec := purelbv1.ServiceGroupLocalSpec{
+		V6Pool: &purelbv1.ServiceGroupAddressPool{
+			Pool:         "fd53:9ef0:8683::10-fd53:9ef0:8683::3ff",
+			Subnet:       "fd53:9ef0:8683::/118",
+			PrefixLength: 120,
+		},
+	}
+	p, err := NewLocalPool(localPoolTestLogger, spec)
+	assert.NoError(t, err, "Pool instantiation failed")
+
+	// The first block starts before the range so there are only three
+	assert.Equal(t, uint64(3), p.Size(), "Pool Size() failed")
+
+	svc1 := service("svc1", ports("tcp/80"), "")
+	svc2 := service("svc2", ports("tcp/80"), "")
+	svc3 := service("svc3", ports("tcp/80"), "")
+
+	assert.NoError(t, p.AssignNext(&svc1))
+	assert.Equal(t, "fd53:9ef0:8683::100", svc1.Status.LoadBalancer.Ingress[0].IP)
+	assert.Equal(t, "fd53:9ef0:8683::100/120", svc1.Annotations[purelbv1.PrefixAnnotation])
+	assert.NoError(t, p.AssignNext(&svc2))
+	assert.Equal(t, "fd53:9ef0:8683::200", svc2.Status.LoadBalancer.Ingress[0].IP)
+
+	// Blocks can't be shared and addresses must start a block
+	assert.Error(t, p.available(net.ParseIP("fd53:9ef0:8683::100"), &svc3))
+	assert.Error(t, p.available(net.ParseIP("fd53:9ef0:8683::301"), &svc3))
+	assert.NoError(t, p.available(net.ParseIP("fd53:9ef0:8683::300"), &svc3))
+
+	// Once a block is released it can be reused
+	assert.NoError(t, p.Release(namespacedName(&svc1)))
+	assert.NoError(t, p.AssignNext(&svc3))
+	assert.Equal(t, "fd53:9ef0:8683::100", svc3.Status.LoadBalancer.Ingress[0].IP)
+
+	// A new pool learns about existing blocks from the annotation,
+	// even if they're a different size
+	spec.V6Pool.PrefixLength = 122
+	p, err = NewLocalPool(localPoolTestLogger, spec)
+	assert.NoError(t, err, "Pool instantiation failed")
+	assert.NoError(t, p.Notify(&svc2))
+	assert.Error(t, p.available(net.ParseIP("fd53:9ef0:8683::240"), &svc3))
+	assert.NoError(t, p.available(net.ParseIP("fd53:9ef0:8683::300"), &svc3))
+
+	// The prefix length has to fit in the subnet
+	spec.V6Pool.PrefixLength = 112
+	_, err = NewLocalPool(localPoolTestLogger, spec)
+	assert.Error(t, err, "prefix shorter than the subnet should fail")
+}
+
+func TestAddressCount(t *testing.T) {
+	p, err := NewLocalPool(localPoolTestLogger, purelbv1.ServiceGroupLocalSpec{
+		V4Pool: &purelbv1.ServiceGroupAddressPool{
+			Pool:   "192.168.1.0/30",
+			Subnet: "192.168.1.0/24",
+		},
+		V6Pool: &purelbv1.ServiceGroupAddressPool{
+			Pool:   "fd53:9ef0:8683::/120",
+			Subnet: "fd53:9ef0:8683::/120",
+		},
+	})
+	assert.NoError(t, err, "Pool instantiation failed")
+
+	svc1 := service("svc1", ports("tcp/80"), "")
+	svc1.Spec.IPFamilies = []v1.IPFamily{v1.IPv4Protocol}
+	svc1.Annotations[purelbv1.AddressCountAnnotation] = "3"
+	assert.NoError(t, p.AssignNext(&svc1))
+	assert.Equal(t, []v1.LoadBalancerIngress{{IP: "192.168.1.0"}, {IP: "192.168.1.1"}, {IP: "192.168.1.2"}}, svc1.Status.LoadBalancer.Ingress)
+
+	// Not enough IPV4 addresses left so the whole allocation fails,
+	// including the IPV6 addresses that were available
+	svc2 := service("svc2", ports("tcp/80"), "")
+	svc2.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol}
+	svc2.Annotations[purelbv1.AddressCountAnnotation] = "2"
+	assert.Error(t, p.AssignNext(&svc2))
+	assert.Empty(t, svc2.Status.LoadBalancer.Ingress)
+	assert.Equal(t, 3, p.InUse())
+
+	svc2.Annotations[purelbv1.AddressCountAnnotation] = "1"
+	assert.NoError(t, p.AssignNext(&svc2))
+	assert.Equal(t, []v1.LoadBalancerIngress{{IP: "fd53:9ef0:8683::"}, {IP: "192.168.1.3"}}, svc2.Status.LoadBalancer.Ingress)
+
+	svc2.Annotations[purelbv1.AddressCountAnnotation] = "none"
+	_, err = AddressCount(&svc2)
+	assert.Error(t, err)
+	svc2.Annotations[purelbv1.AddressCountAnnotation] = "0"
+	_, err = AddressCount(&svc2)
+	assert.Error(t, err)
+}
+
+func TestAutoShare(t *testing.T) {
+	p, err := NewLocalPool(localPoolTestLogger, purelbv1.ServiceGroupLocalSpec{
+		V4Pool: &purelbv1.ServiceGroupAddressPool{
+			Pool:   "192.168.1.0/31",
+			Subnet: "192.168.1.0/24",
+		},
+		AutoShare: true,
+	})
+	assert.NoError(t, err, "Pool instantiation failed")
+
+	// Free addresses are used before any are shared
+	svc1 := service("svc1", ports("tcp/80"), "")
+	svc2 := service("svc2", ports("tcp/80", "tcp/443"), "")
+	assert.NoError(t, p.AssignNext(&svc1))
+	assert.Equal(t, "192.168.1.0", svc1.Status.LoadBalancer.Ingress[0].IP)
+	assert.NoError(t, p.AssignNext(&svc2))
+	assert.Equal(t, "192.168.1.1", svc2.Status.LoadBalancer.Ingress[0].IP)
+
+	// The pool is full so services are packed onto the least-used
+	// address whose ports are free
+	svc3 := service("svc3", ports("tcp/443"), "")
+	assert.NoError(t, p.AssignNext(&svc3))
+	assert.Equal(t, "192.168.1.0", svc3.Status.LoadBalancer.Ingress[0].IP)
+	svc4 := service("svc4", ports("tcp/25"), "")
+	assert.NoError(t, p.AssignNext(&svc4))
+	assert.Equal(t, "192.168.1.1", svc4.Status.LoadBalancer.Ingress[0].IP)
+
+	// Port 80 is in use on both addresses
+	svc5 := service("svc5", ports("tcp/80"), "")
+	assert.Error(t, p.AssignNext(&svc5))
+
+	// Services with their own sharing key don't share with
+	// automatically-shared services
+	svc6 := service("svc6", ports("tcp/8080"), "sharing1")
+	assert.Error(t, p.AssignNext(&svc6))
+
+	// Pools without AutoShare don't share automatically
+	plain := mustLocalPool(t, "192.168.1.0/32")
+	assert.NoError(t, plain.AssignNext(&svc1))
+	assert.Error(t, plain.AssignNext(&svc3))
+}
+
+func TestNamespaceSharing(t *testing.T) {
+	p, err := NewLocalPool(localPoolTestLogger, purelbv1.ServiceGroupLocalSpec{
+		V4Pool: &purelbv1.ServiceGroupAddressPool{
+			Pool:   "192.168.1.0/31",
+			Subnet: "192.168.1.0/24",
+		},
+		Sharing: &purelbv1.ServiceGroupSharingSpec{
+			NamespaceGroups: [][]string{{"unit", "friend"}},
+		},
+	})
+	assert.NoError(t, err, "Pool instantiation failed")
+	ip := net.ParseIP("192.168.1.0")
+
+	svc1 := service("svc1", ports("tcp/80"), "sharing1")
+	assert.NoError(t, p.Assign(ip, &svc1))
+
+	// Same namespace: share
+	svc2 := service("svc2", ports("tcp/25"), "sharing1")
+	assert.NoError(t, p.available(ip, &svc2))
+
+	// Namespace in the same group: share
+	svc2.Namespace = "friend"
+	assert.NoError(t, p.available(ip, &svc2))
+
+	// Other namespace with the same key: blocked
+	svc2.Namespace = "stranger"
+	assert.IsType(t, &sharingBlockedError{}, p.available(ip, &svc2))
+	assert.Equal(t, "", p.SharingBlocked(&svc1))
+	assert.NoError(t, p.AssignNext(&svc2))
+	assert.Equal(t, "192.168.1.1", svc2.Status.LoadBalancer.Ingress[0].IP)
+	assert.Contains(t, p.SharingBlocked(&svc2), "unit/svc1")
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	v1 "k8s.io/api/core/v1"
+
+	"purelb.io/internal/netbox"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// errNotLeading is returned by NetboxAddressChanged when this
+// allocator can't act on the notification because it's on standby or
+// hasn't synced yet.
+var errNotLeading = errors.New("this allocator is not the leader")
+
+// NetboxWebhook returns an http.Handler that receives Netbox webhook
+// notifications and passes them to c. secret is the key that Netbox
+// uses to sign its notifications. Requests whose signatures don't
+// match are rejected. Standby allocators reply with 503 Service
+// Unavailable so Netbox will retry, hopefully with the leader.
+func NetboxWebhook(l log.Logger, secret []byte, c Controller) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		event, err := netbox.ParseWebhook(r, secret)
+		if err != nil {
+			l.Log("op", "netboxWebhook", "error", err)
+			if errors.Is(err, netbox.ErrBadSignature) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+			} else {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+
+		if err := c.NetboxAddressChanged(event); err != nil {
+			l.Log("op", "netboxWebhook", "error", err)
+			if errors.Is(err, errNotLeading) {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			} else {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// NetboxAddressChanged handles a notification from Netbox that one of
+// its addresses has changed. If the address belongs to one of our
+// services, and the change means that the service shouldn't use it
+// anymore, then we warn the user and, if the service's group allows
+// it, move the service to a new address. It returns errNotLeading if
+// this allocator can't act on the notification.
+func (c *controller) NetboxAddressChanged(event *netbox.WebhookEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.standby || !c.synced {
+		return errNotLeading
+	}
+	if event.Model != "ipaddress" {
+		return nil
+	}
+	ip, err := event.IP()
+	if err != nil {
+		return err
+	}
+
+	// If the address didn't come from one of our Netbox pools then we
+	// don't care about it
+	poolName := poolFor(c.ips.pools, ip)
+	group := c.ips.groups[poolName]
+	if group == nil || group.Spec.Netbox == nil {
+		return nil
+	}
+
+	reason := netboxChange(event, group.Spec.Netbox)
+	if reason == "" {
+		return nil
+	}
+
+	for _, svc := range c.client.Services() {
+		if svc.Annotations[purelbv1.PoolAnnotation] != poolName || !hasIngress(svc, ip) {
+			continue
+		}
+
+		nsName := namespacedName(svc)
+		c.logger.Log("op", "netboxWebhook", "service", nsName, "ip", ip, "reason", reason)
+		c.client.Errorf(svc, "AddressChanged", "Netbox reports that %s", reason)
+
+		if group.Spec.Netbox.Reallocate {
+			if c.reallocate == nil {
+				c.reallocate = map[string]string{}
+			}
+			c.reallocate[nsName] = reason
+			c.client.ResyncService(nsName)
+		}
+	}
+
+	return nil
+}
+
+// netboxChange returns a description of why event means that its
+// address can't be used anymore, or "" if the address is still OK.
+func netboxChange(event *netbox.WebhookEvent, spec *purelbv1.ServiceGroupNetboxSpec) string {
+	if event.Event == netbox.EventDeleted {
+		return fmt.Sprintf("address %s was deleted", event.Data.Address)
+	}
+	if event.Data.Status.Value != netbox.StatusActive {
+		return fmt.Sprintf("address %s has status %q", event.Data.Address, event.Data.Status.Value)
+	}
+	if tenant := event.TenantSlug(); tenant != spec.Tenant {
+		return fmt.Sprintf("address %s belongs to tenant %q", event.Data.Address, tenant)
+	}
+	return ""
+}
+
+// hasIngress returns true if ip is one of svc's ingress addresses.
+func hasIngress(svc *v1.Service, ip net.IP) bool {
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ip.Equal(net.ParseIP(ingress.IP)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 Acnodal Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package allocator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+
+	"purelb.io/internal/k8s"
+	"purelb.io/internal/netbox"
+	"purelb.io/internal/netbox/fake"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// netboxController returns a synced controller with one Netbox pool
+// called "netbox".
+func netboxController(t *testing.T, k *testK8S, reallocate bool) *controller {
+	l := log.NewNopLogger()
+	a := New(l)
+	a.client = k
+	c := &controller{
+		logger:    l,
+		ips:       a,
+		client:    k,
+		synced:    true,
+		isDefault: true,
+	}
+
+	spec := purelbv1.ServiceGroupNetboxSpec{URL: "url", Tenant: "tenant", Reallocate: reallocate}
+	nbp, err := NewNetboxPool(l, spec)
+	assert.Nil(t, err, "NewNetboxPool()")
+	nbp.netbox = fake.NewNetbox("base", "tenant", "token") // patch the pool with a fake Netbox client
+	a.pools = map[string]Pool{"netbox": *nbp}
+	a.groups = map[string]*purelbv1.ServiceGroup{
+		"netbox": serviceGroup("netbox", purelbv1.ServiceGroupSpec{Netbox: &spec}),
+	}
+
+	return c
+}
+
+func netboxEvent(event string, status string, tenant string) *netbox.WebhookEvent {
+	e := netbox.WebhookEvent{Event: event, Model: "ipaddress"}
+	e.Data.Address = "10.1.2.3/32"
+	e.Data.Status.Value = status
+	if tenant != "" {
+		e.Data.Tenant = &struct {
+			Slug string `json:"slug"`
+		}{Slug: tenant}
+	}
+	return &e
+}
+
+func TestNetboxAddressChanged(t *testing.T) {
+	k := &testK8S{t: t}
+	c := netboxController(t, k, true)
+
+	svc := service("svc1", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "netbox"
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "1.2.3.4"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil), "SetBalancer failed")
+	assert.Equal(t, "10.1.2.3", svc.Status.LoadBalancer.Ingress[0].IP, "svc1 got the wrong IP")
+	k.services = []*v1.Service{&svc}
+
+	// Changes that leave the address usable are ignored
+	assert.Nil(t, c.NetboxAddressChanged(netboxEvent("updated", "active", "tenant")), "NetboxAddressChanged() failed")
+	assert.False(t, k.loggedWarning, "harmless change logged a warning")
+	assert.Empty(t, k.resynced, "harmless change caused a resync")
+
+	// Deprecating the address warns and triggers a reallocation
+	assert.Nil(t, c.NetboxAddressChanged(netboxEvent("updated", "deprecated", "tenant")), "NetboxAddressChanged() failed")
+	assert.True(t, k.loggedWarning, "deprecated address didn't log a warning")
+	assert.Equal(t, []string{"unit/svc1"}, k.resynced, "deprecated address didn't cause a resync")
+	assert.Contains(t, c.reallocate, "unit/svc1", "service wasn't marked for reallocation")
+
+	// The next SetBalancer moves the service to a new address
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil), "SetBalancer failed")
+	assert.NotEmpty(t, svc.Status.LoadBalancer.Ingress, "svc1 wasn't reallocated")
+	assert.NotContains(t, c.reallocate, "unit/svc1", "service is still marked for reallocation")
+
+	// If the group doesn't allow reallocation then we only warn
+	k = &testK8S{t: t}
+	c = netboxController(t, k, false)
+	svc = service("svc1", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "netbox"
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "1.2.3.4"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil), "SetBalancer failed")
+	k.services = []*v1.Service{&svc}
+	assert.Nil(t, c.NetboxAddressChanged(netboxEvent("updated", "active", "someone-else")), "NetboxAddressChanged() failed")
+	assert.True(t, k.loggedWarning, "tenant change didn't log a warning")
+	assert.Empty(t, k.resynced, "warn-only group caused a resync")
+}
+
+func TestNetboxWebhookSignature(t *testing.T) {
+	k := &testK8S{t: t}
+	c := netboxController(t, k, true)
+	secret := []byte("secret")
+	handler := NetboxWebhook(log.NewNopLogger(), secret, c)
+	body := []byte(`{"event": "deleted", "model": "ipaddress", "data": {"address": "10.9.9.9/32"}}`)
+
+	mac := hmac.New(sha512.New, secret)
+	mac.Write(body)
+	good := hex.EncodeToString(mac.Sum(nil))
+
+	for _, test := range []struct {
+		desc      string
+		signature string
+		want      int
+	}{
+		{desc: "good signature", signature: good, want: http.StatusNoContent},
+		{desc: "no signature", signature: "", want: http.StatusForbidden},
+		{desc: "bad signature", signature: "abcd", want: http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set(netbox.SignatureHeader, test.signature)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, test.want, rec.Code, test.desc)
+	}
+
+	// Standbys ask Netbox to retry so the leader gets the notification
+	c.Standby()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set(netbox.SignatureHeader, good)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "standby accepted a notification")
+}
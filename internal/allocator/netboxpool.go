@@ -20,9 +20,11 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	"purelb.io/internal/netbox"
 	purelbv1 "purelb.io/pkg/apis/v1"
@@ -46,8 +48,20 @@ type NetboxPool struct {
 
 	// Map of the addresses that have been assigned.
 	addressesInUse map[string]map[string]bool // ip.String() -> svc name -> true
+
+	// prefix is the optional prefix whose utilization we report. If
+	// it's empty then we report the tenant's reserved and active
+	// addresses instead.
+	prefix       string
+	pollInterval time.Duration
+	capacity     *remoteCapacity
+	stopCh       chan struct{}
 }
 
+const (
+	defaultNetboxPollInterval = time.Minute
+)
+
 // NewNetboxPool initializes a new instance of NetboxPool. If error is
 // non-nil then the returned NetboxPool should not be used.
 func NewNetboxPool(log log.Logger, spec purelbv1.ServiceGroupNetboxSpec) (*NetboxPool, error) {
@@ -63,6 +77,21 @@ func NewNetboxPool(log log.Logger, spec purelbv1.ServiceGroupNetboxSpec) (*Netbo
 		return nil, fmt.Errorf("Netbox URL invalid")
 	}
 
+	// Validate the optional prefix
+	if spec.Prefix != "" {
+		if _, _, err := net.ParseCIDR(spec.Prefix); err != nil {
+			return nil, fmt.Errorf("Netbox prefix %q invalid", spec.Prefix)
+		}
+	}
+
+	pollInterval := defaultNetboxPollInterval
+	if spec.PollInterval != nil {
+		if spec.PollInterval.Duration <= 0 {
+			return nil, fmt.Errorf("Netbox pollInterval %s must be positive", spec.PollInterval.Duration)
+		}
+		pollInterval = spec.PollInterval.Duration
+	}
+
 	return &NetboxPool{
 		logger:         log,
 		url:            url.String(),
@@ -70,6 +99,10 @@ func NewNetboxPool(log log.Logger, spec purelbv1.ServiceGroupNetboxSpec) (*Netbo
 		netbox:         netbox.NewNetbox(url.String(), spec.Tenant, userToken),
 		services:       map[string][]net.IP{},
 		addressesInUse: map[string]map[string]bool{},
+		prefix:         spec.Prefix,
+		pollInterval:   pollInterval,
+		capacity:       &remoteCapacity{lastPoll: time.Now()},
+		stopCh:         make(chan struct{}),
 	}, nil
 }
 
@@ -124,10 +157,18 @@ func (p NetboxPool) Assign(ip net.IP, service *v1.Service) error {
 func (p NetboxPool) Release(service string) error {
 	ip, haveIp := p.services[service]
 	if !haveIp {
-		return fmt.Errorf("trying to release an IP from unknown service %s", service)
+		return fmt.Errorf("trying to release an IP from %w %s", errUnknownService, service)
 	}
-	delete(p.services, service)
+
+	// Give the address back to Netbox. If Netbox doesn't hear about it
+	// then we keep the address so we can try again.
 	ipstr := ip[0].String()
+	if err := p.netbox.Release(ipstr); err != nil {
+		p.logger.Log("op", "releaseNetbox", "service", service, "ip", ipstr, "error", err)
+		return err
+	}
+
+	delete(p.services, service)
 	delete(p.addressesInUse[ipstr], service)
 	if len(p.addressesInUse[ipstr]) == 0 {
 		delete(p.addressesInUse, ipstr)
@@ -136,15 +177,105 @@ func (p NetboxPool) Release(service string) error {
 }
 
 // InUse returns the count of addresses that currently have services
-// assigned.
+// assigned. Once we've polled Netbox this is the count that Netbox
+// reports, otherwise it's the count of addresses that we've
+// allocated.
 func (p NetboxPool) InUse() int {
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+
+	if p.capacity.polled {
+		return p.capacity.inUse
+	}
 	return len(p.addressesInUse)
 }
 
-// Size returns the total number of addresses in this pool if it's a
-// local pool, or 0 if it's a remote pool.
+// Size returns the total number of addresses in this pool as of the
+// most recent poll of Netbox, or 0 if we haven't been able to poll
+// Netbox yet.
 func (p NetboxPool) Size() uint64 {
-	return uint64(0)
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+
+	return p.capacity.size
+}
+
+// Start starts polling Netbox for this pool's capacity. It
+// implements the Poller interface.
+func (p NetboxPool) Start(name string) {
+	go wait.Until(func() { p.poll(name) }, p.pollInterval, p.stopCh)
+}
+
+// Stop stops polling Netbox. It implements the Poller interface.
+func (p NetboxPool) Stop() {
+	close(p.stopCh)
+}
+
+// poll refreshes this pool's capacity from Netbox and updates the
+// pool's metrics.
+func (p NetboxPool) poll(name string) {
+	if err := p.refresh(); err != nil {
+		p.logger.Log("op", "pollNetbox", "pool", name, "error", err)
+	} else {
+		poolCapacity.WithLabelValues(name).Set(float64(p.Size()))
+		poolActive.WithLabelValues(name).Set(float64(p.InUse()))
+	}
+
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+	poolPollAge.WithLabelValues(name).Set(time.Since(p.capacity.lastPoll).Seconds())
+}
+
+// refresh reads this pool's capacity from Netbox. If we have a
+// prefix then the capacity is the size of the prefix and the usage is
+// the number of addresses that Netbox has within it. If not, then the
+// capacity is our tenant's reserved and active addresses and the
+// usage is the active addresses.
+func (p NetboxPool) refresh() error {
+	var (
+		size  uint64
+		inUse int
+	)
+
+	if p.prefix != "" {
+		iprange, err := NewIPRange(p.prefix)
+		if err != nil {
+			return err
+		}
+		used, err := p.netbox.PrefixUsage(p.prefix)
+		if err != nil {
+			return err
+		}
+		size = iprange.Size()
+		inUse = used
+	} else {
+		reserved, err := p.netbox.Count("reserved")
+		if err != nil {
+			return err
+		}
+		active, err := p.netbox.Count("active")
+		if err != nil {
+			return err
+		}
+		size = uint64(reserved + active)
+		inUse = active
+	}
+
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+	p.capacity.polled = true
+	p.capacity.size = size
+	p.capacity.inUse = inUse
+	p.capacity.lastPoll = time.Now()
+
+	return nil
+}
+
+// CanShare indicates whether svc1 and svc2 can share an
+// address. This pool has no sharing rules of its own so they need the
+// same sharing key and ports that don't clash.
+func (p NetboxPool) CanShare(svc1 *v1.Service, svc2 *v1.Service) bool {
+	return canShareByKey(svc1, svc2)
 }
 
 // Overlaps indicates whether the other Pool overlaps with this one
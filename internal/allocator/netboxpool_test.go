@@ -45,3 +45,34 @@ func TestNetboxContains(t *testing.T) {
 	nbp.Release(nsName)
 	assert.False(t, nbp.Contains(assigned), "address should not have been contained in pool but was")
 }
+
+func TestNetboxCapacity(t *testing.T) {
+	svc1 := service("svc1", ports("tcp/80"), "sharing1")
+
+	nbp, err := NewNetboxPool(netboxPoolTestLogger, purelbv1.ServiceGroupNetboxSpec{URL: "url", Tenant: "tenant"})
+	assert.Nil(t, err, "NewNetboxPool()")
+	nbp.netbox = fake.NewNetbox("base", "tenant", "token") // patch the pool with a fake Netbox client
+
+	// Before we poll Netbox we don't know the size, but we know what
+	// we've allocated
+	assert.Nil(t, nbp.AssignNext(&svc1), "Netbox pool AssignNext() failed")
+	assert.Equal(t, uint64(0), nbp.Size(), "unpolled pool should have no size")
+	assert.Equal(t, 1, nbp.InUse(), "unpolled pool should report local usage")
+
+	// The fake Netbox has 5 reserved and 1 active address
+	assert.Nil(t, nbp.refresh(), "Netbox pool refresh() failed")
+	assert.Equal(t, uint64(6), nbp.Size(), "polled pool has wrong size")
+	assert.Equal(t, 1, nbp.InUse(), "polled pool has wrong usage")
+
+	// If we have a prefix then we report its size and usage
+	nbp, err = NewNetboxPool(netboxPoolTestLogger, purelbv1.ServiceGroupNetboxSpec{URL: "url", Tenant: "tenant", Prefix: "10.1.2.0/29"})
+	assert.Nil(t, err, "NewNetboxPool()")
+	nbp.netbox = fake.NewNetbox("base", "tenant", "token")
+	assert.Nil(t, nbp.refresh(), "Netbox pool refresh() failed")
+	assert.Equal(t, uint64(8), nbp.Size(), "polled pool has wrong size")
+	assert.Equal(t, 3, nbp.InUse(), "polled pool has wrong usage")
+
+	// Invalid prefixes are rejected
+	_, err = NewNetboxPool(netboxPoolTestLogger, purelbv1.ServiceGroupNetboxSpec{URL: "url", Tenant: "tenant", Prefix: "10.1.2.0"})
+	assert.Error(t, err, "NewNetboxPool() accepted an invalid prefix")
+}
@@ -0,0 +1,281 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/go-kit/kit/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// nodeLister lists the cluster's nodes. The k8s client lists them
+// from its node cache so it's cheap to call.
+type nodeLister interface {
+	Nodes(selector labels.Selector) ([]v1.Node, error)
+}
+
+// NodesPool is the IP address pool that gives services the addresses
+// of the cluster's nodes. Node addresses are always shared so each
+// service gets every selected node address on which its ports are
+// free.
+type NodesPool struct {
+	logger log.Logger
+
+	lister      nodeLister
+	selector    labels.Selector
+	addressType v1.NodeAddressType
+
+	// addresses caches the node addresses from the most recent node
+	// list. It's nil until we've listed the nodes.
+	addresses *[]net.IP
+
+	// services caches the addresses that we've assigned to each
+	// service. The key is the service's namespaced name.
+	services map[string][]net.IP
+
+	portsInUse map[string]map[Port]string // ip.String() -> Port -> svc
+}
+
+// NewNodesPool initializes a new instance of NodesPool. If error is
+// non-nil then the returned NodesPool should not be used.
+func NewNodesPool(log log.Logger, lister nodeLister, spec purelbv1.ServiceGroupNodesSpec) (*NodesPool, error) {
+	selector := labels.Everything()
+	if spec.NodeSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(spec.NodeSelector); err != nil {
+			return nil, fmt.Errorf("invalid nodeSelector: %s", err)
+		}
+	}
+
+	addressType := v1.NodeInternalIP
+	if spec.AddressType != "" {
+		addressType = v1.NodeAddressType(spec.AddressType)
+	}
+	if addressType != v1.NodeInternalIP && addressType != v1.NodeExternalIP {
+		return nil, fmt.Errorf("addressType %q must be %q or %q", addressType, v1.NodeInternalIP, v1.NodeExternalIP)
+	}
+
+	return &NodesPool{
+		logger:      log,
+		lister:      lister,
+		selector:    selector,
+		addressType: addressType,
+		addresses:   new([]net.IP),
+		services:    map[string][]net.IP{},
+		portsInUse:  map[string]map[Port]string{},
+	}, nil
+}
+
+func (p NodesPool) Notify(service *v1.Service) error {
+	nsName := namespacedName(service)
+
+	// After a restart we're notified of the existing services before
+	// we've allocated anything, so list the nodes to learn the pool's
+	// size
+	if *p.addresses == nil {
+		if err := p.refresh(); err != nil {
+			p.logger.Log("op", "notifyNode", "service", nsName, "error", err)
+		}
+	}
+	ports := Ports(service)
+
+	ips := []net.IP{}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		ip := net.ParseIP(ingress.IP)
+		if ip == nil {
+			return fmt.Errorf("Service %s has unparseable IP %s", nsName, ingress.IP)
+		}
+		ips = append(ips, ip)
+
+		if p.portsInUse[ip.String()] == nil {
+			p.portsInUse[ip.String()] = map[Port]string{}
+		}
+		for _, port := range ports {
+			p.portsInUse[ip.String()][port] = nsName
+		}
+	}
+	p.services[nsName] = ips
+
+	return nil
+}
+
+// AssignNext assigns service the addresses of the selected nodes on
+// which its ports are free.
+func (p NodesPool) AssignNext(service *v1.Service) error {
+	if err := p.refresh(); err != nil {
+		return err
+	}
+
+	families := map[v1.IPFamily]bool{}
+	for _, family := range service.Spec.IPFamilies {
+		families[family] = true
+	}
+
+	assigned := false
+	for _, ip := range *p.addresses {
+		family := v1.IPv4Protocol
+		if ip.To4() == nil {
+			family = v1.IPv6Protocol
+		}
+		if len(families) > 0 && !families[family] {
+			continue
+		}
+		if err := p.available(ip, service); err != nil {
+			p.logger.Log("op", "assignNode", "service", namespacedName(service), "ip", ip, "msg", err)
+			continue
+		}
+		addIngress(p.logger, service, ip)
+		assigned = true
+	}
+	if !assigned {
+		return fmt.Errorf("no node addresses with free ports for service %s", namespacedName(service))
+	}
+
+	// Update our internal allocation data structures
+	return p.Notify(service)
+}
+
+// Assign assigns a service to an IP, which must be the address of one
+// of the selected nodes.
+func (p NodesPool) Assign(ip net.IP, service *v1.Service) error {
+	if !p.Contains(ip) {
+		return fmt.Errorf("%s is not the address of a selected node", ip)
+	}
+	if err := p.available(ip, service); err != nil {
+		return err
+	}
+
+	addIngress(p.logger, service, ip)
+	return p.Notify(service)
+}
+
+// available determines whether service's ports are free on ip.
+func (p NodesPool) available(ip net.IP, service *v1.Service) error {
+	nsName := namespacedName(service)
+	for _, port := range Ports(service) {
+		if curSvc, ok := p.portsInUse[ip.String()][port]; ok && curSvc != nsName {
+			return fmt.Errorf("port %s on %q is already in use by %s", port, ip, curSvc)
+		}
+	}
+	return nil
+}
+
+// Release releases a service's node addresses.
+func (p NodesPool) Release(service string) error {
+	ips, has := p.services[service]
+	if !has {
+		return fmt.Errorf("trying to release an IP from %w %s", errUnknownService, service)
+	}
+	delete(p.services, service)
+
+	for _, ip := range ips {
+		ipstr := ip.String()
+		for port, svc := range p.portsInUse[ipstr] {
+			if svc == service {
+				delete(p.portsInUse[ipstr], port)
+			}
+		}
+		if len(p.portsInUse[ipstr]) == 0 {
+			delete(p.portsInUse, ipstr)
+		}
+	}
+	return nil
+}
+
+// InUse returns the count of node addresses that currently have
+// services assigned.
+func (p NodesPool) InUse() int {
+	return len(p.portsInUse)
+}
+
+// Size returns the number of node addresses as of the most recent
+// node list.
+func (p NodesPool) Size() uint64 {
+	return uint64(len(*p.addresses))
+}
+
+// CanShare indicates whether svc1 and svc2 can share a node
+// address. Node addresses are always shared so they can if their ports
+// don't clash, whatever their sharing keys.
+func (p NodesPool) CanShare(svc1 *v1.Service, svc2 *v1.Service) bool {
+	return !portsClash(svc1, svc2)
+}
+
+// Overlaps indicates whether the other Pool overlaps with this
+// one. Node addresses can't be allocated by other pools so this
+// always returns false.
+func (p NodesPool) Overlaps(other Pool) bool {
+	return false
+}
+
+// Contains indicates whether the provided net.IP is the address of
+// one of the selected nodes. If the address isn't in our copy of the
+// node addresses then we list the nodes again since they might have
+// changed, or we might not have listed them yet.
+func (p NodesPool) Contains(ip net.IP) bool {
+	if _, inUse := p.portsInUse[ip.String()]; inUse {
+		return true
+	}
+	if p.cached(ip) {
+		return true
+	}
+	if err := p.refresh(); err != nil {
+		p.logger.Log("op", "containsNode", "ip", ip, "error", err)
+		return false
+	}
+	return p.cached(ip)
+}
+
+// cached indicates whether ip is in the most recent node list.
+func (p NodesPool) cached(ip net.IP) bool {
+	for _, addr := range *p.addresses {
+		if addr.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh lists the selected nodes and caches their addresses.
+func (p NodesPool) refresh() error {
+	if p.lister == nil {
+		return fmt.Errorf("no k8s client, can't list nodes")
+	}
+	nodes, err := p.lister.Nodes(p.selector)
+	if err != nil {
+		return fmt.Errorf("listing nodes: %s", err)
+	}
+
+	addresses := []net.IP{}
+	for _, node := range nodes {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != p.addressType {
+				continue
+			}
+			if ip := net.ParseIP(addr.Address); ip != nil {
+				addresses = append(addresses, ip)
+			}
+		}
+	}
+	*p.addresses = addresses
+
+	return nil
+}
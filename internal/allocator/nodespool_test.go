@@ -0,0 +1,97 @@
+// Copyright 2021 Acnodal Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package allocator
+
+import (
+	"net"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func node(name string, edge bool, addrs ...v1.NodeAddress) v1.Node {
+	n := v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+	if edge {
+		n.Labels["edge"] = "true"
+	}
+	n.Status.Addresses = addrs
+	return n
+}
+
+func TestNodesPool(t *testing.T) {
+	k := &testK8S{t: t, nodes: []v1.Node{
+		node("n1", true, v1.NodeAddress{Type: v1.NodeInternalIP, Address: "10.0.0.1"}, v1.NodeAddress{Type: v1.NodeExternalIP, Address: "192.0.2.1"}),
+		node("n2", true, v1.NodeAddress{Type: v1.NodeInternalIP, Address: "10.0.0.2"}, v1.NodeAddress{Type: v1.NodeInternalIP, Address: "fd00::2"}),
+		node("n3", false, v1.NodeAddress{Type: v1.NodeInternalIP, Address: "10.0.0.3"}),
+	}}
+	spec := purelbv1.ServiceGroupNodesSpec{NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"edge": "true"}}}
+
+	p, err := NewNodesPool(log.NewNopLogger(), k, spec)
+	assert.Nil(t, err, "NewNodesPool()")
+
+	// A single-stack service gets the selected nodes' addresses of its
+	// family
+	svc1 := service("svc1", ports("tcp/80"), "")
+	svc1.Spec.IPFamilies = []v1.IPFamily{v1.IPv4Protocol}
+	assert.Nil(t, p.AssignNext(&svc1), "AssignNext() failed")
+	assert.Equal(t, []v1.LoadBalancerIngress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}}, svc1.Status.LoadBalancer.Ingress)
+	assert.Equal(t, uint64(3), p.Size())
+	assert.True(t, p.Contains(net.ParseIP("fd00::2")), "pool should contain fd00::2")
+	assert.False(t, p.Contains(net.ParseIP("10.0.0.3")), "pool shouldn't contain unselected node's address")
+
+	// Services can share node addresses if their ports don't clash
+	svc2 := service("svc2", ports("tcp/443"), "")
+	assert.Nil(t, p.AssignNext(&svc2), "AssignNext() failed")
+	assert.Equal(t, 3, len(svc2.Status.LoadBalancer.Ingress))
+
+	// ...but not if they do
+	svc3 := service("svc3", ports("tcp/80"), "")
+	svc3.Spec.IPFamilies = []v1.IPFamily{v1.IPv4Protocol}
+	assert.Error(t, p.AssignNext(&svc3), "AssignNext() with clashing ports succeeded")
+
+	// Once the port is released it's available again
+	assert.Nil(t, p.Release(namespacedName(&svc1)), "Release() failed")
+	assert.Nil(t, p.AssignNext(&svc3), "AssignNext() failed")
+	assert.Equal(t, 2, len(svc3.Status.LoadBalancer.Ingress))
+
+	// ExternalIP addresses can be used instead
+	spec.AddressType = "ExternalIP"
+	p, err = NewNodesPool(log.NewNopLogger(), k, spec)
+	assert.Nil(t, err, "NewNodesPool()")
+	svc4 := service("svc4", ports("tcp/80"), "")
+	assert.Nil(t, p.AssignNext(&svc4), "AssignNext() failed")
+	assert.Equal(t, []v1.LoadBalancerIngress{{IP: "192.0.2.1"}}, svc4.Status.LoadBalancer.Ingress)
+
+	// After a restart the pool knows its nodes before it allocates
+	// anything, so services can ask for node addresses and existing
+	// services count towards the pool's size
+	spec.AddressType = ""
+	p, err = NewNodesPool(log.NewNopLogger(), k, spec)
+	assert.Nil(t, err, "NewNodesPool()")
+	assert.Nil(t, p.Notify(&svc2), "Notify() failed")
+	assert.Equal(t, uint64(3), p.Size())
+	p, err = NewNodesPool(log.NewNopLogger(), k, spec)
+	assert.Nil(t, err, "NewNodesPool()")
+	assert.True(t, p.Contains(net.ParseIP("10.0.0.2")), "pool should contain 10.0.0.2 before the first allocation")
+	svc5 := service("svc5", ports("tcp/80"), "")
+	assert.Nil(t, p.Assign(net.ParseIP("10.0.0.2"), &svc5), "Assign() failed")
+
+	spec.AddressType = "Hostname"
+	_, err = NewNodesPool(log.NewNopLogger(), k, spec)
+	assert.Error(t, err, "NewNodesPool() accepted a bad addressType")
+}
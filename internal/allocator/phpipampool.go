@@ -0,0 +1,265 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"purelb.io/internal/phpipam"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// PhpIPAMPool is the IP address pool that requests IP addresses from
+// a subnet in a phpIPAM system.
+type PhpIPAMPool struct {
+	logger log.Logger
+
+	subnetID int
+	phpipam  phpipam.PhpIPAM
+
+	// services caches the addresses that we've allocated to a specific
+	// service. It's used so we can release addresses when we're given
+	// only the service name. The key is the service's namespaced name,
+	// and the value is an array of the addresses assigned to that
+	// service.
+	services map[string][]net.IP
+
+	// Map of the addresses that have been assigned.
+	addressesInUse map[string]string // ip.String() -> svc name
+
+	pollInterval time.Duration
+	capacity     *remoteCapacity
+	stopCh       chan struct{}
+}
+
+const (
+	defaultPhpIPAMPollInterval = time.Minute
+)
+
+// NewPhpIPAMPool initializes a new instance of PhpIPAMPool. If error
+// is non-nil then the returned PhpIPAMPool should not be used.
+func NewPhpIPAMPool(log log.Logger, spec purelbv1.ServiceGroupPhpIPAMSpec) (*PhpIPAMPool, error) {
+	// Make sure that we've got credentials for phpIPAM
+	token, ok := os.LookupEnv("PHPIPAM_TOKEN")
+	if !ok {
+		return nil, fmt.Errorf("PHPIPAM_TOKEN not set, can't connect to phpIPAM")
+	}
+
+	// Validate the url from the service group
+	url, err := url.Parse(spec.URL)
+	if err != nil || url.Scheme == "" || url.Host == "" {
+		return nil, fmt.Errorf("phpIPAM URL %q invalid", spec.URL)
+	}
+
+	if spec.SubnetID <= 0 {
+		return nil, fmt.Errorf("phpIPAM subnetID %d invalid", spec.SubnetID)
+	}
+
+	pollInterval := defaultPhpIPAMPollInterval
+	if spec.PollInterval != nil {
+		if spec.PollInterval.Duration <= 0 {
+			return nil, fmt.Errorf("phpIPAM pollInterval %s must be positive", spec.PollInterval.Duration)
+		}
+		pollInterval = spec.PollInterval.Duration
+	}
+
+	return &PhpIPAMPool{
+		logger:         log,
+		subnetID:       spec.SubnetID,
+		phpipam:        phpipam.NewPhpIPAM(url.String(), token),
+		services:       map[string][]net.IP{},
+		addressesInUse: map[string]string{},
+		pollInterval:   pollInterval,
+		capacity:       &remoteCapacity{lastPoll: time.Now()},
+		stopCh:         make(chan struct{}),
+	}, nil
+}
+
+func (p PhpIPAMPool) Notify(service *v1.Service) error {
+	nsName := namespacedName(service)
+
+	ips := []net.IP{}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		ipstr := ingress.IP
+		ip := net.ParseIP(ipstr)
+		if ip == nil {
+			return fmt.Errorf("Service %s has unparseable IP %s", nsName, ipstr)
+		}
+		ips = append(ips, ip)
+	}
+
+	for _, ip := range ips {
+		p.addressesInUse[ip.String()] = nsName
+	}
+	p.services[nsName] = ips
+
+	return nil
+}
+
+// AssignNext asks phpIPAM for the first free address in our subnet
+// and assigns it to service. The address is tagged with the service's
+// namespaced name.
+func (p PhpIPAMPool) AssignNext(service *v1.Service) error {
+	nsName := namespacedName(service)
+	addr, err := p.phpipam.FirstFree(p.subnetID, service.Name+"."+service.Namespace, nsName)
+	if err != nil {
+		return fmt.Errorf("no available IPs in subnet %d: %s", p.subnetID, err)
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		if err := p.phpipam.Release(p.subnetID, addr); err != nil {
+			p.logger.Log("op", "releasePhpIPAM", "service", nsName, "ip", addr, "error", err)
+		}
+		return fmt.Errorf("phpIPAM returned unparseable IP %q", addr)
+	}
+
+	return p.Assign(ip, service)
+}
+
+// Assign assigns a service to an IP.
+func (p PhpIPAMPool) Assign(ip net.IP, service *v1.Service) error {
+	// we have an IP selected somehow, so program the data plane
+	addIngress(p.logger, service, ip)
+
+	// Update our internal allocation data structures
+	return p.Notify(service)
+}
+
+// Release releases a service's addresses, both in our cache and in
+// phpIPAM.
+func (p PhpIPAMPool) Release(service string) error {
+	ips, haveIp := p.services[service]
+	if !haveIp {
+		return fmt.Errorf("trying to release an IP from %w %s", errUnknownService, service)
+	}
+
+	// We keep the addresses that phpIPAM couldn't release so we can
+	// try again.
+	kept := []net.IP{}
+	for _, ip := range ips {
+		if err := p.phpipam.Release(p.subnetID, ip.String()); err != nil {
+			p.logger.Log("op", "releasePhpIPAM", "service", service, "ip", ip, "error", err)
+			kept = append(kept, ip)
+			continue
+		}
+		delete(p.addressesInUse, ip.String())
+	}
+	if len(kept) > 0 {
+		p.services[service] = kept
+		return fmt.Errorf("phpIPAM didn't release %d of %s's addresses", len(kept), service)
+	}
+	delete(p.services, service)
+
+	return nil
+}
+
+// InUse returns the count of addresses that currently have services
+// assigned. Once we've polled phpIPAM this is the subnet's usage,
+// otherwise it's the count of addresses that we've allocated.
+func (p PhpIPAMPool) InUse() int {
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+
+	if p.capacity.polled {
+		return p.capacity.inUse
+	}
+	return len(p.addressesInUse)
+}
+
+// Size returns the number of usable addresses in the subnet as of the
+// most recent poll of phpIPAM, or 0 if we haven't been able to poll
+// it yet.
+func (p PhpIPAMPool) Size() uint64 {
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+
+	return p.capacity.size
+}
+
+// Start starts polling phpIPAM for the subnet's usage. It implements
+// the Poller interface.
+func (p PhpIPAMPool) Start(name string) {
+	go wait.Until(func() { p.poll(name) }, p.pollInterval, p.stopCh)
+}
+
+// Stop stops polling phpIPAM. It implements the Poller interface.
+func (p PhpIPAMPool) Stop() {
+	close(p.stopCh)
+}
+
+// poll refreshes this pool's capacity from phpIPAM and updates the
+// pool's metrics.
+func (p PhpIPAMPool) poll(name string) {
+	if err := p.refresh(); err != nil {
+		p.logger.Log("op", "pollPhpIPAM", "pool", name, "error", err)
+	} else {
+		poolCapacity.WithLabelValues(name).Set(float64(p.Size()))
+		poolActive.WithLabelValues(name).Set(float64(p.InUse()))
+	}
+
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+	poolPollAge.WithLabelValues(name).Set(time.Since(p.capacity.lastPoll).Seconds())
+}
+
+// refresh reads the subnet's usage from phpIPAM.
+func (p PhpIPAMPool) refresh() error {
+	usage, err := p.phpipam.Usage(p.subnetID)
+	if err != nil {
+		return err
+	}
+
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+	p.capacity.polled = true
+	p.capacity.size = usage.MaxHosts
+	p.capacity.inUse = int(usage.Used)
+	p.capacity.lastPoll = time.Now()
+
+	return nil
+}
+
+// CanShare indicates whether svc1 and svc2 can share an
+// address. This pool has no sharing rules of its own so they need the
+// same sharing key and ports that don't clash.
+func (p PhpIPAMPool) CanShare(svc1 *v1.Service, svc2 *v1.Service) bool {
+	return canShareByKey(svc1, svc2)
+}
+
+// Overlaps indicates whether the other Pool overlaps with this one
+// (i.e., has any addresses in common).  It returns true if there are
+// any common addresses and false if there aren't. This implementation
+// always returns false since the pool is managed by a remote system.
+func (p PhpIPAMPool) Overlaps(other Pool) bool {
+	return false
+}
+
+// Contains indicates whether the provided net.IP represents an
+// address within this Pool.  It returns true if so, false
+// otherwise. In this case the pool is owned by a remote system so
+// "address within this Pool" means that the address has been
+// allocated by a previous call to AssignNext().
+func (p PhpIPAMPool) Contains(ip net.IP) bool {
+	_, allocated := p.addressesInUse[ip.String()]
+	return allocated
+}
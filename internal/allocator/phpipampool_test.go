@@ -0,0 +1,59 @@
+// Copyright 2021 Acnodal Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package allocator
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"purelb.io/internal/phpipam/fake"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func TestPhpIPAMPool(t *testing.T) {
+	os.Setenv("PHPIPAM_TOKEN", "token")
+	spec := purelbv1.ServiceGroupPhpIPAMSpec{URL: "https://ipam/api/purelb/", SubnetID: 7}
+
+	p, err := NewPhpIPAMPool(log.NewNopLogger(), spec)
+	assert.Nil(t, err, "NewPhpIPAMPool()")
+	ipam := fake.NewPhpIPAM("10.1.2.3", "10.1.2.4")
+	p.phpipam = ipam // patch the pool with a fake phpIPAM client
+
+	// Addresses are tagged with the service's name
+	svc1 := service("svc1", ports("tcp/80"), "")
+	assert.Nil(t, p.AssignNext(&svc1), "AssignNext() failed")
+	assert.Equal(t, "10.1.2.3", svc1.Status.LoadBalancer.Ingress[0].IP)
+	assert.Equal(t, fake.Address{Hostname: "svc1.unit", Description: "unit/svc1"}, ipam.Records["10.1.2.3"])
+	assert.True(t, p.Contains(net.ParseIP("10.1.2.3")), "pool should contain 10.1.2.3")
+
+	// Before we poll we report only what we've allocated
+	assert.Equal(t, uint64(0), p.Size(), "unpolled pool should have no size")
+	assert.Equal(t, 1, p.InUse(), "unpolled pool should report local usage")
+	assert.Nil(t, p.refresh(), "refresh() failed")
+	assert.Equal(t, uint64(2), p.Size(), "polled pool has wrong size")
+	assert.Equal(t, 1, p.InUse(), "polled pool has wrong usage")
+
+	// Releasing frees the address in phpIPAM
+	assert.Nil(t, p.Release(namespacedName(&svc1)), "Release() failed")
+	assert.NotContains(t, ipam.Records, "10.1.2.3", "address wasn't released")
+	assert.False(t, p.Contains(net.ParseIP("10.1.2.3")), "pool should not contain 10.1.2.3")
+
+	// Subnet IDs must be positive
+	spec.SubnetID = 0
+	_, err = NewPhpIPAMPool(log.NewNopLogger(), spec)
+	assert.Error(t, err, "NewPhpIPAMPool() accepted an invalid subnetID")
+}
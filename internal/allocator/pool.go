@@ -19,10 +19,13 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	v1 "k8s.io/api/core/v1"
 
+	"purelb.io/internal/k8s"
 	purelbv1 "purelb.io/pkg/apis/v1"
 )
 
@@ -37,6 +40,15 @@ func (p Port) String() string {
 	return fmt.Sprintf("%s/%d", p.Proto, p.Port)
 }
 
+// errUnknownService is returned by Pool.Release when the pool has no
+// addresses for the service.
+var errUnknownService = errors.New("unknown service")
+
+// errAllocationPending is returned by Pool.AssignNext when the pool
+// has started to allocate an address but doesn't have it yet. The
+// pool resyncs the service when the address is ready.
+var errAllocationPending = errors.New("allocation pending")
+
 type Key struct {
 	Sharing string
 }
@@ -49,11 +61,43 @@ type Pool interface {
 	Notify(*v1.Service) error
 	AssignNext(*v1.Service) error
 	Assign(net.IP, *v1.Service) error
+	// Release releases the service's addresses. If the pool gets its
+	// addresses from an external IPAM system then Release returns an
+	// error if that system didn't confirm the release, and the pool
+	// keeps the addresses so Release can be retried.
 	Release(string) error
 	InUse() int
 	Overlaps(Pool) bool
 	Contains(net.IP) bool // FIXME: I'm not sure that we need this. It might be the case that we can always rely on the service's pool annotation to find to which pool an address belongs
 	Size() uint64
+	// CanShare indicates whether the pool's rules let two of its
+	// services share an address.
+	CanShare(*v1.Service, *v1.Service) bool
+}
+
+// Poller is implemented by pools that need to periodically refresh
+// their state from an external system. Start starts the refresh and
+// Stop stops it. name is the name of the pool, which is used to label
+// the pool's metrics.
+type Poller interface {
+	Start(name string)
+	Stop()
+}
+
+// remoteCapacity caches the most recent capacity information that a
+// Poller has read from a remote IPAM system. It's updated by the
+// poller goroutine and read by the allocator so it needs a lock.
+type remoteCapacity struct {
+	sync.Mutex
+
+	// polled is true if at least one poll has succeeded.
+	polled bool
+	size   uint64
+	inUse  int
+
+	// lastPoll is the time of the most recent successful poll, or the
+	// time that the pool was created if no poll has succeeded.
+	lastPoll time.Time
 }
 
 func sharingOK(existing, new *Key) error {
@@ -69,7 +113,31 @@ func sharingOK(existing, new *Key) error {
 	return nil
 }
 
-func parsePool(log log.Logger, name string, group purelbv1.ServiceGroupSpec) (Pool, error) {
+// canShareByKey indicates whether svc1 and svc2 can share an address
+// according to the default rules: they need the same sharing key and
+// ports that don't clash. Pools that don't have their own sharing
+// rules use it.
+func canShareByKey(svc1 *v1.Service, svc2 *v1.Service) bool {
+	if err := sharingOK(&Key{Sharing: SharingKey(svc1)}, &Key{Sharing: SharingKey(svc2)}); err != nil {
+		return false
+	}
+	return !portsClash(svc1, svc2)
+}
+
+// portsClash indicates whether svc1 and svc2 use any of the same
+// ports.
+func portsClash(svc1 *v1.Service, svc2 *v1.Service) bool {
+	for _, port1 := range Ports(svc1) {
+		for _, port2 := range Ports(svc2) {
+			if port1 == port2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parsePool(log log.Logger, client k8s.ServiceEvent, name string, group purelbv1.ServiceGroupSpec) (Pool, error) {
 	if group.Local != nil {
 		ret, err := NewLocalPool(log, *group.Local)
 		if err != nil {
@@ -82,7 +150,37 @@ func parsePool(log log.Logger, name string, group purelbv1.ServiceGroupSpec) (Po
 			return nil, err
 		}
 		return *ret, nil
+	} else if group.Infoblox != nil {
+		ret, err := NewInfobloxPool(log, *group.Infoblox)
+		if err != nil {
+			return nil, err
+		}
+		return *ret, nil
+	} else if group.PhpIPAM != nil {
+		ret, err := NewPhpIPAMPool(log, *group.PhpIPAM)
+		if err != nil {
+			return nil, err
+		}
+		return *ret, nil
+	} else if group.DHCP != nil {
+		ret, err := NewDHCPPool(log, client, *group.DHCP)
+		if err != nil {
+			return nil, err
+		}
+		return *ret, nil
+	} else if group.Nodes != nil {
+		ret, err := NewNodesPool(log, client, *group.Nodes)
+		if err != nil {
+			return nil, err
+		}
+		return *ret, nil
+	} else if group.Webhook != nil {
+		ret, err := NewWebhookPool(log, name, *group.Webhook)
+		if err != nil {
+			return nil, err
+		}
+		return *ret, nil
 	}
 
-	return nil, fmt.Errorf("Pool is not local or Netbox")
+	return nil, fmt.Errorf("Pool is not local, Netbox, Infoblox, phpIPAM, DHCP, nodes, or webhook")
 }
@@ -0,0 +1,135 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"fmt"
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// holding records how many addresses a service holds and from which
+// pool.
+type holding struct {
+	namespace string
+	pool      string
+	addresses int
+}
+
+// SetQuotas updates the set of quotas that the allocator enforces.
+func (a *Allocator) SetQuotas(quotas []*purelbv1.PureLBQuota) {
+	a.quotas = quotas
+
+	// Start from the status that's in the cluster so we only write
+	// the quotas whose status has changed
+	a.quotaStatus = map[string]purelbv1.PureLBQuotaStatus{}
+	for _, quota := range quotas {
+		a.quotaStatus[quota.Namespace+"/"+quota.Name] = quota.Status
+	}
+
+	a.reportQuotas()
+}
+
+// hold records the addresses that svc holds.
+func (a *Allocator) hold(svc *v1.Service, poolName string) {
+	a.holdings[namespacedName(svc)] = holding{
+		namespace: svc.Namespace,
+		pool:      poolName,
+		addresses: len(svc.Status.LoadBalancer.Ingress),
+	}
+}
+
+// usage returns the number of addresses that the services in
+// namespace hold, both in total and per pool.
+func (a *Allocator) usage(namespace string) (int, map[string]int) {
+	total := 0
+	pools := map[string]int{}
+	for _, held := range a.holdings {
+		if held.namespace == namespace {
+			total += held.addresses
+			pools[held.pool] += held.addresses
+		}
+	}
+	return total, pools
+}
+
+// checkQuotas returns an error if svc holding count addresses from
+// the pool named poolName would take svc's namespace over any of its
+// quotas.
+func (a *Allocator) checkQuotas(svc *v1.Service, poolName string, count int) error {
+	// Count the namespace's usage as if svc already held its addresses
+	held := a.holdings[namespacedName(svc)]
+	a.holdings[namespacedName(svc)] = holding{namespace: svc.Namespace, pool: poolName, addresses: count}
+	total, pools := a.usage(svc.Namespace)
+	if held.addresses > 0 {
+		a.holdings[namespacedName(svc)] = held
+	} else {
+		delete(a.holdings, namespacedName(svc))
+	}
+
+	for _, quota := range a.quotas {
+		if quota.Namespace != svc.Namespace {
+			continue
+		}
+		if limit := quota.Spec.Addresses; limit != nil && total > *limit {
+			return fmt.Errorf("namespace %s would hold %d addresses but quota %s allows %d", svc.Namespace, total, quota.Name, *limit)
+		}
+		if limit, has := quota.Spec.Groups[poolName]; has && pools[poolName] > limit {
+			return fmt.Errorf("namespace %s would hold %d addresses from group %s but quota %s allows %d", svc.Namespace, pools[poolName], poolName, quota.Name, limit)
+		}
+	}
+	return nil
+}
+
+// reportQuotas writes the current usage to the status of each quota
+// whose usage has changed since we last wrote it.
+func (a *Allocator) reportQuotas() {
+	for _, quota := range a.quotas {
+		total, pools := a.usage(quota.Namespace)
+
+		status := purelbv1.PureLBQuotaStatus{
+			Used:  total,
+			Limit: quota.Spec.Addresses,
+		}
+		for pool, used := range pools {
+			if status.Groups == nil {
+				status.Groups = map[string]purelbv1.PureLBQuotaUsage{}
+			}
+			status.Groups[pool] = purelbv1.PureLBQuotaUsage{Used: used}
+		}
+		for pool, limit := range quota.Spec.Groups {
+			if status.Groups == nil {
+				status.Groups = map[string]purelbv1.PureLBQuotaUsage{}
+			}
+			limit := limit
+			status.Groups[pool] = purelbv1.PureLBQuotaUsage{Used: pools[pool], Limit: &limit}
+		}
+
+		key := quota.Namespace + "/" + quota.Name
+		if reflect.DeepEqual(status, a.quotaStatus[key]) {
+			continue
+		}
+		updated := quota.DeepCopy()
+		updated.Status = status
+		if err := a.client.UpdateQuotaStatus(updated); err != nil {
+			a.logger.Log("op", "reportQuota", "quota", key, "error", err)
+			continue
+		}
+		a.quotaStatus[key] = status
+	}
+}
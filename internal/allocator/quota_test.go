@@ -0,0 +1,106 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"purelb.io/internal/dhcp"
+	"purelb.io/internal/dhcp/fake"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func TestQuotas(t *testing.T) {
+	k := &testK8S{t: t}
+	alloc := New(allocatorTestLogger)
+	alloc.SetClient(k)
+	alloc.pools = map[string]Pool{
+		"default": mustLocalPool(t, "1.2.3.0/28"),
+		"other":   mustLocalPool(t, "1.2.4.0/28"),
+	}
+	total := 3
+	alloc.SetQuotas([]*purelbv1.PureLBQuota{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "unit", Name: "limits"},
+		Spec: purelbv1.PureLBQuotaSpec{
+			Addresses: &total,
+			Groups:    map[string]int{"default": 2},
+		},
+	}})
+	assert.Equal(t, 0, k.quotas["unit/limits"].Used)
+	assert.Equal(t, 2, *k.quotas["unit/limits"].Groups["default"].Limit)
+
+	// Two addresses from "default" are OK but the third isn't
+	for _, name := range []string{"s1", "s2"} {
+		svc := service(name, ports("tcp/80"), "")
+		_, err := alloc.AllocateAnyIP(&svc)
+		assert.NoError(t, err)
+	}
+	svc := service("s3", ports("tcp/80"), "")
+	_, err := alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "allocation over the group quota should have failed")
+	assert.Empty(t, svc.Status.LoadBalancer.Ingress)
+	assert.Equal(t, 2, alloc.pools["default"].InUse(), "failed allocation wasn't released")
+
+	// One more from "other" is OK, which hits the total limit
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "other"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	svc = service("s4", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "other"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "allocation over the total quota should have failed")
+	assert.Equal(t, 3, k.quotas["unit/limits"].Used)
+	assert.Equal(t, 1, k.quotas["unit/limits"].Groups["other"].Used)
+
+	// Other namespaces aren't limited
+	svc.Namespace = "other"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+
+	// Releasing an address makes room
+	assert.NoError(t, alloc.Unassign("unit/s1"))
+	assert.Equal(t, 2, k.quotas["unit/limits"].Used)
+	svc = service("s5", ports("tcp/80"), "")
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+
+	// Remote pools aren't asked for addresses that the namespace has
+	// no room for
+	server := fake.NewClient("192.168.1.100")
+	newDHCPClient = func(string) (dhcp.Client, error) { return server, nil }
+	defer func() { newDHCPClient = dhcp.NewClient }()
+	dhcpPool, err := NewDHCPPool(allocatorTestLogger, k, purelbv1.ServiceGroupDHCPSpec{Interface: "eth0"})
+	assert.NoError(t, err)
+	alloc.pools["dhcp"] = *dhcpPool
+	svc = service("s6", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "dhcp"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "allocation over the total quota should have failed")
+	assert.Empty(t, dhcpPool.leases.acquiring, "pool was asked for an address over the quota")
+
+	// Services that get several addresses are checked once they have
+	// them
+	assert.NoError(t, alloc.Unassign("unit/s2"))
+	svc = service("s7", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.AddressCountAnnotation] = "2"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "allocation over the group quota should have failed")
+	assert.Empty(t, svc.Status.LoadBalancer.Ingress)
+	assert.Equal(t, 1, alloc.pools["default"].InUse(), "failed allocation wasn't released")
+	assert.Equal(t, 2, k.quotas["unit/limits"].Used)
+}
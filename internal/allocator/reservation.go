@@ -0,0 +1,105 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"time"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// SetReservations updates the set of addresses that the local pools
+// set aside for particular namespaces and services. Reservations that
+// we can't honor get Warning events so their owners can tell.
+func (a *Allocator) SetReservations(reservations []*purelbv1.AddressReservation) {
+	for _, pool := range a.pools {
+		if local, ok := pool.(LocalPool); ok {
+			local.ClearReservations()
+		}
+	}
+	a.restick()
+
+	// If reservations conflict then the oldest one wins
+	sorted := append([]*purelbv1.AddressReservation{}, reservations...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].CreationTimestamp.Equal(&sorted[j].CreationTimestamp) {
+			return sorted[i].CreationTimestamp.Before(&sorted[j].CreationTimestamp)
+		}
+		return sorted[i].Namespace+"/"+sorted[i].Name < sorted[j].Namespace+"/"+sorted[j].Name
+	})
+
+	for _, resv := range sorted {
+		key := resv.Namespace + "/" + resv.Name
+
+		local, ok := a.pools[resv.Spec.ServiceGroup].(LocalPool)
+		if !ok {
+			a.logger.Log("op", "setReservations", "reservation", key, "group", resv.Spec.ServiceGroup, "error", "group not found or not local")
+			a.client.Errorf(resv, "InvalidReservation", "Group %q doesn't exist or isn't a local group", resv.Spec.ServiceGroup)
+			continue
+		}
+
+		ip := net.ParseIP(resv.Spec.Address)
+		if ip == nil {
+			a.logger.Log("op", "setReservations", "reservation", key, "error", "invalid address "+resv.Spec.Address)
+			a.client.Errorf(resv, "InvalidReservation", "Invalid address %q", resv.Spec.Address)
+			continue
+		}
+
+		expires := time.Time{}
+		if resv.Spec.Expires != nil {
+			expires = resv.Spec.Expires.Time
+		}
+
+		if err := local.Reserve(ip, resv.Namespace, resv.Spec.Service, expires); err != nil {
+			a.logger.Log("op", "setReservations", "reservation", key, "error", err)
+			a.client.Errorf(resv, "InvalidReservation", "Can't reserve %s: %s", ip, err)
+		}
+	}
+
+	a.reportGroups()
+}
+
+// reportGroups writes the reservation counts and sticky addresses to
+// the status of each local group whose status has changed since we
+// last wrote it.
+func (a *Allocator) reportGroups() {
+	for name, group := range a.groups {
+		local, ok := a.pools[name].(LocalPool)
+		if !ok {
+			continue
+		}
+
+		status := purelbv1.ServiceGroupStatus{}
+		status.Reserved, status.ReservedInUse = local.ReservedCounts()
+		if conflicts := local.StaticConflicts(); len(conflicts) > 0 {
+			status.Conflicts = conflicts
+		}
+		status.Sticky = a.stickyStatus(name)
+
+		if reflect.DeepEqual(status, a.groupStatus[name]) {
+			continue
+		}
+		updated := group.DeepCopy()
+		updated.Status = status
+		if err := a.client.UpdateGroupStatus(updated); err != nil {
+			a.logger.Log("op", "reportGroup", "group", name, "error", err)
+			continue
+		}
+		a.groupStatus[name] = status
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func TestReservations(t *testing.T) {
+	k := &testK8S{t: t}
+	alloc := New(allocatorTestLogger)
+	alloc.SetClient(k)
+	alloc.pools = map[string]Pool{
+		"default": mustLocalPool(t, "1.2.3.0/30"),
+	}
+	alloc.groups = map[string]*purelbv1.ServiceGroup{
+		"default": {ObjectMeta: metav1.ObjectMeta{Namespace: "purelb", Name: "default"}},
+	}
+	expired := metav1.NewTime(time.Now().Add(-time.Minute))
+	alloc.SetReservations([]*purelbv1.AddressReservation{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "unit", Name: "web"},
+		Spec:       purelbv1.AddressReservationSpec{ServiceGroup: "default", Address: "1.2.3.1", Service: "web"},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "any"},
+		Spec:       purelbv1.AddressReservationSpec{ServiceGroup: "default", Address: "1.2.3.2"},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "old"},
+		Spec:       purelbv1.AddressReservationSpec{ServiceGroup: "default", Address: "1.2.3.3", Expires: &expired},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Namespace: "unit", Name: "outside"},
+		Spec:       purelbv1.AddressReservationSpec{ServiceGroup: "default", Address: "1.2.4.1"},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Namespace: "unit", Name: "dup", CreationTimestamp: metav1.NewTime(time.Now())},
+		Spec:       purelbv1.AddressReservationSpec{ServiceGroup: "default", Address: "1.2.3.1"},
+	}})
+	assert.Equal(t, 2, k.groups["default"].Reserved)
+	assert.Equal(t, 0, k.groups["default"].ReservedInUse)
+
+	// The owners of the reservations that we can't honor are told
+	// why, and newer reservations don't take over older ones
+	assert.ElementsMatch(t, []string{
+		"InvalidReservation: Can't reserve 1.2.4.1: 1.2.4.1 is not in the pool",
+		"InvalidReservation: Can't reserve 1.2.3.1: 1.2.3.1 is already reserved for unit/web",
+	}, k.warnings)
+
+	// Dynamic allocation skips the reserved addresses, but not the
+	// expired one
+	for _, name := range []string{"s1", "s2"} {
+		svc := service(name, ports("tcp/80"), "")
+		_, err := alloc.AllocateAnyIP(&svc)
+		assert.NoError(t, err)
+		assert.NotEqual(t, "1.2.3.1", svc.Status.LoadBalancer.Ingress[0].IP)
+		assert.NotEqual(t, "1.2.3.2", svc.Status.LoadBalancer.Ingress[0].IP)
+	}
+	svc := service("s3", ports("tcp/80"), "")
+	_, err := alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "reserved addresses should not be allocated dynamically")
+
+	// Services in other namespaces can't ask for reserved addresses
+	svc.Spec.LoadBalancerIP = "1.2.3.2"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "address is reserved for another namespace")
+
+	// A service in the reserved namespace can ask for it
+	svc = service("s3", ports("tcp/80"), "")
+	svc.Namespace = "other"
+	svc.Spec.LoadBalancerIP = "1.2.3.2"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, k.groups["default"].ReservedInUse)
+
+	// The service with the reserved name gets its address without
+	// asking
+	svc = service("web", ports("tcp/80"), "")
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.1", svc.Status.LoadBalancer.Ingress[0].IP)
+	assert.Equal(t, 2, k.groups["default"].ReservedInUse)
+
+	// Releasing the address updates the status
+	assert.NoError(t, alloc.Unassign("unit/web"))
+	assert.Equal(t, 1, k.groups["default"].ReservedInUse)
+}
+
+func TestStaticAssignments(t *testing.T) {
+	k := &testK8S{t: t}
+	alloc := New(allocatorTestLogger)
+	alloc.SetClient(k)
+	alloc.SetPools([]*purelbv1.ServiceGroup{{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec:       purelbv1.ServiceGroupSpec{Local: &purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/30", Pool: "1.2.3.0/30"}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "static"},
+		Spec: purelbv1.ServiceGroupSpec{Local: &purelbv1.ServiceGroupLocalSpec{
+			Subnet: "1.2.4.0/30",
+			Pool:   "1.2.4.0/30",
+			StaticAssignments: map[string][]string{
+				"unit/web":   {"1.2.4.1"},
+				"unit/x-api": {"1.2.4.1", "1.2.5.1"},
+				"unit/taken": {"1.2.4.2"},
+			},
+		}},
+	}})
+
+	// A service that already holds a static address is a conflict
+	other := service("other", ports("tcp/80"), "")
+	other.Annotations[purelbv1.PoolAnnotation] = "static"
+	other.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "1.2.4.2"}}
+	assert.NoError(t, alloc.NotifyExisting(&other))
+	assert.Equal(t, []string{
+		"1.2.4.1 for unit/x-api is already assigned to unit/web",
+		"1.2.5.1 for unit/x-api is not in the group",
+		"1.2.4.2 for unit/taken is in use by unit/other",
+	}, k.groups["static"].Conflicts)
+
+	// The static assignment takes priority over the desired group
+	svc := service("web", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "default"
+	pool, err := alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	assert.Equal(t, "static", pool)
+	assert.Equal(t, "1.2.4.1", svc.Status.LoadBalancer.Ingress[0].IP)
+
+	// Static addresses aren't allocated dynamically
+	for _, name := range []string{"s1", "s2"} {
+		svc := service(name, ports("tcp/80"), "")
+		svc.Annotations[purelbv1.DesiredGroupAnnotation] = "static"
+		_, err := alloc.AllocateAnyIP(&svc)
+		assert.NoError(t, err)
+	}
+	svc = service("s3", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "static"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "static addresses should not be allocated dynamically")
+
+	// Static addresses that are in use can't be assigned
+	svc = service("taken", ports("tcp/80"), "")
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.Error(t, err, "static address is in use by another service")
+	assert.Empty(t, svc.Status.LoadBalancer.Ingress)
+}
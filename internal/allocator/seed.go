@@ -0,0 +1,115 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// duplicate describes two services that hold the same address but
+// can't share it.
+type duplicate struct {
+	ip    string
+	older *v1.Service
+	newer *v1.Service
+}
+
+// key identifies the duplicate so we can tell whether we've seen it
+// before.
+func (d duplicate) key() string {
+	return fmt.Sprintf("%s %s %s", d.ip, namespacedName(d.older), namespacedName(d.newer))
+}
+
+// heldServices returns copies of the services that PureLB has
+// allocated addresses to, oldest first.
+func heldServices(all []*v1.Service) []*v1.Service {
+	svcs := []*v1.Service{}
+	for _, svc := range all {
+		if svc.Annotations[purelbv1.BrandAnnotation] == purelbv1.Brand && len(svc.Status.LoadBalancer.Ingress) > 0 {
+			svcs = append(svcs, svc.DeepCopy())
+		}
+	}
+
+	// The oldest service has the best claim to an address
+	sort.SliceStable(svcs, func(i, j int) bool {
+		return svcs[i].CreationTimestamp.Before(&svcs[j].CreationTimestamp)
+	})
+
+	return svcs
+}
+
+// duplicates returns the addresses that are held by services that
+// can't share them. svcs must be sorted oldest first.
+func (a *Allocator) duplicates(svcs []*v1.Service) []duplicate {
+	dups := []duplicate{}
+	holders := map[string][]*v1.Service{} // ip -> services
+	for _, svc := range svcs {
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			for _, other := range holders[ingress.IP] {
+				if !a.canShare(other, svc) {
+					dups = append(dups, duplicate{ip: ingress.IP, older: other, newer: svc})
+					break
+				}
+			}
+			holders[ingress.IP] = append(holders[ingress.IP], svc)
+		}
+	}
+	return dups
+}
+
+// seed tells the allocator about the addresses that all of the
+// existing services hold. It runs once the caches have synced, before
+// we allocate anything, so new services can't get addresses that
+// belong to services that we haven't processed yet. If two services
+// hold the same address and can't share it then we send a Warning
+// event to the newer one. It also restores the addresses that the
+// groups are keeping for deleted services.
+func (c *controller) seed() {
+	c.ips.restoreSticky()
+
+	svcs := heldServices(c.client.Services())
+
+	for _, dup := range c.ips.duplicates(svcs) {
+		c.logger.Log("op", "seed", "service", namespacedName(dup.newer), "ip", dup.ip, "duplicate-of", namespacedName(dup.older))
+		c.client.Errorf(dup.newer, "DuplicateAddress", "Address %s is also held by %s", dup.ip, namespacedName(dup.older))
+	}
+
+	for _, svc := range svcs {
+		if err := c.ips.NotifyExisting(svc); err != nil {
+			c.logger.Log("op", "seed", "service", namespacedName(svc), "error", err)
+		}
+	}
+
+	c.logger.Log("op", "seed", "services", len(svcs))
+}
+
+// canShare indicates whether svc1 and svc2 can share an address. If
+// they're both in the same pool then its rules decide, e.g., local
+// pools share automatically and apply the namespace sharing policy,
+// and nodes pools share node addresses between services whose ports
+// don't clash. Otherwise they need the same sharing key and ports
+// that don't clash.
+func (a *Allocator) canShare(svc1 *v1.Service, svc2 *v1.Service) bool {
+	poolName := svc2.Annotations[purelbv1.PoolAnnotation]
+	if pool, ok := a.pools[poolName]; ok && svc1.Annotations[purelbv1.PoolAnnotation] == poolName {
+		return pool.CanShare(svc1, svc2)
+	}
+	return canShareByKey(svc1, svc2)
+}
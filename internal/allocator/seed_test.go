@@ -0,0 +1,149 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// existingService returns a service that PureLB allocated ip to from
+// the "default" pool.
+func existingService(name string, ports []v1.ServicePort, sharingKey string, ip string, created time.Time) *v1.Service {
+	svc := service(name, ports, sharingKey)
+	svc.CreationTimestamp = metav1.NewTime(created)
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "10.0.0.1"
+	svc.Annotations[purelbv1.BrandAnnotation] = purelbv1.Brand
+	svc.Annotations[purelbv1.PoolAnnotation] = "default"
+	svc.Status = statusAssigned(ip)
+	return &svc
+}
+
+func TestSeed(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t}
+	a := New(l)
+	a.client = k
+	c := &controller{logger: l, ips: a, client: k}
+
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups: []*purelbv1.ServiceGroup{
+			localGroup("default", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/30", Pool: "1.2.3.0/30"}),
+		},
+	}))
+
+	now := time.Now()
+	k.services = []*v1.Service{
+		existingService("shared-b", ports("tcp/443"), "key", "1.2.3.1", now.Add(-2*time.Hour)),
+		existingService("shared-a", ports("tcp/80"), "key", "1.2.3.1", now.Add(-3*time.Hour)),
+		existingService("old", ports("tcp/80"), "", "1.2.3.0", now.Add(-time.Hour)),
+	}
+
+	// Services that share properly aren't duplicates
+	c.MarkSynced()
+	assert.False(t, k.loggedWarning, "shared address reported as a duplicate")
+	assert.Equal(t, 2, a.pools["default"].InUse())
+
+	// A new service doesn't get an address that an unprocessed service
+	// holds
+	svc := service("new", ports("tcp/80"), "")
+	svc.Spec.Type = "LoadBalancer"
+	svc.Spec.ClusterIP = "10.0.0.2"
+	assert.Equal(t, k8s.SyncStateSuccess, c.SetBalancer(&svc, nil))
+	assert.Equal(t, "1.2.3.2", svc.Status.LoadBalancer.Ingress[0].IP)
+
+	// Services that hold the same address but can't share it are
+	// reported
+	k.services = append(k.services, existingService("dup", ports("tcp/8080"), "", "1.2.3.0", now))
+	c.seed()
+	assert.True(t, k.loggedWarning, "duplicate wasn't reported")
+}
+
+func TestSeedSharing(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t}
+	a := New(l)
+	a.client = k
+	c := &controller{logger: l, ips: a, client: k}
+
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups: []*purelbv1.ServiceGroup{
+			localGroup("default", purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/30", Pool: "1.2.3.0/30", AutoShare: true}),
+		},
+	}))
+
+	now := time.Now()
+	other := existingService("auto-b", ports("tcp/443"), "", "1.2.3.0", now.Add(-2*time.Hour))
+	other.Namespace = "other"
+	k.services = []*v1.Service{
+		existingService("auto-a", ports("tcp/80"), "", "1.2.3.0", now.Add(-3*time.Hour)),
+		other,
+	}
+
+	// Services that the pool shared automatically, even across
+	// namespaces, aren't duplicates
+	c.seed()
+	assert.False(t, k.loggedWarning, "auto-shared address reported as a duplicate")
+
+	// Services with the same key in different namespaces are
+	// duplicates because the policy doesn't let the namespaces share
+	keyed := existingService("keyed-b", ports("tcp/443"), "key", "1.2.3.1", now)
+	keyed.Namespace = "other"
+	k.services = append(k.services, existingService("keyed-a", ports("tcp/80"), "key", "1.2.3.1", now.Add(-time.Hour)), keyed)
+	c.seed()
+	assert.True(t, k.loggedWarning, "cross-namespace duplicate wasn't reported")
+}
+
+func TestSeedNodesPool(t *testing.T) {
+	l := log.NewNopLogger()
+	k := &testK8S{t: t, nodes: []v1.Node{
+		node("n1", true, v1.NodeAddress{Type: v1.NodeInternalIP, Address: "10.0.0.1"}),
+	}}
+	a := New(l)
+	a.client = k
+	c := &controller{logger: l, ips: a, client: k}
+
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups: []*purelbv1.ServiceGroup{
+			serviceGroup("default", purelbv1.ServiceGroupSpec{Nodes: &purelbv1.ServiceGroupNodesSpec{}}),
+		},
+	}))
+
+	// Services share node addresses without sharing keys
+	now := time.Now()
+	k.services = []*v1.Service{
+		existingService("svc1", ports("tcp/80"), "", "10.0.0.1", now.Add(-time.Hour)),
+		existingService("svc2", ports("tcp/443"), "", "10.0.0.1", now),
+	}
+	c.seed()
+	assert.False(t, k.loggedWarning, "shared node address reported as a duplicate")
+
+	// ...as long as their ports don't clash
+	k.services = append(k.services, existingService("svc3", ports("tcp/80"), "", "10.0.0.1", now))
+	c.seed()
+	assert.True(t, k.loggedWarning, "clashing ports weren't reported")
+}
@@ -16,17 +16,23 @@
 package allocator
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 
 	"purelb.io/internal/k8s"
 	purelbv1 "purelb.io/pkg/apis/v1"
 )
 
 func (c *controller) SetBalancer(svc *v1.Service, _ *v1.Endpoints) k8s.SyncState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	nsName := svc.Namespace + "/" + svc.Name
 	log := log.With(c.logger, "svc-name", nsName)
 
@@ -35,9 +41,15 @@ func (c *controller) SetBalancer(svc *v1.Service, _ *v1.Endpoints) k8s.SyncState
 		return k8s.SyncStateError
 	}
 
+	// The leader will take care of the service. If we become the
+	// leader then we'll reprocess it.
+	if c.standby {
+		return k8s.SyncStateSuccess
+	}
+
 	// If the user has specified an LB class and it's not ours then we
 	// ignore the LB.
-	if svc.Spec.LoadBalancerClass != nil && *svc.Spec.LoadBalancerClass != purelbv1.ServiceLBClass {
+	if svc.Spec.LoadBalancerClass != nil && *svc.Spec.LoadBalancerClass != c.lbClass {
 		log.Log("event", "ignore", "reason", "user has specified another class", "class", *svc.Spec.LoadBalancerClass)
 		return k8s.SyncStateSuccess
 	}
@@ -49,6 +61,17 @@ func (c *controller) SetBalancer(svc *v1.Service, _ *v1.Endpoints) k8s.SyncState
 		return k8s.SyncStateSuccess
 	}
 
+	// If the service is being deleted then release its addresses so
+	// Kubernetes can finish deleting it. The finalizer has the same
+	// name in every instance of PureLB so only the instance that owns
+	// the service's class may remove it.
+	if svc.DeletionTimestamp != nil {
+		if hasFinalizer(svc) {
+			return c.releaseDeleted(log, svc)
+		}
+		return k8s.SyncStateSuccess
+	}
+
 	// If the service isn't a LoadBalancer then we might need to clean
 	// up. It might have been a load balancer before and the user might
 	// have changed it to tell us to release the address
@@ -66,6 +89,7 @@ func (c *controller) SetBalancer(svc *v1.Service, _ *v1.Endpoints) k8s.SyncState
 					return k8s.SyncStateError
 				}
 				svc.Status.LoadBalancer.Ingress = nil
+				delete(svc.Annotations, purelbv1.PrefixAnnotation)
 			}
 		}
 
@@ -73,6 +97,12 @@ func (c *controller) SetBalancer(svc *v1.Service, _ *v1.Endpoints) k8s.SyncState
 		// we'll re-allocate if the user flips this service back to a
 		// LoadBalancer
 		delete(svc.Annotations, purelbv1.PoolAnnotation)
+		delete(svc.Annotations, purelbv1.DHCPServerAnnotation)
+		delete(svc.Annotations, purelbv1.PrefixAnnotation)
+		delete(svc.Annotations, purelbv1.LeaseExpiresAnnotation)
+		delete(svc.Annotations, purelbv1.LeaseExpiredAnnotation)
+		meta.RemoveStatusCondition(&svc.Status.Conditions, purelbv1.LeaseCondition)
+		removeFinalizer(svc)
 
 		// It's not a LoadBalancer so there's nothing more for us to do
 		return k8s.SyncStateSuccess
@@ -86,6 +116,30 @@ func (c *controller) SetBalancer(svc *v1.Service, _ *v1.Endpoints) k8s.SyncState
 		return k8s.SyncStateSuccess
 	}
 
+	// If something has told us that the service's address is no
+	// longer valid then release it so we'll allocate a new one.
+	if reason, needsNew := c.reallocate[nsName]; needsNew && len(svc.Status.LoadBalancer.Ingress) > 0 {
+		log.Log("event", "unassign", "ingress-address", svc.Status.LoadBalancer.Ingress, "reason", reason)
+		c.client.Infof(svc, "AddressReleased", "Reallocating because %s", reason)
+		if err := c.ips.Unassign(nsName); err != nil {
+			log.Log("event", "unassign", "error", err)
+			return k8s.SyncStateError
+		}
+		svc.Status.LoadBalancer.Ingress = nil
+		delete(svc.Annotations, purelbv1.PrefixAnnotation)
+	}
+	delete(c.reallocate, nsName)
+
+	// If the service's address lease has expired then release the
+	// address, and don't allocate a new one until the user asks.
+	if expires, has := c.ips.leaseExpires(svc); has && !time.Now().Before(expires) && len(svc.Status.LoadBalancer.Ingress) > 0 {
+		return c.expireLease(log, svc)
+	}
+	if expired, has := svc.Annotations[purelbv1.LeaseExpiredAnnotation]; has && len(svc.Status.LoadBalancer.Ingress) == 0 {
+		log.Log("event", "ignore", "reason", "lease expired", "expired", expired)
+		return k8s.SyncStateSuccess
+	}
+
 	// Check if the service already has an address
 	if len(svc.Status.LoadBalancer.Ingress) > 0 {
 		log.Log("event", "hasIngress", "ingress", svc.Status.LoadBalancer.Ingress)
@@ -104,6 +158,15 @@ func (c *controller) SetBalancer(svc *v1.Service, _ *v1.Endpoints) k8s.SyncState
 			if err := c.ips.NotifyExisting(svc); err != nil {
 				log.Log("event", "notifyFailure", "ingress-address", svc.Status.LoadBalancer.Ingress, "reason", err.Error())
 			}
+
+			// Services that we allocated before we used finalizers
+			// need one, too.
+			addFinalizer(svc)
+
+			// Services that we allocated before their group had a lease
+			// need one, and users can't extend their leases past the
+			// group's maximum.
+			c.checkLease(svc)
 		}
 
 		// If the service already has an address then we don't need to
@@ -111,13 +174,29 @@ func (c *controller) SetBalancer(svc *v1.Service, _ *v1.Endpoints) k8s.SyncState
 		return k8s.SyncStateSuccess
 	}
 
+	// The sharing policy might be why the allocation fails so check it
+	// first
+	blocked := c.ips.SharingBlocked(svc, c.ips.requestedPool(svc))
+
 	pool, err := c.ips.AllocateAnyIP(svc)
+	if errors.Is(err, errAllocationPending) {
+		// The pool will resync the service when it has an address
+		log.Log("op", "allocateIP", "msg", "waiting for the pool")
+		return k8s.SyncStateSuccess
+	}
 	if err != nil {
 		log.Log("op", "allocateIP", "error", err, "msg", "IP allocation failed")
-		c.client.Errorf(svc, "AllocationFailed", "Failed to allocate IP for %q: %s", nsName, err)
+		if blocked != "" {
+			c.client.Errorf(svc, "AllocationFailed", "Failed to allocate IP for %q: %s, and it can't share an address because the %s", nsName, err, blocked)
+		} else {
+			c.client.Errorf(svc, "AllocationFailed", "Failed to allocate IP for %q: %s", nsName, err)
+		}
 		return k8s.SyncStateSuccess
 	}
 	c.client.Infof(svc, "AddressAssigned", "Assigned %+v from pool %s", svc.Status.LoadBalancer, pool)
+	if blocked := c.ips.SharingBlocked(svc, pool); blocked != "" {
+		c.client.Errorf(svc, "SharingBlocked", "Allocated a separate address because the %s", blocked)
+	}
 
 	// annotate the service as "ours" and annotate the pool from which
 	// the address came
@@ -126,6 +205,9 @@ func (c *controller) SetBalancer(svc *v1.Service, _ *v1.Endpoints) k8s.SyncState
 	}
 	svc.Annotations[purelbv1.BrandAnnotation] = purelbv1.Brand
 	svc.Annotations[purelbv1.PoolAnnotation] = pool
+	delete(svc.Annotations, purelbv1.LeaseExpiredAnnotation)
+	c.setLease(svc, pool)
+	addFinalizer(svc)
 
 	return k8s.SyncStateSuccess
 }
@@ -38,9 +38,24 @@ var (
 		Name:      "addresses_in_use",
 		Help:      "Number of addresses allocated from the pool",
 	}, labelNames)
+
+	poolPollAge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: purelbv1.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "seconds_since_last_poll",
+		Help:      "Seconds since the pool's capacity was last successfully read from its IPAM system",
+	}, labelNames)
+
+	addressConflicts = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: purelbv1.MetricsNamespace,
+		Name:      "address_conflicts",
+		Help:      "Number of conflicting address assignments that the last audit found",
+	})
 )
 
 func init() {
 	prometheus.MustRegister(poolCapacity)
 	prometheus.MustRegister(poolActive)
+	prometheus.MustRegister(poolPollAge)
+	prometheus.MustRegister(addressConflicts)
 }
@@ -0,0 +1,159 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// stickyAddresses records the addresses that a deleted service held
+// so it can get them back if it returns before they expire.
+type stickyAddresses struct {
+	pool      string
+	addresses []net.IP
+	expires   time.Time
+}
+
+// Delete frees the addresses of svc, which has been deleted. Pools
+// with a StickyWindow keep the addresses reserved for svc for that
+// long in case it's re-created. The kept addresses are written to the
+// groups' status so they survive restarts and leader changes.
+func (a *Allocator) Delete(svc string) error {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(svc)
+	now := time.Now()
+
+	for pname, p := range a.pools {
+		local, ok := p.(LocalPool)
+		if !ok {
+			continue
+		}
+		if ips := local.StickyAddresses(svc); len(ips) > 0 {
+			sticky := stickyAddresses{pool: pname, addresses: ips, expires: now.Add(local.StickyWindow())}
+			for _, ip := range ips {
+				local.Stick(ip, namespace, name, sticky.expires)
+			}
+			a.sticky[svc] = sticky
+		}
+	}
+
+	return a.Unassign(svc)
+}
+
+// restick re-applies the unexpired sticky reservations to the pools,
+// for example, after they've been rebuilt.
+func (a *Allocator) restick() {
+	now := time.Now()
+	for svc, sticky := range a.sticky {
+		local, ok := a.pools[sticky.pool].(LocalPool)
+		if !ok || now.After(sticky.expires) {
+			delete(a.sticky, svc)
+			continue
+		}
+		namespace, name, _ := cache.SplitMetaNamespaceKey(svc)
+		for _, ip := range sticky.addresses {
+			local.Stick(ip, namespace, name, sticky.expires)
+		}
+	}
+}
+
+// restoreSticky reads the unexpired sticky addresses that we wrote to
+// the groups' status, e.g., before we restarted or another allocator
+// was the leader, and keeps them for their services again.
+func (a *Allocator) restoreSticky() {
+	now := time.Now()
+	for name, group := range a.groups {
+		if _, ok := a.pools[name].(LocalPool); !ok {
+			continue
+		}
+		for _, status := range group.Status.Sticky {
+			if _, has := a.sticky[status.Service]; has || !now.Before(status.Expires.Time) {
+				continue
+			}
+			sticky := stickyAddresses{pool: name, expires: status.Expires.Time}
+			for _, addr := range status.Addresses {
+				if ip := net.ParseIP(addr); ip != nil {
+					sticky.addresses = append(sticky.addresses, ip)
+				}
+			}
+			if len(sticky.addresses) > 0 {
+				a.sticky[status.Service] = sticky
+			}
+		}
+	}
+	a.restick()
+}
+
+// stickyStatus returns the sticky addresses that pool is keeping, in
+// the form that we write to its group's status.
+func (a *Allocator) stickyStatus(pool string) []purelbv1.ServiceGroupStickyAddresses {
+	var status []purelbv1.ServiceGroupStickyAddresses
+	for svc, sticky := range a.sticky {
+		if sticky.pool != pool {
+			continue
+		}
+		entry := purelbv1.ServiceGroupStickyAddresses{Service: svc, Expires: metav1.NewTime(sticky.expires)}
+		for _, ip := range sticky.addresses {
+			entry.Addresses = append(entry.Addresses, ip.String())
+		}
+		status = append(status, entry)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Service < status[j].Service })
+	return status
+}
+
+// pruneSticky forgets the sticky addresses that have expired.
+func (a *Allocator) pruneSticky() {
+	now := time.Now()
+	pruned := false
+	for svc, sticky := range a.sticky {
+		if now.Before(sticky.expires) {
+			continue
+		}
+		delete(a.sticky, svc)
+		pruned = true
+		if local, ok := a.pools[sticky.pool].(LocalPool); ok {
+			namespace, name, _ := cache.SplitMetaNamespaceKey(svc)
+			for _, ip := range sticky.addresses {
+				local.Unstick(ip, namespace, name)
+			}
+		}
+	}
+	if pruned {
+		a.reportGroups()
+	}
+}
+
+// unstick removes svc's sticky reservations once it has been
+// allocated addresses so they're freed normally the next time.
+func (a *Allocator) unstick(svc *v1.Service) {
+	sticky, has := a.sticky[namespacedName(svc)]
+	if !has {
+		return
+	}
+	delete(a.sticky, namespacedName(svc))
+	if local, ok := a.pools[sticky.pool].(LocalPool); ok {
+		for _, ip := range sticky.addresses {
+			local.Unstick(ip, svc.Namespace, svc.Name)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"purelb.io/internal/k8s"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func TestSticky(t *testing.T) {
+	k := &testK8S{t: t}
+	alloc := New(allocatorTestLogger)
+	alloc.SetClient(k)
+	assert.NoError(t, alloc.SetPools([]*purelbv1.ServiceGroup{{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec:       purelbv1.ServiceGroupSpec{Local: &purelbv1.ServiceGroupLocalSpec{Subnet: "1.2.3.0/31", Pool: "1.2.3.0/31"}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "sticky"},
+		Spec: purelbv1.ServiceGroupSpec{Local: &purelbv1.ServiceGroupLocalSpec{
+			Subnet:       "1.2.4.0/31",
+			Pool:         "1.2.4.0/31",
+			StickyWindow: &metav1.Duration{Duration: time.Hour},
+		}},
+	}}))
+
+	svc := service("web", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "sticky"
+	_, err := alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	ip := svc.Status.LoadBalancer.Ingress[0].IP
+
+	// After the service is deleted its address is kept for it
+	assert.NoError(t, alloc.Delete("unit/web"))
+	for _, name := range []string{"s1", "s2"} {
+		other := service(name, ports("tcp/80"), "")
+		other.Annotations[purelbv1.DesiredGroupAnnotation] = "sticky"
+		_, err = alloc.AllocateAnyIP(&other)
+		if name == "s1" {
+			assert.NoError(t, err)
+			assert.NotEqual(t, ip, other.Status.LoadBalancer.Ingress[0].IP)
+		} else {
+			assert.Error(t, err, "sticky address should not be allocated to another service")
+		}
+	}
+
+	// The sticky address survives a config change
+	alloc.SetReservations(nil)
+
+	// The re-created service gets its old address back even without
+	// its annotation
+	svc = service("web", ports("tcp/80"), "")
+	pool, err := alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	assert.Equal(t, "sticky", pool)
+	assert.Equal(t, ip, svc.Status.LoadBalancer.Ingress[0].IP)
+
+	// Once it's been claimed, unassigning frees the address
+	assert.NoError(t, alloc.Unassign("unit/web"))
+	other := service("s2", ports("tcp/80"), "")
+	other.Annotations[purelbv1.DesiredGroupAnnotation] = "sticky"
+	_, err = alloc.AllocateAnyIP(&other)
+	assert.NoError(t, err)
+
+	// Pools without a window free addresses immediately
+	svc = service("api", ports("tcp/80"), "")
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	assert.NoError(t, alloc.Delete("unit/api"))
+	assert.Empty(t, alloc.sticky)
+	assert.Equal(t, 0, alloc.pools["default"].InUse())
+
+	// A service that's re-created after its sticky addresses have
+	// expired gets an address from the default pool, and the expired
+	// addresses are forgotten
+	assert.NoError(t, alloc.Unassign("unit/s2"))
+	svc = service("web", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "sticky"
+	_, err = alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	assert.NoError(t, alloc.Delete("unit/web"))
+	sticky := alloc.sticky["unit/web"]
+	sticky.expires = time.Now().Add(-time.Second)
+	alloc.sticky["unit/web"] = sticky
+	svc = service("web", ports("tcp/80"), "")
+	pool, err = alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", pool)
+	assert.NoError(t, alloc.Unassign("unit/web"))
+
+	assert.NoError(t, alloc.Delete("unit/s1"))
+	sticky = alloc.sticky["unit/s1"]
+	sticky.expires = time.Now().Add(-time.Second)
+	alloc.sticky["unit/s1"] = sticky
+	alloc.pruneSticky()
+	assert.Empty(t, alloc.sticky)
+}
+
+func TestStickyRestore(t *testing.T) {
+	l := log.NewNopLogger()
+	spec := purelbv1.ServiceGroupLocalSpec{
+		Subnet:       "1.2.4.0/31",
+		Pool:         "1.2.4.0/31",
+		StickyWindow: &metav1.Duration{Duration: time.Hour},
+	}
+
+	k := &testK8S{t: t}
+	alloc := New(l)
+	alloc.SetClient(k)
+	assert.NoError(t, alloc.SetPools([]*purelbv1.ServiceGroup{localGroup("sticky", spec)}))
+	svc := service("web", ports("tcp/80"), "")
+	svc.Annotations[purelbv1.DesiredGroupAnnotation] = "sticky"
+	_, err := alloc.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	ip := svc.Status.LoadBalancer.Ingress[0].IP
+
+	// The kept address is written to the group's status
+	assert.NoError(t, alloc.Delete("unit/web"))
+	status := k.groups["sticky"]
+	if assert.Len(t, status.Sticky, 1) {
+		assert.Equal(t, "unit/web", status.Sticky[0].Service)
+		assert.Equal(t, []string{ip}, status.Sticky[0].Addresses)
+	}
+
+	// A new allocator, e.g., after a restart or a leader change,
+	// restores the kept address from the group's status
+	group := localGroup("sticky", spec)
+	group.Status = status
+	a := New(l)
+	a.client = k
+	c := &controller{logger: l, ips: a, client: k}
+	assert.Equal(t, k8s.SyncStateReprocessAll, c.SetConfig(&purelbv1.Config{
+		DefaultAnnouncer: true,
+		Groups:           []*purelbv1.ServiceGroup{group},
+	}))
+	c.MarkSynced()
+
+	for _, name := range []string{"s1", "s2"} {
+		other := service(name, ports("tcp/80"), "")
+		other.Annotations[purelbv1.DesiredGroupAnnotation] = "sticky"
+		_, err = a.AllocateAnyIP(&other)
+		if name == "s1" {
+			assert.NoError(t, err)
+			assert.NotEqual(t, ip, other.Status.LoadBalancer.Ingress[0].IP)
+		} else {
+			assert.Error(t, err, "restored sticky address should not be allocated to another service")
+		}
+	}
+
+	// The re-created service gets its old address back, and the status
+	// no longer lists it
+	svc = service("web", ports("tcp/80"), "")
+	pool, err := a.AllocateAnyIP(&svc)
+	assert.NoError(t, err)
+	assert.Equal(t, "sticky", pool)
+	assert.Equal(t, ip, svc.Status.LoadBalancer.Ingress[0].IP)
+	assert.Empty(t, k.groups["sticky"].Sticky)
+
+	// Expired entries aren't restored
+	group = localGroup("sticky", spec)
+	group.Status.Sticky = []purelbv1.ServiceGroupStickyAddresses{{
+		Service:   "unit/old",
+		Addresses: []string{ip},
+		Expires:   metav1.NewTime(time.Now().Add(-time.Second)),
+	}}
+	a = New(l)
+	a.client = k
+	c = &controller{logger: l, ips: a, client: k}
+	c.SetConfig(&purelbv1.Config{DefaultAnnouncer: true, Groups: []*purelbv1.ServiceGroup{group}})
+	c.MarkSynced()
+	assert.Empty(t, a.sticky)
+}
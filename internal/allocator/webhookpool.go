@@ -0,0 +1,285 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocator
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+
+	"purelb.io/internal/webhook"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// WebhookPool is the IP address pool that requests IP addresses from
+// an external IPAM system using PureLB's HTTP webhook protocol.
+type WebhookPool struct {
+	logger log.Logger
+
+	// name is the name of the ServiceGroup, which we send to the IPAM
+	// so it can tell our pools apart.
+	name string
+	ipam webhook.IPAM
+
+	// services caches the addresses that we've allocated to a specific
+	// service. It's used so we can release addresses when we're given
+	// only the service name. The key is the service's namespaced name,
+	// and the value is an array of the addresses assigned to that
+	// service.
+	services map[string][]net.IP
+
+	// Map of the addresses that have been assigned.
+	addressesInUse map[string]string // ip.String() -> svc name
+
+	pollInterval time.Duration
+	capacity     *remoteCapacity
+	stopCh       chan struct{}
+}
+
+const (
+	defaultWebhookPollInterval = time.Minute
+)
+
+// NewWebhookPool initializes a new instance of WebhookPool. If error
+// is non-nil then the returned WebhookPool should not be used.
+func NewWebhookPool(log log.Logger, name string, spec purelbv1.ServiceGroupWebhookSpec) (*WebhookPool, error) {
+	// Validate the url from the service group
+	url, err := url.Parse(spec.URL)
+	if err != nil || url.Scheme == "" || url.Host == "" {
+		return nil, fmt.Errorf("webhook URL %q invalid", spec.URL)
+	}
+
+	pollInterval := defaultWebhookPollInterval
+	if spec.PollInterval != nil {
+		if spec.PollInterval.Duration <= 0 {
+			return nil, fmt.Errorf("webhook pollInterval %s must be positive", spec.PollInterval.Duration)
+		}
+		pollInterval = spec.PollInterval.Duration
+	}
+
+	return &WebhookPool{
+		logger:         log,
+		name:           name,
+		ipam:           webhook.NewIPAM(url.String(), os.Getenv("IPAM_WEBHOOK_TOKEN")),
+		services:       map[string][]net.IP{},
+		addressesInUse: map[string]string{},
+		pollInterval:   pollInterval,
+		capacity:       &remoteCapacity{lastPoll: time.Now()},
+		stopCh:         make(chan struct{}),
+	}, nil
+}
+
+func (p WebhookPool) Notify(service *v1.Service) error {
+	nsName := namespacedName(service)
+
+	ips := []net.IP{}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		ipstr := ingress.IP
+		ip := net.ParseIP(ipstr)
+		if ip == nil {
+			return fmt.Errorf("Service %s has unparseable IP %s", nsName, ipstr)
+		}
+		ips = append(ips, ip)
+	}
+
+	for _, ip := range ips {
+		p.addressesInUse[ip.String()] = nsName
+	}
+	p.services[nsName] = ips
+
+	return nil
+}
+
+// AssignNext asks the IPAM for addresses for service, one for each of
+// the service's IP families.
+func (p WebhookPool) AssignNext(service *v1.Service) error {
+	families := []string{}
+	for _, family := range service.Spec.IPFamilies {
+		families = append(families, string(family))
+	}
+
+	addrs, err := p.ipam.Allocate(p.name, webhookService(service), families)
+	if err != nil {
+		return fmt.Errorf("no available IPs in pool %q: %s", p.name, err)
+	}
+
+	ips := []net.IP{}
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			// Give the addresses back since we can't use them
+			if err := p.ipam.Release(p.name, webhookService(service), addrs); err != nil {
+				p.logger.Log("op", "releaseWebhook", "service", namespacedName(service), "error", err)
+			}
+			return fmt.Errorf("IPAM returned unparseable IP %q", addr)
+		}
+		ips = append(ips, ip)
+	}
+
+	for _, ip := range ips {
+		addIngress(p.logger, service, ip)
+	}
+
+	// Update our internal allocation data structures
+	return p.Notify(service)
+}
+
+// Assign assigns a service to an IP.
+func (p WebhookPool) Assign(ip net.IP, service *v1.Service) error {
+	// we have an IP selected somehow, so program the data plane
+	addIngress(p.logger, service, ip)
+
+	// Update our internal allocation data structures
+	return p.Notify(service)
+}
+
+// Release releases a service's addresses, both in our cache and in
+// the IPAM.
+func (p WebhookPool) Release(service string) error {
+	ips, haveIp := p.services[service]
+	if !haveIp {
+		return fmt.Errorf("trying to release an IP from %w %s", errUnknownService, service)
+	}
+
+	addrs := []string{}
+	for _, ip := range ips {
+		addrs = append(addrs, ip.String())
+	}
+
+	// If the IPAM didn't hear about the release then we keep the
+	// addresses so we can try again.
+	namespace, name, _ := cache.SplitMetaNamespaceKey(service)
+	if err := p.ipam.Release(p.name, webhook.Service{Namespace: namespace, Name: name}, addrs); err != nil {
+		p.logger.Log("op", "releaseWebhook", "service", service, "error", err)
+		return err
+	}
+
+	delete(p.services, service)
+	for _, ip := range ips {
+		delete(p.addressesInUse, ip.String())
+	}
+
+	return nil
+}
+
+// InUse returns the count of addresses that currently have services
+// assigned. Once we've polled the IPAM this is the count that it
+// reports, otherwise it's the count of addresses that we've
+// allocated.
+func (p WebhookPool) InUse() int {
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+
+	if p.capacity.polled {
+		return p.capacity.inUse
+	}
+	return len(p.addressesInUse)
+}
+
+// Size returns the total number of addresses in this pool as of the
+// most recent poll of the IPAM, or 0 if we haven't been able to poll
+// it yet.
+func (p WebhookPool) Size() uint64 {
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+
+	return p.capacity.size
+}
+
+// Start starts polling the IPAM for this pool's capacity. It
+// implements the Poller interface.
+func (p WebhookPool) Start(name string) {
+	go wait.Until(func() { p.poll(name) }, p.pollInterval, p.stopCh)
+}
+
+// Stop stops polling the IPAM. It implements the Poller interface.
+func (p WebhookPool) Stop() {
+	close(p.stopCh)
+}
+
+// poll refreshes this pool's capacity from the IPAM and updates the
+// pool's metrics.
+func (p WebhookPool) poll(name string) {
+	if err := p.refresh(); err != nil {
+		p.logger.Log("op", "pollWebhook", "pool", name, "error", err)
+	} else {
+		poolCapacity.WithLabelValues(name).Set(float64(p.Size()))
+		poolActive.WithLabelValues(name).Set(float64(p.InUse()))
+	}
+
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+	poolPollAge.WithLabelValues(name).Set(time.Since(p.capacity.lastPoll).Seconds())
+}
+
+// refresh reads this pool's size and allocations from the IPAM.
+func (p WebhookPool) refresh() error {
+	list, err := p.ipam.List(p.name)
+	if err != nil {
+		return err
+	}
+
+	p.capacity.Lock()
+	defer p.capacity.Unlock()
+	p.capacity.polled = true
+	p.capacity.size = list.Size
+	p.capacity.inUse = len(list.Allocations)
+	p.capacity.lastPoll = time.Now()
+
+	return nil
+}
+
+// CanShare indicates whether svc1 and svc2 can share an
+// address. This pool has no sharing rules of its own so they need the
+// same sharing key and ports that don't clash.
+func (p WebhookPool) CanShare(svc1 *v1.Service, svc2 *v1.Service) bool {
+	return canShareByKey(svc1, svc2)
+}
+
+// Overlaps indicates whether the other Pool overlaps with this one
+// (i.e., has any addresses in common).  It returns true if there are
+// any common addresses and false if there aren't. This implementation
+// always returns false since the pool is managed by a remote system.
+func (p WebhookPool) Overlaps(other Pool) bool {
+	return false
+}
+
+// Contains indicates whether the provided net.IP represents an
+// address within this Pool.  It returns true if so, false
+// otherwise. In this case the pool is owned by a remote system so
+// "address within this Pool" means that the address has been
+// allocated by a previous call to AssignNext().
+func (p WebhookPool) Contains(ip net.IP) bool {
+	_, allocated := p.addressesInUse[ip.String()]
+	return allocated
+}
+
+// webhookService returns the webhook protocol's description of svc.
+func webhookService(svc *v1.Service) webhook.Service {
+	return webhook.Service{
+		Namespace:   svc.Namespace,
+		Name:        svc.Name,
+		UID:         string(svc.UID),
+		Labels:      svc.Labels,
+		Annotations: svc.Annotations,
+	}
+}
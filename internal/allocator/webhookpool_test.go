@@ -0,0 +1,67 @@
+// Copyright 2021 Acnodal Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package allocator
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+
+	"purelb.io/internal/webhook/fake"
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+func TestWebhookPool(t *testing.T) {
+	server := httptest.NewServer(fake.NewServer("10.1.2.3", "10.1.2.4", "fd00::1"))
+	defer server.Close()
+
+	p, err := NewWebhookPool(log.NewNopLogger(), "inhouse", purelbv1.ServiceGroupWebhookSpec{URL: server.URL})
+	assert.Nil(t, err, "NewWebhookPool()")
+
+	// A dual-stack service gets one address of each family
+	svc1 := service("svc1", ports("tcp/80"), "")
+	svc1.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol}
+	assert.Nil(t, p.AssignNext(&svc1), "dual-stack AssignNext() failed")
+	assert.Equal(t, []v1.LoadBalancerIngress{{IP: "fd00::1"}, {IP: "10.1.2.3"}}, svc1.Status.LoadBalancer.Ingress)
+	assert.True(t, p.Contains(net.ParseIP("fd00::1")), "pool should contain fd00::1")
+	assert.True(t, p.Contains(net.ParseIP("10.1.2.3")), "pool should contain 10.1.2.3")
+
+	// A service with no family preference gets whatever's free
+	svc2 := service("svc2", ports("tcp/80"), "")
+	assert.Nil(t, p.AssignNext(&svc2), "AssignNext() failed")
+	assert.Equal(t, "10.1.2.4", svc2.Status.LoadBalancer.Ingress[0].IP)
+
+	// The IPAM reports its size and usage
+	assert.Nil(t, p.refresh(), "refresh() failed")
+	assert.Equal(t, uint64(3), p.Size(), "polled pool has wrong size")
+	assert.Equal(t, 3, p.InUse(), "polled pool has wrong usage")
+
+	// The pool is full
+	svc3 := service("svc3", ports("tcp/80"), "")
+	assert.Error(t, p.AssignNext(&svc3), "AssignNext() from a full pool succeeded")
+
+	// Releasing gives the addresses back to the IPAM
+	assert.Nil(t, p.Release(namespacedName(&svc1)), "Release() failed")
+	assert.False(t, p.Contains(net.ParseIP("10.1.2.3")), "pool should not contain 10.1.2.3")
+	assert.Error(t, p.Release(namespacedName(&svc1)), "Release() of unknown service succeeded")
+	assert.Nil(t, p.AssignNext(&svc3), "AssignNext() after Release() failed")
+	assert.Equal(t, "10.1.2.3", svc3.Status.LoadBalancer.Ingress[0].IP)
+
+	// Invalid URLs are rejected
+	_, err = NewWebhookPool(log.NewNopLogger(), "inhouse", purelbv1.ServiceGroupWebhookSpec{URL: "ipam"})
+	assert.Error(t, err, "NewWebhookPool() accepted an invalid URL")
+}
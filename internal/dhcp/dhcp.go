@@ -0,0 +1,321 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dhcp is a minimal DHCPv4 client that leases addresses on
+// behalf of services. Unlike a host's DHCP client it never configures
+// the addresses that it leases, so it can't rely on owning them: all
+// of its requests are broadcast with the BROADCAST flag set so
+// servers broadcast their replies, and it renews leases using
+// INIT-REBOOT requests (RFC 2131 section 4.3.2) instead of unicast
+// RENEWING requests.
+package dhcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	opRequest byte = 1
+	opReply   byte = 2
+
+	flagBroadcast uint16 = 0x8000
+
+	optHostname     byte = 12
+	optRequestedIP  byte = 50
+	optLeaseTime    byte = 51
+	optMessageType  byte = 53
+	optServerID     byte = 54
+	optParamRequest byte = 55
+	optRenewalTime  byte = 58
+	optClientID     byte = 61
+	optEnd          byte = 255
+	optPad          byte = 0
+
+	typeDiscover byte = 1
+	typeOffer    byte = 2
+	typeRequest  byte = 3
+	typeAck      byte = 5
+	typeNak      byte = 6
+	typeRelease  byte = 7
+
+	// minMessageLen is the minimum BOOTP message length. Some servers
+	// ignore shorter messages.
+	minMessageLen = 300
+
+	attempts = 3
+	timeout  = 3 * time.Second
+)
+
+var magicCookie = []byte{99, 130, 83, 99}
+
+// Lease is an address that a DHCP server has leased to us.
+type Lease struct {
+	IP     net.IP
+	Server net.IP
+
+	// Duration is the length of the lease, and RenewAfter is when we
+	// should renew it (DHCP's "T1"), both from the time that the lease
+	// was granted.
+	Duration   time.Duration
+	RenewAfter time.Duration
+}
+
+// Client leases addresses from DHCP servers. Each lease belongs to a
+// client ID, which must be unique on the network.
+type Client interface {
+	Acquire(clientID string, hostname string) (Lease, error)
+	Renew(clientID string, hostname string, ip net.IP) (Lease, error)
+	Release(clientID string, ip net.IP, server net.IP) error
+}
+
+// client is a DHCP client that runs on one network interface.
+type client struct {
+	// lock serializes our exchanges since they all use the DHCP
+	// client port.
+	lock sync.Mutex
+
+	iface  string
+	hwAddr net.HardwareAddr
+}
+
+// NewClient returns a Client that leases addresses from the DHCP
+// servers attached to the network interface iface.
+func NewClient(iface string) (Client, error) {
+	intf, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	if len(intf.HardwareAddr) == 0 || len(intf.HardwareAddr) > 16 {
+		return nil, fmt.Errorf("interface %s has no usable hardware address", iface)
+	}
+	return &client{iface: iface, hwAddr: intf.HardwareAddr}, nil
+}
+
+// Acquire leases a new address using the DISCOVER/OFFER/REQUEST/ACK
+// exchange.
+func (c *client) Acquire(clientID string, hostname string) (Lease, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	discover := c.newMessage(typeDiscover, clientID, hostname)
+	offer, err := c.exchange(discover, typeOffer)
+	if err != nil {
+		return Lease{}, fmt.Errorf("no DHCP offer: %w", err)
+	}
+
+	request := c.newMessage(typeRequest, clientID, hostname)
+	request.options[optRequestedIP] = offer.yiaddr.To4()
+	request.options[optServerID] = offer.options[optServerID]
+	return c.request(request)
+}
+
+// Renew extends the lease on ip.
+func (c *client) Renew(clientID string, hostname string, ip net.IP) (Lease, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	request := c.newMessage(typeRequest, clientID, hostname)
+	request.options[optRequestedIP] = ip.To4()
+	return c.request(request)
+}
+
+// Release tells the server that we no longer need ip. Servers don't
+// reply to releases so we can't tell whether it worked.
+func (c *client) Release(clientID string, ip net.IP, server net.IP) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	release := c.newMessage(typeRelease, clientID, "")
+	release.ciaddr = ip.To4()
+	if server != nil {
+		release.options[optServerID] = server.To4()
+	}
+	conn, err := listen(c.iface)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.WriteTo(release.marshal(), &net.UDPAddr{IP: net.IPv4bcast, Port: 67})
+	return err
+}
+
+// request sends a REQUEST and converts the ACK into a Lease.
+func (c *client) request(request *message) (Lease, error) {
+	ack, err := c.exchange(request, typeAck, typeNak)
+	if err != nil {
+		return Lease{}, fmt.Errorf("no DHCP ack: %w", err)
+	}
+	if ack.messageType() == typeNak {
+		return Lease{}, fmt.Errorf("DHCP server refused %s", net.IP(request.options[optRequestedIP]))
+	}
+
+	lease := Lease{IP: ack.yiaddr, Server: net.IP(ack.options[optServerID])}
+	if secs := ack.options[optLeaseTime]; len(secs) == 4 {
+		lease.Duration = time.Duration(binary.BigEndian.Uint32(secs)) * time.Second
+	}
+	if secs := ack.options[optRenewalTime]; len(secs) == 4 {
+		lease.RenewAfter = time.Duration(binary.BigEndian.Uint32(secs)) * time.Second
+	} else {
+		lease.RenewAfter = lease.Duration / 2
+	}
+	return lease, nil
+}
+
+// exchange broadcasts msg and waits for a reply of one of
+// replyTypes.
+func (c *client) exchange(msg *message, replyTypes ...byte) (*message, error) {
+	conn, err := listen(c.iface)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 1500)
+	for attempt := 0; attempt < attempts; attempt++ {
+		if _, err := conn.WriteTo(msg.marshal(), &net.UDPAddr{IP: net.IPv4bcast, Port: 67}); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break // try again
+				}
+				return nil, err
+			}
+			reply, err := unmarshal(buf[:n])
+			if err != nil || reply.op != opReply || reply.xid != msg.xid {
+				continue // not for us
+			}
+			for _, replyType := range replyTypes {
+				if reply.messageType() == replyType {
+					return reply, nil
+				}
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no reply after %d attempts", attempts)
+}
+
+func (c *client) newMessage(msgType byte, clientID string, hostname string) *message {
+	msg := message{
+		op:     opRequest,
+		xid:    rand.Uint32(),
+		flags:  flagBroadcast,
+		chaddr: c.hwAddr,
+		options: map[byte][]byte{
+			optMessageType:  {msgType},
+			optClientID:     append([]byte{0}, clientID...),
+			optParamRequest: {optLeaseTime, optRenewalTime, optServerID},
+		},
+	}
+	if hostname != "" {
+		msg.options[optHostname] = []byte(hostname)
+	}
+	return &msg
+}
+
+// message is a DHCP message. We only use the fields that we need.
+type message struct {
+	op      byte
+	xid     uint32
+	flags   uint16
+	ciaddr  net.IP
+	yiaddr  net.IP
+	chaddr  net.HardwareAddr
+	options map[byte][]byte
+}
+
+func (m *message) messageType() byte {
+	if t := m.options[optMessageType]; len(t) == 1 {
+		return t[0]
+	}
+	return 0
+}
+
+// marshal encodes m in the DHCP wire format.
+func (m *message) marshal() []byte {
+	buf := make([]byte, 240, minMessageLen)
+	buf[0] = m.op
+	buf[1] = 1 // ethernet
+	buf[2] = byte(len(m.chaddr))
+	binary.BigEndian.PutUint32(buf[4:8], m.xid)
+	binary.BigEndian.PutUint16(buf[10:12], m.flags)
+	copy(buf[12:16], m.ciaddr.To4())
+	copy(buf[16:20], m.yiaddr.To4())
+	copy(buf[28:44], m.chaddr)
+	copy(buf[236:240], magicCookie)
+
+	// Write the options in order so the encoding is predictable
+	for code := 1; code < int(optEnd); code++ {
+		if value, exists := m.options[byte(code)]; exists {
+			buf = append(buf, byte(code), byte(len(value)))
+			buf = append(buf, value...)
+		}
+	}
+	buf = append(buf, optEnd)
+
+	for len(buf) < minMessageLen {
+		buf = append(buf, optPad)
+	}
+	return buf
+}
+
+// unmarshal decodes a DHCP message.
+func unmarshal(buf []byte) (*message, error) {
+	if len(buf) < 240 || string(buf[236:240]) != string(magicCookie) {
+		return nil, fmt.Errorf("not a DHCP message")
+	}
+	hlen := int(buf[2])
+	if hlen > 16 {
+		return nil, fmt.Errorf("invalid hardware address length %d", hlen)
+	}
+
+	m := message{
+		op:      buf[0],
+		xid:     binary.BigEndian.Uint32(buf[4:8]),
+		flags:   binary.BigEndian.Uint16(buf[10:12]),
+		ciaddr:  net.IP(append([]byte{}, buf[12:16]...)),
+		yiaddr:  net.IP(append([]byte{}, buf[16:20]...)),
+		chaddr:  net.HardwareAddr(append([]byte{}, buf[28:28+hlen]...)),
+		options: map[byte][]byte{},
+	}
+
+	opts := buf[240:]
+	for len(opts) > 0 {
+		code := opts[0]
+		if code == optEnd {
+			break
+		}
+		if code == optPad {
+			opts = opts[1:]
+			continue
+		}
+		if len(opts) < 2 || len(opts) < 2+int(opts[1]) {
+			return nil, fmt.Errorf("truncated option %d", code)
+		}
+		m.options[code] = append([]byte{}, opts[2:2+int(opts[1])]...)
+		opts = opts[2+int(opts[1]):]
+	}
+
+	return &m, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dhcp
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	c := client{hwAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 1}}
+	msg := c.newMessage(typeRequest, "purelb-test", "svc-ns")
+	msg.options[optRequestedIP] = net.ParseIP("192.0.2.50").To4()
+
+	raw := msg.marshal()
+	assert.True(t, len(raw) >= minMessageLen, "message is too short")
+
+	parsed, err := unmarshal(raw)
+	assert.Nil(t, err, "unmarshal()")
+	assert.Equal(t, msg.xid, parsed.xid)
+	assert.Equal(t, flagBroadcast, parsed.flags)
+	assert.Equal(t, c.hwAddr, parsed.chaddr)
+	assert.Equal(t, typeRequest, parsed.messageType())
+	assert.Equal(t, append([]byte{0}, "purelb-test"...), parsed.options[optClientID])
+	assert.Equal(t, []byte("svc-ns"), parsed.options[optHostname])
+	assert.Equal(t, []byte{192, 0, 2, 50}, parsed.options[optRequestedIP])
+
+	_, err = unmarshal(raw[:200])
+	assert.Error(t, err, "unmarshal() accepted a truncated message")
+}
+
+// TestDnsmasq leases addresses from a dnsmasq server that runs in a
+// network namespace connected to ours by a veth pair. It needs root
+// and dnsmasq so it's skipped in short mode or if either is missing.
+func TestDnsmasq(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping dnsmasq test in short mode")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("dnsmasq test needs root")
+	}
+	dnsmasq, err := exec.LookPath("dnsmasq")
+	if err != nil {
+		t.Skip("dnsmasq test needs dnsmasq")
+	}
+
+	const (
+		ns      = "purelb-dhcp-test"
+		hostEnd = "pldhcp0"
+		nsEnd   = "pldhcp1"
+	)
+	run := func(args ...string) {
+		if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+			t.Fatalf("ip %v: %s: %s", args, err, out)
+		}
+	}
+	run("netns", "add", ns)
+	defer exec.Command("ip", "netns", "del", ns).Run()
+	run("link", "add", hostEnd, "type", "veth", "peer", "name", nsEnd)
+	defer exec.Command("ip", "link", "del", hostEnd).Run()
+	run("link", "set", nsEnd, "netns", ns)
+	run("-n", ns, "addr", "add", "192.0.2.1/24", "dev", nsEnd)
+	run("-n", ns, "link", "set", "up", "dev", nsEnd)
+	run("link", "set", "up", "dev", hostEnd)
+
+	dir, err := ioutil.TempDir("", "purelb-dhcp")
+	assert.Nil(t, err, "TempDir()")
+	defer os.RemoveAll(dir)
+	server := exec.Command("ip", "netns", "exec", ns, dnsmasq, "--no-daemon", "--conf-file=/dev/null",
+		"--port=0", "--interface="+nsEnd, "--bind-interfaces", "--dhcp-range=192.0.2.50,192.0.2.59,2m",
+		"--dhcp-leasefile="+filepath.Join(dir, "leases"), "--pid-file="+filepath.Join(dir, "pid"))
+	assert.Nil(t, server.Start(), "starting dnsmasq")
+	defer server.Process.Kill()
+	time.Sleep(time.Second) // give dnsmasq time to start listening
+
+	c, err := NewClient(hostEnd)
+	assert.Nil(t, err, "NewClient()")
+
+	lease1, err := c.Acquire("purelb-svc1", "svc1-test")
+	assert.Nil(t, err, "Acquire()")
+	assert.Equal(t, "192.0.2.1", lease1.Server.String())
+	assert.Equal(t, 2*time.Minute, lease1.Duration)
+	assert.True(t, lease1.RenewAfter > 0 && lease1.RenewAfter < lease1.Duration, "bad renewal time %s", lease1.RenewAfter)
+
+	// A different client ID gets a different address
+	lease2, err := c.Acquire("purelb-svc2", "svc2-test")
+	assert.Nil(t, err, "Acquire()")
+	assert.NotEqual(t, lease1.IP.String(), lease2.IP.String())
+
+	// Renewal keeps the same address
+	renewed, err := c.Renew("purelb-svc1", "svc1-test", lease1.IP)
+	assert.Nil(t, err, "Renew()")
+	assert.Equal(t, lease1.IP.String(), renewed.IP.String())
+
+	// The server won't renew another client's address
+	_, err = c.Renew("purelb-svc2", "svc2-test", lease1.IP)
+	assert.Error(t, err, "Renew() of another client's address succeeded")
+
+	// After a release the address is available again
+	assert.Nil(t, c.Release("purelb-svc1", lease1.IP, lease1.Server), "Release()")
+	time.Sleep(100 * time.Millisecond)
+	lease3, err := c.Acquire("purelb-svc3", "svc3-test")
+	assert.Nil(t, err, "Acquire()")
+	assert.Equal(t, lease1.IP.String(), lease3.IP.String())
+}
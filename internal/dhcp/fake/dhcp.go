@@ -0,0 +1,92 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"purelb.io/internal/dhcp"
+)
+
+// Server is the address of the imaginary DHCP server.
+var Server = net.ParseIP("192.168.1.1")
+
+// Client leases addresses from an imaginary DHCP server.
+type Client struct {
+	sync.Mutex
+
+	addresses []string
+	// Leases maps client IDs to leased addresses.
+	Leases map[string]string
+	// Renewals counts each client ID's renewals.
+	Renewals map[string]int
+	// Duration is the length of each lease.
+	Duration time.Duration
+}
+
+// NewClient returns a Client whose imaginary server leases
+// addresses.
+func NewClient(addresses ...string) *Client {
+	return &Client{addresses: addresses, Leases: map[string]string{}, Renewals: map[string]int{}, Duration: time.Hour}
+}
+
+// Acquire leases the first free address.
+func (c *Client) Acquire(clientID string, hostname string) (dhcp.Lease, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if addr, exists := c.Leases[clientID]; exists {
+		return c.lease(addr), nil
+	}
+Addresses:
+	for _, addr := range c.addresses {
+		for _, leased := range c.Leases {
+			if leased == addr {
+				continue Addresses
+			}
+		}
+		c.Leases[clientID] = addr
+		return c.lease(addr), nil
+	}
+	return dhcp.Lease{}, fmt.Errorf("no DHCP offer")
+}
+
+// Renew extends clientID's lease on ip.
+func (c *Client) Renew(clientID string, hostname string, ip net.IP) (dhcp.Lease, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Leases[clientID] != ip.String() {
+		return dhcp.Lease{}, fmt.Errorf("DHCP server refused %s", ip)
+	}
+	c.Renewals[clientID]++
+	return c.lease(ip.String()), nil
+}
+
+// Release frees clientID's lease.
+func (c *Client) Release(clientID string, ip net.IP, server net.IP) error {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.Leases, clientID)
+	return nil
+}
+
+func (c *Client) lease(addr string) dhcp.Lease {
+	return dhcp.Lease{IP: net.ParseIP(addr), Server: Server, Duration: c.Duration, RenewAfter: c.Duration / 2}
+}
@@ -18,6 +18,7 @@ import (
 	"crypto/sha256"
 	"log"
 	"sort"
+	"sync"
 	"time"
 
 	"purelb.io/internal/k8s"
@@ -41,6 +42,7 @@ type Config struct {
 
 type Election struct {
 	namespace  string
+	labelsLock *sync.Mutex
 	labels     string
 	Memberlist *memberlist.Memberlist
 	logger     gokitlog.Logger
@@ -50,7 +52,7 @@ type Election struct {
 }
 
 func New(cfg *Config) (Election, error) {
-	election := Election{stopCh: cfg.StopCh, logger: *cfg.Logger}
+	election := Election{stopCh: cfg.StopCh, logger: *cfg.Logger, labelsLock: &sync.Mutex{}}
 
 	mconfig := memberlist.DefaultLANConfig()
 	mconfig.Name = cfg.NodeName
@@ -102,7 +104,10 @@ func (e *Election) shutdown() error {
 // that will announce the service represented by "key".
 func (e *Election) Winner(key string) string {
 	members := e.Memberlist.Members()
-	pods, err := e.Client.GetPodsIPs(e.namespace, e.labels)
+	e.labelsLock.Lock()
+	labels := e.labels
+	e.labelsLock.Unlock()
+	pods, err := e.Client.GetPodsIPs(e.namespace, labels)
 	if err != nil {
 		e.logger.Log("op", "Election", "error", err, "msg", "failed to get Pod count")
 	}
@@ -122,6 +127,14 @@ func (e *Election) Winner(key string) string {
 	return election(key, nodes)[0]
 }
 
+// SetLabels changes the labels that select the node agent pods that
+// should be in the memberlist.
+func (e *Election) SetLabels(labels string) {
+	e.labelsLock.Lock()
+	defer e.labelsLock.Unlock()
+	e.labels = labels
+}
+
 // election conducts an election among the candidates based on the
 // provided key. The order of the candidates in the return array is
 // the result of the election.
@@ -0,0 +1,262 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Record is a record that the fake WAPI server has created.
+type Record struct {
+	Type      string
+	Name      string
+	Comment   string
+	ExtAttrs  map[string]string
+	Addresses []string
+}
+
+// Server is a fake Infoblox WAPI server. It implements the small
+// subset of WAPI that PureLB uses: creating host and fixed address
+// records using func:nextavailableip, deleting records, and looking
+// up records by address. Wrap it in an httptest.Server to use it in
+// tests.
+type Server struct {
+	sync.Mutex
+
+	records   map[string]*Record // ref -> record
+	allocated map[string]string  // ip.String() -> ref
+	serial    int
+}
+
+// wapiObject is the subset of WAPI object fields that the server
+// understands.
+type wapiObject struct {
+	Name     string `json:"name"`
+	Comment  string `json:"comment"`
+	ExtAttrs map[string]struct {
+		Value string `json:"value"`
+	} `json:"extattrs"`
+	IPv4Addrs []struct {
+		IPv4Addr string `json:"ipv4addr"`
+	} `json:"ipv4addrs"`
+	IPv6Addrs []struct {
+		IPv6Addr string `json:"ipv6addr"`
+	} `json:"ipv6addrs"`
+	IPv4Addr string `json:"ipv4addr"`
+	IPv6Addr string `json:"ipv6addr"`
+}
+
+// NewServer returns an empty fake WAPI server.
+func NewServer() *Server {
+	return &Server{records: map[string]*Record{}, allocated: map[string]string{}}
+}
+
+// Record returns the record that holds addr, if there is one.
+func (s *Server) Record(addr string) (Record, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	ref, exists := s.allocated[addr]
+	if !exists {
+		return Record{}, false
+	}
+	return *s.records[ref], true
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	// Strip the "wapi/vX.Y/" prefix, if any
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if parts := strings.SplitN(path, "/", 3); len(parts) == 3 && parts[0] == "wapi" {
+		path = parts[2]
+	}
+
+	switch {
+	case r.Method == http.MethodPost && (path == "record:host" || path == "fixedaddress" || path == "ipv6fixedaddress"):
+		s.create(w, r, path)
+	case r.Method == http.MethodGet && (path == "ipv4address" || path == "ipv6address"):
+		addr := r.URL.Query().Get("ip_address")
+		objects := []string{}
+		if ref, exists := s.allocated[addr]; exists {
+			objects = append(objects, ref)
+		}
+		reply(w, http.StatusOK, []map[string]interface{}{{"_ref": path + "/" + addr, "objects": objects}})
+	case r.Method == http.MethodDelete:
+		record, exists := s.records[path]
+		if !exists {
+			wapiError(w, http.StatusNotFound, "Reference "+path+" not found")
+			return
+		}
+		for _, addr := range record.Addresses {
+			delete(s.allocated, addr)
+		}
+		delete(s.records, path)
+		reply(w, http.StatusOK, path)
+	default:
+		wapiError(w, http.StatusBadRequest, fmt.Sprintf("unsupported request %s %s", r.Method, path))
+	}
+}
+
+// create creates a record of type objType. Caller must hold the lock.
+func (s *Server) create(w http.ResponseWriter, r *http.Request, objType string) {
+	in := wapiObject{}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		wapiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.serial++
+	ref := fmt.Sprintf("%s/ZG5zLmZha2U%d:%s/default", objType, s.serial, in.Name)
+	record := Record{Type: objType, Name: in.Name, Comment: in.Comment, ExtAttrs: map[string]string{}}
+	for k, v := range in.ExtAttrs {
+		record.ExtAttrs[k] = v.Value
+	}
+
+	// Collect the address functions from whichever fields the object
+	// type uses
+	funcs := []string{}
+	for _, addr := range in.IPv4Addrs {
+		funcs = append(funcs, addr.IPv4Addr)
+	}
+	for _, addr := range in.IPv6Addrs {
+		funcs = append(funcs, addr.IPv6Addr)
+	}
+	if in.IPv4Addr != "" {
+		funcs = append(funcs, in.IPv4Addr)
+	}
+	if in.IPv6Addr != "" {
+		funcs = append(funcs, in.IPv6Addr)
+	}
+	if len(funcs) == 0 {
+		wapiError(w, http.StatusBadRequest, "no addresses requested")
+		return
+	}
+
+	for _, f := range funcs {
+		addr, err := s.nextAvailable(f, record.Addresses)
+		if err != nil {
+			wapiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		record.Addresses = append(record.Addresses, addr)
+	}
+
+	s.records[ref] = &record
+	out := map[string]interface{}{"_ref": ref}
+	for _, addr := range record.Addresses {
+		s.allocated[addr] = ref
+		v6 := net.ParseIP(addr).To4() == nil
+		switch {
+		case objType == "record:host" && v6:
+			out["ipv6addrs"] = []map[string]string{{"ipv6addr": addr}}
+		case objType == "record:host":
+			out["ipv4addrs"] = []map[string]string{{"ipv4addr": addr}}
+		case v6:
+			out["ipv6addr"] = addr
+		default:
+			out["ipv4addr"] = addr
+		}
+	}
+	reply(w, http.StatusCreated, out)
+}
+
+// nextAvailable evaluates a "func:nextavailableip:<pool>,<view>"
+// call, skipping any addresses in skip. Caller must hold the lock.
+func (s *Server) nextAvailable(call string, skip []string) (string, error) {
+	if !strings.HasPrefix(call, "func:nextavailableip:") {
+		return "", fmt.Errorf("unsupported address %q", call)
+	}
+	pool := strings.SplitN(strings.TrimPrefix(call, "func:nextavailableip:"), ",", 2)[0]
+
+	var first, last net.IP
+	if strings.Contains(pool, "-") {
+		ends := strings.SplitN(pool, "-", 2)
+		first, last = net.ParseIP(strings.TrimSpace(ends[0])), net.ParseIP(strings.TrimSpace(ends[1]))
+	} else if _, cidr, err := net.ParseCIDR(pool); err == nil {
+		// Like Infoblox, skip the network and broadcast addresses
+		first = next(cidr.IP)
+		last = dup(cidr.IP)
+		for i := range last {
+			last[i] |= ^cidr.Mask[i]
+		}
+		if first.To4() != nil {
+			last = prev(last)
+		}
+	}
+	if first == nil || last == nil {
+		return "", fmt.Errorf("invalid network or range %q", pool)
+	}
+
+Addresses:
+	for ip := first; bytes.Compare(ip.To16(), last.To16()) <= 0; ip = next(ip) {
+		if _, used := s.allocated[ip.String()]; used {
+			continue
+		}
+		for _, skipped := range skip {
+			if ip.String() == skipped {
+				continue Addresses
+			}
+		}
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("Cannot find 1 available IP address(es) in %s", pool)
+}
+
+func next(ip net.IP) net.IP {
+	n := dup(ip)
+	for i := len(n) - 1; i >= 0; i-- {
+		n[i]++
+		if n[i] > 0 {
+			break
+		}
+	}
+	return n
+}
+
+func prev(ip net.IP) net.IP {
+	p := dup(ip)
+	for i := len(p) - 1; i >= 0; i-- {
+		p[i]--
+		if p[i] != 0xff {
+			break
+		}
+	}
+	return p
+}
+
+func dup(ip net.IP) net.IP {
+	d := make(net.IP, len(ip))
+	copy(d, ip)
+	return d
+}
+
+func reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func wapiError(w http.ResponseWriter, status int, text string) {
+	reply(w, status, map[string]string{"Error": "AdmConProtoError: " + text, "text": text})
+}
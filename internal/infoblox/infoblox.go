@@ -0,0 +1,288 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infoblox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// maxResponseBody is the largest response body that we'll read.
+	maxResponseBody = 1 << 20
+)
+
+// Infoblox is a connection to an Infoblox grid's WAPI.
+type Infoblox interface {
+	CreateHost(name string, v4Pool string, v6Pool string, comment string, extAttrs map[string]string) (Record, error)
+	CreateFixedAddress(pool string, name string, duid string, comment string, extAttrs map[string]string) (Record, error)
+	Delete(ref string) error
+	Lookup(addr string) ([]string, error)
+}
+
+// Record is an Infoblox object that holds one or more addresses.
+type Record struct {
+	Ref       string
+	Addresses []string
+}
+
+// infoblox represents a connection to an
+// [Infoblox](https://www.infoblox.com/) WAPI endpoint.
+type infoblox struct {
+	http http.Client
+	// The base URL of the WAPI, e.g., "https://gm/wapi/v2.11/".
+	base string
+	// The network view that contains our pools.
+	view string
+	// The credentials that PureLB uses to authenticate.
+	username string
+	password string
+}
+
+// extAttr is the WAPI representation of an extensible attribute
+// value.
+type extAttr struct {
+	Value string `json:"value"`
+}
+
+type hostAddr struct {
+	IPv4Addr string `json:"ipv4addr,omitempty"`
+	IPv6Addr string `json:"ipv6addr,omitempty"`
+}
+
+// object is the subset of WAPI object fields that we read and write.
+type object struct {
+	Ref             string             `json:"_ref,omitempty"`
+	Name            string             `json:"name,omitempty"`
+	NetworkView     string             `json:"network_view,omitempty"`
+	Comment         string             `json:"comment,omitempty"`
+	ExtAttrs        map[string]extAttr `json:"extattrs,omitempty"`
+	ConfigureForDNS *bool              `json:"configure_for_dns,omitempty"`
+	IPv4Addrs       []hostAddr         `json:"ipv4addrs,omitempty"`
+	IPv6Addrs       []hostAddr         `json:"ipv6addrs,omitempty"`
+	IPv4Addr        string             `json:"ipv4addr,omitempty"`
+	IPv6Addr        string             `json:"ipv6addr,omitempty"`
+	MatchClient     string             `json:"match_client,omitempty"`
+	Mac             string             `json:"mac,omitempty"`
+	DUID            string             `json:"duid,omitempty"`
+}
+
+// addressObject is the WAPI ipv4address/ipv6address object, which
+// lists the objects that refer to an address.
+type addressObject struct {
+	Objects []string `json:"objects"`
+}
+
+// wapiError is the body of a WAPI error response.
+type wapiError struct {
+	Error string `json:"Error"`
+	Text  string `json:"text"`
+}
+
+// NewInfoblox configures a new connection to an Infoblox WAPI
+// endpoint.
+func NewInfoblox(base string, view string, username string, password string) Infoblox {
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return &infoblox{http: http.Client{Timeout: 30 * time.Second}, base: base, view: view, username: username, password: password}
+}
+
+// CreateHost creates a host record with one address from each of
+// v4Pool and v6Pool. Either pool can be empty, but not both. The
+// record isn't added to DNS.
+func (i *infoblox) CreateHost(name string, v4Pool string, v6Pool string, comment string, extAttrs map[string]string) (Record, error) {
+	noDNS := false
+	host := object{
+		Name:            name,
+		NetworkView:     i.view,
+		Comment:         comment,
+		ExtAttrs:        wapiExtAttrs(extAttrs),
+		ConfigureForDNS: &noDNS,
+	}
+	if v4Pool != "" {
+		host.IPv4Addrs = []hostAddr{{IPv4Addr: i.nextAvailable(v4Pool)}}
+	}
+	if v6Pool != "" {
+		host.IPv6Addrs = []hostAddr{{IPv6Addr: i.nextAvailable(v6Pool)}}
+	}
+
+	created := object{}
+	if err := i.do(http.MethodPost, "record:host", url.Values{"_return_fields": {"ipv4addrs,ipv6addrs"}}, host, &created); err != nil {
+		return Record{}, err
+	}
+
+	record := Record{Ref: created.Ref}
+	for _, addr := range created.IPv4Addrs {
+		record.Addresses = append(record.Addresses, addr.IPv4Addr)
+	}
+	for _, addr := range created.IPv6Addrs {
+		record.Addresses = append(record.Addresses, addr.IPv6Addr)
+	}
+	return record, nil
+}
+
+// CreateFixedAddress creates a fixed address record with an address
+// from pool. IPV4 records are "reserved" so they don't need a MAC
+// address. IPV6 records need a DUID so the caller must provide one
+// that is unique.
+func (i *infoblox) CreateFixedAddress(pool string, name string, duid string, comment string, extAttrs map[string]string) (Record, error) {
+	fixed := object{
+		Name:        name,
+		NetworkView: i.view,
+		Comment:     comment,
+		ExtAttrs:    wapiExtAttrs(extAttrs),
+	}
+
+	var objType string
+	if IsV6(pool) {
+		objType = "ipv6fixedaddress"
+		fixed.IPv6Addr = i.nextAvailable(pool)
+		fixed.DUID = duid
+	} else {
+		objType = "fixedaddress"
+		fixed.IPv4Addr = i.nextAvailable(pool)
+		fixed.MatchClient = "RESERVED"
+		fixed.Mac = "00:00:00:00:00:00"
+	}
+
+	created := object{}
+	if err := i.do(http.MethodPost, objType, url.Values{"_return_fields": {"ipv4addr,ipv6addr"}}, fixed, &created); err != nil {
+		return Record{}, err
+	}
+
+	record := Record{Ref: created.Ref}
+	if created.IPv4Addr != "" {
+		record.Addresses = append(record.Addresses, created.IPv4Addr)
+	}
+	if created.IPv6Addr != "" {
+		record.Addresses = append(record.Addresses, created.IPv6Addr)
+	}
+	return record, nil
+}
+
+// Delete deletes the object referred to by ref.
+func (i *infoblox) Delete(ref string) error {
+	return i.do(http.MethodDelete, ref, nil, nil, nil)
+}
+
+// Lookup returns the references of the host and fixed address
+// records that hold addr. We use it to release addresses when we
+// don't know the record's reference, e.g., after a restart.
+func (i *infoblox) Lookup(addr string) ([]string, error) {
+	objType := "ipv4address"
+	if IsV6(addr) {
+		objType = "ipv6address"
+	}
+
+	found := []addressObject{}
+	query := url.Values{"ip_address": {addr}, "network_view": {i.view}, "_return_fields": {"objects"}}
+	if err := i.do(http.MethodGet, objType, query, nil, &found); err != nil {
+		return nil, err
+	}
+
+	refs := []string{}
+	for _, address := range found {
+		for _, ref := range address.Objects {
+			if strings.HasPrefix(ref, "record:host/") || strings.HasPrefix(ref, "fixedaddress/") || strings.HasPrefix(ref, "ipv6fixedaddress/") {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// nextAvailable returns the WAPI function call that allocates the
+// next available address from pool.
+func (i *infoblox) nextAvailable(pool string) string {
+	return fmt.Sprintf("func:nextavailableip:%s,%s", pool, i.view)
+}
+
+// do sends a WAPI request. If in is non-nil then it's sent as the
+// request body, and if out is non-nil then the response body is
+// decoded into it.
+func (i *infoblox) do(verb string, path string, query url.Values, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(verb, i.base+path, body)
+	if err != nil {
+		return err
+	}
+	req.URL.RawQuery = query.Encode()
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("accept", "application/json")
+	req.SetBasicAuth(i.username, i.password)
+
+	resp, err := i.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		wErr := wapiError{}
+		if json.Unmarshal(raw, &wErr) == nil && wErr.Text != "" {
+			return fmt.Errorf("%s %s failed: %s (%s)", verb, path, wErr.Text, resp.Status)
+		}
+		return fmt.Errorf("%s %s failed: %s", verb, path, resp.Status)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("%s %s response unparseable: %w", verb, path, err)
+		}
+	}
+	return nil
+}
+
+// IsV6 indicates whether addr, which can be an address, a CIDR, or a
+// from-to range, is IPV6.
+func IsV6(addr string) bool {
+	first := strings.TrimSpace(strings.SplitN(addr, "-", 2)[0])
+	first = strings.SplitN(first, "/", 2)[0]
+	ip := net.ParseIP(first)
+	return ip != nil && ip.To4() == nil
+}
+
+func wapiExtAttrs(attrs map[string]string) map[string]extAttr {
+	if len(attrs) == 0 {
+		return nil
+	}
+	ret := map[string]extAttr{}
+	for k, v := range attrs {
+		ret[k] = extAttr{Value: v}
+	}
+	return ret
+}
@@ -18,10 +18,14 @@ package k8s
 import (
 	"fmt"
 	"os"
+	"reflect"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -39,7 +43,18 @@ import (
 	listers "purelb.io/pkg/generated/listers/apis/v1"
 )
 
-const controllerAgentName = "cr-controller"
+const (
+	controllerAgentName = "cr-controller"
+
+	// defaultGroup is the ServiceGroup that services get addresses
+	// from if they don't ask for a group and the PureLBConfig doesn't
+	// say otherwise.
+	defaultGroup = "default"
+
+	// defaultMemberlistPort is the port on which the node agents'
+	// memberlists talk if the PureLBConfig doesn't say otherwise.
+	defaultMemberlistPort = 7934
+)
 
 // Controller is the controller implementation for ServiceGroup
 // resources.
@@ -55,6 +70,12 @@ type Controller struct {
 	sgLister    listers.ServiceGroupLister
 	lbnasSynced cache.InformerSynced
 	lbnaLister  listers.LBNodeAgentLister
+	quotaSynced cache.InformerSynced
+	quotaLister listers.PureLBQuotaLister
+	resvSynced  cache.InformerSynced
+	resvLister  listers.AddressReservationLister
+	plcSynced   cache.InformerSynced
+	plcLister   listers.PureLBConfigLister
 
 	// workqueue is a rate limited work queue. This is used to queue
 	// work to be processed instead of performing it as soon as a change
@@ -81,6 +102,9 @@ func NewCRController(
 
 	sgInformer := informerFactory.Purelb().V1().ServiceGroups()
 	lbnaInformer := informerFactory.Purelb().V1().LBNodeAgents()
+	quotaInformer := informerFactory.Purelb().V1().PureLBQuotas()
+	resvInformer := informerFactory.Purelb().V1().AddressReservations()
+	plcInformer := informerFactory.Purelb().V1().PureLBConfigs()
 
 	// Create event broadcaster
 	// Add cr-controller types to the default Kubernetes Scheme so Events can be
@@ -100,6 +124,12 @@ func NewCRController(
 		lbnasSynced:     lbnaInformer.Informer().HasSynced,
 		sgLister:        sgInformer.Lister(),
 		sgsSynced:       sgInformer.Informer().HasSynced,
+		quotaLister:     quotaInformer.Lister(),
+		quotaSynced:     quotaInformer.Informer().HasSynced,
+		resvLister:      resvInformer.Lister(),
+		resvSynced:      resvInformer.Informer().HasSynced,
+		plcLister:       plcInformer.Lister(),
+		plcSynced:       plcInformer.Informer().HasSynced,
 		workqueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "ServiceGroups"),
 		recorder:        recorder,
 	}
@@ -110,7 +140,9 @@ func NewCRController(
 			controller.enqueueResource("sg", added)
 		},
 		UpdateFunc: func(old, new interface{}) {
-			controller.enqueueResource("sg", new)
+			if specChanged(old, new) {
+				controller.enqueueResource("sg", new)
+			}
 		},
 		DeleteFunc: func(deleted interface{}) {
 			controller.enqueueResource("sg", deleted)
@@ -127,6 +159,45 @@ func NewCRController(
 			controller.enqueueResource("lbna", deleted)
 		},
 	})
+	quotaInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(added interface{}) {
+			controller.enqueueResource("quota", added)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			if specChanged(old, new) {
+				controller.enqueueResource("quota", new)
+			}
+		},
+		DeleteFunc: func(deleted interface{}) {
+			controller.enqueueResource("quota", deleted)
+		},
+	})
+	resvInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(added interface{}) {
+			controller.enqueueResource("resv", added)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			if specChanged(old, new) {
+				controller.enqueueResource("resv", new)
+			}
+		},
+		DeleteFunc: func(deleted interface{}) {
+			controller.enqueueResource("resv", deleted)
+		},
+	})
+	plcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(added interface{}) {
+			controller.enqueueResource("plc", added)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			if specChanged(old, new) {
+				controller.enqueueResource("plc", new)
+			}
+		},
+		DeleteFunc: func(deleted interface{}) {
+			controller.enqueueResource("plc", deleted)
+		},
+	})
 
 	return controller
 }
@@ -141,7 +212,7 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) error {
 	defer c.workqueue.ShutDown()
 
 	// Wait for the caches to be synced before starting workers
-	if ok := cache.WaitForCacheSync(stopCh, c.sgsSynced, c.lbnasSynced); !ok {
+	if ok := cache.WaitForCacheSync(stopCh, c.sgsSynced, c.lbnasSynced, c.quotaSynced, c.resvSynced, c.plcSynced); !ok {
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
 
@@ -229,21 +300,72 @@ func (c *Controller) syncHandler() error {
 		cfg purelbv1.Config = purelbv1.Config{}
 	)
 
-	cfg.Groups, err = c.sgLister.ServiceGroups("").List(labels.Everything())
+	// Find out which LoadBalancerClass we handle
+	cfg.LBClass = LBClass()
+
+	// We use only the resources in our class, so several instances of
+	// PureLB can run in the same cluster
+	groups, err := c.sgLister.ServiceGroups("").List(labels.Everything())
 	if err != nil {
 		c.logger.Log("error listing service groups", err)
 		return err
 	}
-	cfg.Agents, err = c.lbnaLister.LBNodeAgents("").List(labels.Everything())
+	for _, group := range groups {
+		if purelbv1.InClass(group.Spec.LBClass, cfg.LBClass) {
+			cfg.Groups = append(cfg.Groups, group)
+		}
+	}
+	agents, err := c.lbnaLister.LBNodeAgents("").List(labels.Everything())
 	if err != nil {
 		c.logger.Log("error listing node agents", err)
 		return err
 	}
+	for _, agent := range agents {
+		if purelbv1.InClass(agent.Spec.LBClass, cfg.LBClass) {
+			cfg.Agents = append(cfg.Agents, agent)
+		}
+	}
+	quotas, err := c.quotaLister.PureLBQuotas("").List(labels.Everything())
+	if err != nil {
+		c.logger.Log("error listing quotas", err)
+		return err
+	}
+	for _, quota := range quotas {
+		if purelbv1.InClass(quota.Spec.LBClass, cfg.LBClass) {
+			cfg.Quotas = append(cfg.Quotas, quota)
+		}
+	}
+
+	// Reservations belong to the class of their group. We keep the
+	// ones whose group doesn't exist so we can complain about them.
+	otherGroups := map[string]bool{}
+	for _, group := range groups {
+		if !purelbv1.InClass(group.Spec.LBClass, cfg.LBClass) {
+			otherGroups[group.Name] = true
+		}
+	}
+	reservations, err := c.resvLister.AddressReservations("").List(labels.Everything())
+	if err != nil {
+		c.logger.Log("error listing reservations", err)
+		return err
+	}
+	for _, resv := range reservations {
+		if !otherGroups[resv.Spec.ServiceGroup] {
+			cfg.Reservations = append(cfg.Reservations, resv)
+		}
+	}
 
-	// Check whether we should be the default service announcer
-	if os.Getenv("DEFAULT_ANNOUNCER") == purelbv1.Brand {
-		cfg.DefaultAnnouncer = true
+	// Read the cluster-wide settings
+	plcs, err := c.plcLister.List(labels.Everything())
+	if err != nil {
+		c.logger.Log("error listing PureLBConfigs", err)
+		return err
+	}
+	plc, ignored := classConfig(plcs, cfg.LBClass)
+	for _, name := range ignored {
+		c.logger.Log("op", "syncHandler", "msg", "ignoring PureLBConfig, there's another one in our class", "name", name, "using", plc.Name)
 	}
+	setGlobals(&cfg, plc)
 
 	switch c.configCB(&cfg) {
 	case SyncStateSuccess:
@@ -258,10 +380,86 @@ func (c *Controller) syncHandler() error {
 	return nil
 }
 
+// LBClass returns the LoadBalancerClass that this instance of PureLB
+// handles.
+func LBClass() string {
+	if class := os.Getenv("PURELB_LB_CLASS"); class != "" {
+		return class
+	}
+	return purelbv1.ServiceLBClass
+}
+
+// classConfig returns the PureLBConfig that belongs to class, or nil
+// if there isn't one. There should be only one per class but if there
+// are more then we use the first by name and return the names of the
+// others.
+func classConfig(plcs []*purelbv1.PureLBConfig, class string) (*purelbv1.PureLBConfig, []string) {
+	var (
+		plc     *purelbv1.PureLBConfig
+		ignored []string
+	)
+	sort.Slice(plcs, func(i, j int) bool { return plcs[i].Name < plcs[j].Name })
+	for _, candidate := range plcs {
+		if !purelbv1.InClass(candidate.Spec.LBClass, class) {
+			continue
+		}
+		if plc == nil {
+			plc = candidate
+		} else {
+			ignored = append(ignored, candidate.Name)
+		}
+	}
+	return plc, ignored
+}
+
+// setGlobals sets cfg's cluster-wide settings from plc, which can be
+// nil. Settings that plc doesn't specify come from our environment
+// variables.
+func setGlobals(cfg *purelbv1.Config, plc *purelbv1.PureLBConfig) {
+	cfg.DefaultAnnouncer = os.Getenv("DEFAULT_ANNOUNCER") == purelbv1.Brand
+	cfg.DefaultGroup = defaultGroup
+	cfg.Memberlist = purelbv1.PureLBConfigMemberlistSpec{
+		Port:      memberlistPort(),
+		Labels:    os.Getenv("PURELB_ML_LABELS"),
+		SecretKey: os.Getenv("ML_GROUP"),
+	}
+
+	if plc == nil {
+		return
+	}
+	if plc.Spec.DefaultAnnouncer != nil {
+		cfg.DefaultAnnouncer = *plc.Spec.DefaultAnnouncer
+	}
+	if plc.Spec.DefaultGroup != "" {
+		cfg.DefaultGroup = plc.Spec.DefaultGroup
+	}
+	if ml := plc.Spec.Memberlist; ml != nil {
+		if ml.Port != 0 {
+			cfg.Memberlist.Port = ml.Port
+		}
+		if ml.Labels != "" {
+			cfg.Memberlist.Labels = ml.Labels
+		}
+		if ml.SecretKey != "" {
+			cfg.Memberlist.SecretKey = ml.SecretKey
+		}
+	}
+}
+
+// memberlistPort returns the memberlist port from our environment, or
+// the default if it's not set or invalid.
+func memberlistPort() int {
+	port, err := strconv.Atoi(os.Getenv("PURELB_ML_PORT"))
+	if err != nil || port <= 0 || port > 65535 {
+		return defaultMemberlistPort
+	}
+	return port
+}
+
 // enqueueResource takes a resource and converts it into a
 // thing/namespace/name string which is then put onto the work
 // queue. This method should *not* be passed resources of any type
-// other than ServiceGroup or LBNodeAgent.
+// other than our custom resources.
 func (c *Controller) enqueueResource(thing string, obj interface{}) {
 	var key string
 	var err error
@@ -271,3 +469,15 @@ func (c *Controller) enqueueResource(thing string, obj interface{}) {
 	}
 	c.workqueue.Add(thing + "/" + key)
 }
+
+// specChanged indicates whether an update changed anything that
+// PureLB reads. The allocator updates some resources' status, which
+// doesn't change their generation, so those updates are ignored.
+func specChanged(old, new interface{}) bool {
+	oldMeta, oldOK := old.(metav1.Object)
+	newMeta, newOK := new.(metav1.Object)
+	if !oldOK || !newOK {
+		return true
+	}
+	return oldMeta.GetGeneration() != newMeta.GetGeneration() || !reflect.DeepEqual(oldMeta.GetLabels(), newMeta.GetLabels())
+}
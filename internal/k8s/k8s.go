@@ -17,6 +17,7 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -27,10 +28,16 @@ import (
 	"purelb.io/pkg/generated/informers/externalversions"
 
 	"github.com/go-kit/kit/log"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -46,15 +53,22 @@ import (
 type Client struct {
 	logger log.Logger
 
-	client *kubernetes.Clientset
-	events record.EventRecorder
-	queue  workqueue.RateLimitingInterface
+	client   *kubernetes.Clientset
+	crClient versioned.Interface
+	events   record.EventRecorder
+	queue    workqueue.RateLimitingInterface
+
+	// fieldManager identifies us in the services' managed fields.
+	fieldManager string
 
 	svcIndexer  cache.Indexer
 	svcInformer cache.Controller
 	epIndexer   cache.Indexer
 	epInformer  cache.Controller
 
+	nodeIndexer  cache.Indexer
+	nodeInformer cache.Controller
+
 	crInformerFactory externalversions.SharedInformerFactory
 	crController      Controller
 
@@ -72,6 +86,12 @@ type ServiceEvent interface {
 	Infof(obj runtime.Object, desc, msg string, args ...interface{})
 	Errorf(obj runtime.Object, desc, msg string, args ...interface{})
 	ForceSync()
+	ResyncService(nsName string)
+	Services() []*corev1.Service
+	Nodes(selector labels.Selector) ([]corev1.Node, error)
+	CanUseGroup(namespace string, group string) (bool, error)
+	UpdateQuotaStatus(quota *purelbv1.PureLBQuota) error
+	UpdateGroupStatus(group *purelbv1.ServiceGroup) error
 }
 
 // SyncState is the result of calling synchronization callbacks.
@@ -94,6 +114,7 @@ type Config struct {
 	ProcessName   string
 	NodeName      string
 	ReadEndpoints bool
+	ReadNodes     bool
 	Logger        log.Logger
 	Kubeconfig    string
 
@@ -137,10 +158,13 @@ func New(cfg *Config) (*Client, error) {
 	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
 
 	c := &Client{
-		logger: cfg.Logger,
-		client: clientset,
-		events: recorder,
-		queue:  queue,
+		logger:   cfg.Logger,
+		client:   clientset,
+		crClient: crClient,
+		events:   recorder,
+		queue:    queue,
+
+		fieldManager: cfg.ProcessName,
 	}
 
 	// Custom Resource Watcher
@@ -206,6 +230,32 @@ func New(cfg *Config) (*Client, error) {
 		c.syncFuncs = append(c.syncFuncs, c.epInformer.HasSynced)
 	}
 
+	// Node Watcher (used by the allocator's nodes pools). Node status
+	// changes often so we only care about changes to the nodes'
+	// addresses and labels, and then we reprocess the services since
+	// some of them might be waiting for node addresses.
+
+	if cfg.ReadNodes {
+		nodeHandlers := cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				c.ForceSync()
+			},
+			UpdateFunc: func(old interface{}, new interface{}) {
+				oldNode, newNode := old.(*corev1.Node), new.(*corev1.Node)
+				if !reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses) || !reflect.DeepEqual(oldNode.Labels, newNode.Labels) {
+					c.ForceSync()
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				c.ForceSync()
+			},
+		}
+		nodeWatcher := cache.NewListWatchFromClient(c.client.CoreV1().RESTClient(), "nodes", corev1.NamespaceAll, fields.Everything())
+		c.nodeIndexer, c.nodeInformer = cache.NewIndexerInformer(nodeWatcher, &corev1.Node{}, 0, nodeHandlers, cache.Indexers{})
+
+		c.syncFuncs = append(c.syncFuncs, c.nodeInformer.HasSynced)
+	}
+
 	// Sync Watcher
 
 	c.synced = cfg.Synced
@@ -256,6 +306,9 @@ func (c *Client) Run(stopCh <-chan struct{}) error {
 	if c.epInformer != nil {
 		go c.epInformer.Run(stopCh)
 	}
+	if c.nodeInformer != nil {
+		go c.nodeInformer.Run(stopCh)
+	}
 
 	if !cache.WaitForCacheSync(stopCh, c.syncFuncs...) {
 		return errors.New("timed out waiting for cache sync")
@@ -301,32 +354,158 @@ func (c *Client) ForceSync() {
 	}
 }
 
-// maybeUpdateService writes the "is" service back to the cluster, but
-// only if it's different than the "was" service.
-func (c *Client) maybeUpdateService(was, is *corev1.Service) error {
-	var (
-		svcUpdated *corev1.Service
-		err        error
-	)
+// ResyncService reprocesses one watched service. nsName is the
+// service's namespaced name, e.g., "purelb/example".
+func (c *Client) ResyncService(nsName string) {
+	c.queue.Add(svcKey(nsName))
+}
 
-	if !reflect.DeepEqual(was.Status, is.Status) {
-		svcUpdated, err = c.client.CoreV1().Services(is.Namespace).UpdateStatus(context.TODO(), is, metav1.UpdateOptions{})
-		if err != nil {
+// Services returns the services in the client's cache. The services
+// are shared with the cache so callers must not modify them.
+func (c *Client) Services() []*corev1.Service {
+	svcs := []*corev1.Service{}
+	if c.svcIndexer != nil {
+		for _, obj := range c.svcIndexer.List() {
+			svcs = append(svcs, obj.(*corev1.Service))
+		}
+	}
+	return svcs
+}
+
+// Nodes lists the cluster's nodes that match selector. If the client
+// watches the nodes then they come from its cache, otherwise we ask
+// the API server.
+func (c *Client) Nodes(selector labels.Selector) ([]corev1.Node, error) {
+	if c.nodeIndexer != nil {
+		if !c.nodeInformer.HasSynced() {
+			return nil, errors.New("the node cache hasn't synced yet")
+		}
+		nodes := []corev1.Node{}
+		for _, obj := range c.nodeIndexer.List() {
+			node := obj.(*corev1.Node)
+			if selector.Matches(labels.Set(node.Labels)) {
+				nodes = append(nodes, *node)
+			}
+		}
+		return nodes, nil
+	}
+
+	nodes, err := c.client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return nodes.Items, nil
+}
+
+// CanUseGroup asks the API server whether the default service account
+// of namespace is allowed to "use" the ServiceGroup named group. The
+// permission is granted with normal RBAC Roles and RoleBindings in
+// namespace, e.g., a Role with the "use" verb on "servicegroups" in
+// the "purelb.io" API group.
+func (c *Client) CanUseGroup(namespace string, group string) (bool, error) {
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User: fmt.Sprintf("system:serviceaccount:%s:default", namespace),
+			Groups: []string{
+				"system:serviceaccounts",
+				"system:serviceaccounts:" + namespace,
+				"system:authenticated",
+			},
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "use",
+				Group:     purelbv1.SchemeGroupVersion.Group,
+				Resource:  "servicegroups",
+				Name:      group,
+			},
+		},
+	}
+	review, err := c.client.AuthorizationV1().SubjectAccessReviews().Create(context.TODO(), review, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
+
+// UpdateQuotaStatus writes quota's status to the cluster. It patches
+// the status subresource so it works even if quota is a stale copy.
+func (c *Client) UpdateQuotaStatus(quota *purelbv1.PureLBQuota) error {
+	patch, err := statusPatch(quota.Status)
+	if err != nil {
+		return err
+	}
+	_, err = c.crClient.PurelbV1().PureLBQuotas(quota.Namespace).Patch(context.TODO(), quota.Name, types.JSONPatchType, patch, metav1.PatchOptions{FieldManager: c.fieldManager}, "status")
+	return err
+}
+
+// UpdateGroupStatus writes group's status to the cluster. It patches
+// the status subresource so it works even if group is a stale copy.
+func (c *Client) UpdateGroupStatus(group *purelbv1.ServiceGroup) error {
+	patch, err := statusPatch(group.Status)
+	if err != nil {
+		return err
+	}
+	_, err = c.crClient.PurelbV1().ServiceGroups(group.Namespace).Patch(context.TODO(), group.Name, types.JSONPatchType, patch, metav1.PatchOptions{FieldManager: c.fieldManager}, "status")
+	return err
+}
+
+// statusPatch returns a JSON patch that replaces a resource's status
+// with status. A merge patch would leave behind map keys that are no
+// longer in status.
+func statusPatch(status interface{}) ([]byte, error) {
+	return json.Marshal([]map[string]interface{}{{"op": "add", "path": "/status", "value": status}})
+}
+
+// Globals reads our instance's cluster-wide settings directly from
+// the cluster. It's for settings that we need before the informers
+// have started, like the memberlist's port. If there's no
+// PureLBConfig in our class, or if the PureLBConfig CRD hasn't been
+// installed yet, then the settings come from our environment
+// variables.
+func (c *Client) Globals() (*purelbv1.Config, error) {
+	plcs := []*purelbv1.PureLBConfig{}
+	list, err := c.crClient.PurelbV1().PureLBConfigs().List(context.TODO(), metav1.ListOptions{})
+	if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		c.logger.Log("op", "globals", "error", err, "msg", "no PureLBConfig CRD, using the environment")
+	} else if err != nil {
+		return nil, err
+	} else {
+		for i := range list.Items {
+			plcs = append(plcs, &list.Items[i])
+		}
+	}
+
+	cfg := &purelbv1.Config{LBClass: LBClass()}
+	plc, _ := classConfig(plcs, cfg.LBClass)
+	setGlobals(cfg, plc)
+	return cfg, nil
+}
+
+// maybeUpdateService writes the changes that the app made to the
+// "was" service back to the cluster. We send patches that contain
+// only the changes so we don't overwrite fields that other
+// controllers have changed in the meantime: one for the status and
+// one for the annotations, finalizers, and spec.
+func (c *Client) maybeUpdateService(was, is *corev1.Service) error {
+	statusPatch, err := servicePatch(&corev1.Service{Status: was.Status}, &corev1.Service{Status: is.Status})
+	if err != nil {
+		return err
+	}
+	if statusPatch != nil {
+		if _, err := c.client.CoreV1().Services(is.Namespace).Patch(context.TODO(), is.Name, types.StrategicMergePatchType, statusPatch, metav1.PatchOptions{FieldManager: c.fieldManager}, "status"); err != nil {
 			c.logger.Log("op", "updateServiceStatus", "error", err, "msg", "failed to update service status")
 			return err
 		}
 	}
-	if !(reflect.DeepEqual(was.Annotations, is.Annotations) && reflect.DeepEqual(was.Spec, is.Spec)) {
-		ann := is.Annotations
-		spec := is.Spec.DeepCopy()
-		if svcUpdated != nil {
-			svcUpdated.DeepCopyInto(is)
-		} else {
-			c.logger.Log("msg", "svcUpdated is nil")
-		}
-		is.Annotations = ann
-		spec.DeepCopyInto(&is.Spec)
-		if _, err = c.client.CoreV1().Services(is.Namespace).Update(context.TODO(), is, metav1.UpdateOptions{}); err != nil {
+
+	wasMeta, isMeta := was.DeepCopy(), is.DeepCopy()
+	wasMeta.Status, isMeta.Status = corev1.ServiceStatus{}, corev1.ServiceStatus{}
+	patch, err := servicePatch(wasMeta, isMeta)
+	if err != nil {
+		return err
+	}
+	if patch != nil {
+		if _, err := c.client.CoreV1().Services(is.Namespace).Patch(context.TODO(), is.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{FieldManager: c.fieldManager}); err != nil {
 			c.logger.Log("op", "updateService", "error", err, "msg", "failed to update service")
 			return err
 		}
@@ -335,6 +514,27 @@ func (c *Client) maybeUpdateService(was, is *corev1.Service) error {
 	return nil
 }
 
+// servicePatch returns a strategic merge patch that changes was into
+// is, or nil if they're the same.
+func servicePatch(was, is *corev1.Service) ([]byte, error) {
+	wasJSON, err := json.Marshal(was)
+	if err != nil {
+		return nil, err
+	}
+	isJSON, err := json.Marshal(is)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(wasJSON, isJSON, corev1.Service{})
+	if err != nil {
+		return nil, err
+	}
+	if string(patch) == "{}" {
+		return nil, nil
+	}
+	return patch, nil
+}
+
 // Infof logs an informational event about obj to the Kubernetes cluster.
 func (c *Client) Infof(obj runtime.Object, kind, msg string, args ...interface{}) {
 	c.events.Eventf(obj, corev1.EventTypeNormal, kind, msg, args...)
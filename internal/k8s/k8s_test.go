@@ -0,0 +1,238 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+	"purelb.io/pkg/generated/clientset/versioned/fake"
+)
+
+func TestServicePatch(t *testing.T) {
+	was := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "svc",
+			Namespace:       "unit",
+			ResourceVersion: "42",
+			Annotations:     map[string]string{"theirs": "x", "purelb.io/old": "y"},
+			Finalizers:      []string{"theirs"},
+		},
+		Spec: corev1.ServiceSpec{Type: "LoadBalancer", ClusterIP: "10.0.0.1"},
+	}
+
+	// No changes, no patch
+	patch, err := servicePatch(was, was.DeepCopy())
+	assert.NoError(t, err)
+	assert.Nil(t, patch)
+
+	// The patch contains only our changes. It doesn't mention the
+	// resource version, or the other fields and list entries, so it
+	// won't conflict with or overwrite others' changes.
+	is := was.DeepCopy()
+	is.Annotations["purelb.io/new"] = "z"
+	delete(is.Annotations, "purelb.io/old")
+	is.Finalizers = append(is.Finalizers, "purelb.io/address")
+	patch, err = servicePatch(was, is)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"annotations":{"purelb.io/new":"z","purelb.io/old":null},"finalizers":["purelb.io/address"],"$setElementOrder/finalizers":["theirs","purelb.io/address"]}}`, string(patch))
+
+	// Removing a finalizer only removes that one
+	patch, err = servicePatch(is, was)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"annotations":{"purelb.io/new":null,"purelb.io/old":"y"},"$deleteFromPrimitiveList/finalizers":["purelb.io/address"],"$setElementOrder/finalizers":["theirs"]}}`, string(patch))
+
+	// Status patches replace the ingress list, including when it's
+	// cleared
+	withIngress := &corev1.Service{Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}}}}}
+	patch, err = servicePatch(&corev1.Service{}, withIngress)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"status":{"loadBalancer":{"ingress":[{"ip":"1.2.3.4"}]}}}`, string(patch))
+	patch, err = servicePatch(withIngress, &corev1.Service{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"status":{"loadBalancer":{"ingress":null}}}`, string(patch))
+}
+
+func TestSetGlobals(t *testing.T) {
+	t.Setenv("DEFAULT_ANNOUNCER", "PureLB")
+	t.Setenv("PURELB_ML_LABELS", "app=purelb")
+	t.Setenv("ML_GROUP", "0123456789abcdef")
+	t.Setenv("PURELB_ML_PORT", "7935")
+
+	// Without a PureLBConfig we use the environment
+	cfg := purelbv1.Config{}
+	setGlobals(&cfg, nil)
+	assert.True(t, cfg.DefaultAnnouncer)
+	assert.Equal(t, "default", cfg.DefaultGroup)
+	assert.Equal(t, purelbv1.PureLBConfigMemberlistSpec{Port: 7935, Labels: "app=purelb", SecretKey: "0123456789abcdef"}, cfg.Memberlist)
+
+	// The PureLBConfig overrides the environment, but only the fields
+	// that it sets
+	announcer := false
+	setGlobals(&cfg, &purelbv1.PureLBConfig{Spec: purelbv1.PureLBConfigSpec{
+		DefaultAnnouncer: &announcer,
+		DefaultGroup:     "general",
+		Memberlist:       &purelbv1.PureLBConfigMemberlistSpec{Port: 7946},
+	}})
+	assert.False(t, cfg.DefaultAnnouncer)
+	assert.Equal(t, "general", cfg.DefaultGroup)
+	assert.Equal(t, purelbv1.PureLBConfigMemberlistSpec{Port: 7946, Labels: "app=purelb", SecretKey: "0123456789abcdef"}, cfg.Memberlist)
+}
+
+func TestGlobals(t *testing.T) {
+	t.Setenv("PURELB_ML_PORT", "7935")
+	t.Setenv("PURELB_LB_CLASS", "")
+
+	plc := &purelbv1.PureLBConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}, Spec: purelbv1.PureLBConfigSpec{
+		Memberlist: &purelbv1.PureLBConfigMemberlistSpec{Port: 7946},
+	}}
+	crClient := fake.NewSimpleClientset(plc)
+	c := &Client{crClient: crClient, logger: log.NewNopLogger()}
+	cfg, err := c.Globals()
+	assert.NoError(t, err)
+	assert.Equal(t, 7946, cfg.Memberlist.Port)
+
+	// Before the PureLBConfig CRD is installed we use the environment
+	crClient.PrependReactor("list", "purelbconfigs", func(action ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(action.GetResource().GroupResource(), "")
+	})
+	cfg, err = c.Globals()
+	assert.NoError(t, err)
+	assert.Equal(t, 7935, cfg.Memberlist.Port)
+
+	// ...but other errors are errors
+	crClient.PrependReactor("list", "purelbconfigs", func(action ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(action.GetResource().GroupResource(), "", nil)
+	})
+	_, err = c.Globals()
+	assert.Error(t, err)
+}
+
+func TestClassConfig(t *testing.T) {
+	plc := func(name string, class string) *purelbv1.PureLBConfig {
+		return &purelbv1.PureLBConfig{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: purelbv1.PureLBConfigSpec{LBClass: class}}
+	}
+	plcs := []*purelbv1.PureLBConfig{plc("second", ""), plc("dmz", "example.com/dmz"), plc("first", purelbv1.ServiceLBClass)}
+
+	// Each class gets its own config, and configs without a class
+	// belong to the default class
+	got, ignored := classConfig(plcs, purelbv1.ServiceLBClass)
+	assert.Equal(t, "first", got.Name)
+	assert.Equal(t, []string{"second"}, ignored)
+	got, ignored = classConfig(plcs, "example.com/dmz")
+	assert.Equal(t, "dmz", got.Name)
+	assert.Empty(t, ignored)
+	got, _ = classConfig(plcs, "example.com/other")
+	assert.Nil(t, got)
+}
+
+func TestLeaseName(t *testing.T) {
+	assert.Equal(t, "purelb-allocator", LeaseName(purelbv1.ServiceLBClass))
+	assert.Equal(t, "purelb-allocator-example.com-dmz", LeaseName("example.com/DMZ"))
+}
+
+func TestUpdateStatus(t *testing.T) {
+	limit := 2
+	quota := &purelbv1.PureLBQuota{ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "unit", ResourceVersion: "1"}}
+	group := &purelbv1.ServiceGroup{ObjectMeta: metav1.ObjectMeta{Name: "group", ResourceVersion: "1"}}
+	crClient := fake.NewSimpleClientset(quota, group)
+
+	// Like the API server, reject updates from stale copies
+	crClient.PrependReactor("update", "*", func(action ktesting.Action) (bool, runtime.Object, error) {
+		obj, err := meta.Accessor(action.(ktesting.UpdateAction).GetObject())
+		if err != nil {
+			return true, nil, err
+		}
+		current, err := crClient.Tracker().Get(action.GetResource(), action.GetNamespace(), obj.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		currentMeta, _ := meta.Accessor(current)
+		if currentMeta.GetResourceVersion() != obj.GetResourceVersion() {
+			return true, nil, apierrors.NewConflict(action.GetResource().GroupResource(), obj.GetName(), nil)
+		}
+		return false, nil, nil
+	})
+
+	// Someone else changes the resources so our copies are stale
+	newer := quota.DeepCopy()
+	newer.ResourceVersion = "2"
+	assert.NoError(t, crClient.Tracker().Update(purelbv1.SchemeGroupVersion.WithResource("purelbquotas"), newer, "unit"))
+	newerGroup := group.DeepCopy()
+	newerGroup.ResourceVersion = "2"
+	assert.NoError(t, crClient.Tracker().Update(purelbv1.SchemeGroupVersion.WithResource("servicegroups"), newerGroup, ""))
+
+	c := &Client{crClient: crClient, fieldManager: "unit"}
+
+	// We can write the status more than once from the stale copies
+	for used := 1; used <= 2; used++ {
+		quota.Status = purelbv1.PureLBQuotaStatus{Used: used, Limit: &limit}
+		assert.NoError(t, c.UpdateQuotaStatus(quota))
+		group.Status = purelbv1.ServiceGroupStatus{Reserved: used}
+		assert.NoError(t, c.UpdateGroupStatus(group))
+	}
+
+	gotQuota, err := crClient.PurelbV1().PureLBQuotas("unit").Get(context.TODO(), "quota", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, quota.Status, gotQuota.Status)
+	gotGroup, err := crClient.PurelbV1().ServiceGroups("").Get(context.TODO(), "group", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, group.Status, gotGroup.Status)
+
+	// A status that drops a group's entry removes it from the cluster
+	quota.Status = purelbv1.PureLBQuotaStatus{Groups: map[string]purelbv1.PureLBQuotaUsage{"a": {Used: 1}}}
+	assert.NoError(t, c.UpdateQuotaStatus(quota))
+	quota.Status = purelbv1.PureLBQuotaStatus{Groups: map[string]purelbv1.PureLBQuotaUsage{"b": {Used: 1}}}
+	assert.NoError(t, c.UpdateQuotaStatus(quota))
+	gotQuota, err = crClient.PurelbV1().PureLBQuotas("unit").Get(context.TODO(), "quota", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, quota.Status, gotQuota.Status)
+}
+
+// syncedInformer is an informer whose cache has synced.
+type syncedInformer struct{ cache.Controller }
+
+func (syncedInformer) HasSynced() bool { return true }
+
+func TestNodes(t *testing.T) {
+	c := &Client{nodeIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}), nodeInformer: syncedInformer{}}
+	for _, node := range []*corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "edge", Labels: map[string]string{"edge": "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "core"}},
+	} {
+		assert.NoError(t, c.nodeIndexer.Add(node))
+	}
+
+	// Nodes come from the cache
+	nodes, err := c.Nodes(labels.SelectorFromSet(labels.Set{"edge": "true"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "edge", nodes[0].Name)
+	nodes, err = c.Nodes(labels.Everything())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(nodes))
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	purelbv1 "purelb.io/pkg/apis/v1"
+)
+
+// LeaderConfig configures leader election.
+type LeaderConfig struct {
+	// Namespace and Name identify the Lease that the candidates
+	// compete for.
+	Namespace string
+	Name      string
+
+	// Identity identifies this candidate. It must be unique among the
+	// candidates, so the pod name is a good choice.
+	Identity string
+
+	// Started is called when this candidate becomes the leader and
+	// Stopped is called when it stops being the leader.
+	Started func()
+	Stopped func()
+}
+
+// LeaseName returns the name of the leader election Lease for the
+// allocators of the PureLB instance that handles class, so several
+// instances can run in the same namespace.
+func LeaseName(class string) string {
+	if class == purelbv1.ServiceLBClass {
+		return "purelb-allocator"
+	}
+	return "purelb-allocator-" + strings.ToLower(strings.NewReplacer("/", "-", "_", "-").Replace(class))
+}
+
+// RunLeaderElection campaigns for the leadership that's described by
+// cfg, using a coordination.k8s.io Lease as the lock. It returns when
+// ctx is done or when this candidate loses the leadership.
+func (c *Client) RunLeaderElection(ctx context.Context, cfg LeaderConfig) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Namespace: cfg.Namespace,
+			Name:      cfg.Name,
+		},
+		Client: c.client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity:      cfg.Identity,
+			EventRecorder: c.events,
+		},
+	}
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				c.logger.Log("op", "leaderElection", "msg", "started leading", "identity", cfg.Identity)
+				cfg.Started()
+			},
+			OnStoppedLeading: func() {
+				c.logger.Log("op", "leaderElection", "msg", "stopped leading", "identity", cfg.Identity)
+				cfg.Stopped()
+			},
+			OnNewLeader: func(identity string) {
+				c.logger.Log("op", "leaderElection", "leader", identity)
+			},
+		},
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net"
 	"regexp"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
 
@@ -32,13 +33,17 @@ import (
 )
 
 type announcer struct {
-	client   k8s.ServiceEvent
-	logger   log.Logger
-	myNode   string
-	config   *purelbv1.LBNodeAgentLocalSpec
-	groups   map[string]*purelbv1.ServiceGroupLocalSpec // groupName -> ServiceGroupLocalSpec
-	election *election.Election
-	dummyInt *netlink.Link // for non-local announcements
+	client k8s.ServiceEvent
+	logger log.Logger
+	myNode string
+	config *purelbv1.LBNodeAgentLocalSpec
+	groups map[string]*purelbv1.ServiceGroupLocalSpec // groupName -> ServiceGroupLocalSpec
+	// nodeGroups contains the names of the groups whose addresses
+	// belong to nodes. We don't announce them since the nodes already
+	// have them.
+	nodeGroups map[string]bool
+	election   *election.Election
+	dummyInt   *netlink.Link // for non-local announcements
 
 	// svcIngresses is a map from svcName to that Service's
 	// Ingresses. Note that we may or may not advertise all of them
@@ -90,10 +95,14 @@ func (a *announcer) SetConfig(cfg *purelbv1.Config) error {
 
 			// stash the local service group configs
 			a.groups = map[string]*purelbv1.ServiceGroupLocalSpec{}
+			a.nodeGroups = map[string]bool{}
 			for _, group := range cfg.Groups {
 				if group.Spec.Local != nil {
 					a.groups[group.ObjectMeta.Name] = group.Spec.Local
 				}
+				if group.Spec.Nodes != nil {
+					a.nodeGroups[group.ObjectMeta.Name] = true
+				}
 			}
 
 			// if the user specified an interface regex then we'll compile
@@ -147,6 +156,17 @@ func (a *announcer) SetBalancer(svc *v1.Service, endpoints *v1.Endpoints) error
 		return nil
 	}
 
+	// if the addresses belong to nodes then they're already where
+	// they need to be. If we announced other addresses for this
+	// service before then we withdraw them.
+	if a.nodeGroups[svc.Annotations[purelbv1.PoolAnnotation]] {
+		l.Log("event", "nodeAddress", "msg", "not announcing node addresses")
+		if _, announced := a.svcIngresses[nsName]; announced {
+			return a.DeleteBalancer(nsName, "nodeAddress", nil)
+		}
+		return nil
+	}
+
 	// add the address to our announcement database
 	a.svcIngresses[nsName] = svc.Status.LoadBalancer.Ingress
 
@@ -158,6 +178,15 @@ func (a *announcer) SetBalancer(svc *v1.Service, endpoints *v1.Endpoints) error
 			continue
 		}
 
+		// Address blocks are routed, not ARPed, so they always go on
+		// dummyInt
+		if servicePrefix(svc, lbIP) != nil {
+			if err := a.announceRemote(svc, endpoints, a.dummyInt, lbIP); err != nil {
+				retErr = err
+			}
+			continue
+		}
+
 		if a.localNameRegex != nil {
 			// The user specified an announcement interface regex so use it to
 			// try to find a local interface, otherwise announce remote
@@ -228,7 +257,7 @@ func (a *announcer) announceLocal(svc *v1.Service, announceInt netlink.Link, lbI
 		if svc.Annotations == nil {
 			svc.Annotations = map[string]string{}
 		}
-		svc.Annotations[purelbv1.AnnounceAnnotation+addrFamilyName(lbIP)] = a.myNode + "," + announceInt.Attrs().Name
+		svc.Annotations[announceAnnotationKey(svc, lbIP)] = a.myNode + "," + announceInt.Attrs().Name
 		announcing.With(prometheus.Labels{
 			"service": nsName,
 			"node":    a.myNode,
@@ -268,15 +297,23 @@ func (a *announcer) announceRemote(svc *v1.Service, endpoints *v1.Endpoints, ann
 		allocPool := a.groups[poolName]
 		l.Log("msg", "announcingNonLocal", "node", a.myNode, "service", nsName)
 		a.client.Infof(svc, "AnnouncingNonLocal", "Announcing %s from node %s interface %s", lbIP, a.myNode, (*a.dummyInt).Attrs().Name)
-		family := AddrFamily(lbIP)
-		subnet, err := allocPool.FamilySubnet(family)
-		if err != nil {
-		}
-		aggregation, err := allocPool.FamilyAggregation(family)
-		if err != nil {
-			return err
+		if block := servicePrefix(svc, lbIP); block != nil {
+			// The service has a whole block so we add it with the
+			// block's mask
+			if err := addNetwork(*block, *a.dummyInt); err != nil {
+				return err
+			}
+		} else {
+			family := AddrFamily(lbIP)
+			subnet, err := allocPool.FamilySubnet(family)
+			if err != nil {
+			}
+			aggregation, err := allocPool.FamilyAggregation(family)
+			if err != nil {
+				return err
+			}
+			addVirtualInt(lbIP, *a.dummyInt, subnet, aggregation)
 		}
-		addVirtualInt(lbIP, *a.dummyInt, subnet, aggregation)
 		announcing.With(prometheus.Labels{
 			"service": nsName,
 			"node":    a.myNode,
@@ -396,6 +433,42 @@ func nodeHasHealthyEndpoint(eps *v1.Endpoints, node string) bool {
 	return false
 }
 
+// announceAnnotationKey returns the key of the AnnounceAnnotation for
+// lbIP. The first address of each family uses the family name as the
+// suffix, e.g., "purelb.io/announcing-IPv4". Services with more than
+// one address per family can be announced from different nodes so
+// the others add their position, e.g., "purelb.io/announcing-IPv4-2".
+func announceAnnotationKey(svc *v1.Service, lbIP net.IP) string {
+	key := purelbv1.AnnounceAnnotation + addrFamilyName(lbIP)
+	position := 0
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		ip := net.ParseIP(ingress.IP)
+		if ip == nil || addrFamilyName(ip) != addrFamilyName(lbIP) {
+			continue
+		}
+		position++
+		if ip.Equal(lbIP) {
+			break
+		}
+	}
+	if position > 1 {
+		key = fmt.Sprintf("%s-%d", key, position)
+	}
+	return key
+}
+
+// servicePrefix returns the address block that the allocator
+// assigned to svc starting at lbIP, or nil if lbIP is a single
+// address.
+func servicePrefix(svc *v1.Service, lbIP net.IP) *net.IPNet {
+	for _, cidr := range strings.Split(svc.Annotations[purelbv1.PrefixAnnotation], ",") {
+		if _, block, err := net.ParseCIDR(cidr); err == nil && block.IP.Equal(lbIP) {
+			return block
+		}
+	}
+	return nil
+}
+
 // addrFamilyName returns whether lbIP is an IPV4 or IPV6 address.
 // The return value will be "IPv6" if the address is an IPV6 address,
 // "IPv4" if it's IPV4, or "unknown" if the family can't be determined.
@@ -31,3 +31,23 @@ func NewNetbox(base string, tenant string, token string) netbox.Netbox {
 func (n *fakeNetbox) Fetch() (string, error) {
 	return "10.1.2.3/32", nil
 }
+
+// Release returns an address to an imaginary Netbox.
+func (n *fakeNetbox) Release(ip string) error {
+	return nil
+}
+
+// Count returns the number of addresses that an imaginary Netbox
+// has with the given status.
+func (n *fakeNetbox) Count(status string) (int, error) {
+	if status == "reserved" {
+		return 5, nil
+	}
+	return 1, nil
+}
+
+// PrefixUsage returns the number of addresses that an imaginary
+// Netbox has recorded within prefix.
+func (n *fakeNetbox) PrefixUsage(prefix string) (int, error) {
+	return 3, nil
+}
@@ -25,6 +25,9 @@ import (
 
 type Netbox interface {
 	Fetch() (string, error)
+	Release(ip string) error
+	Count(status string) (int, error)
+	PrefixUsage(prefix string) (int, error)
 }
 
 // netbox represents a connection to a
@@ -109,6 +112,34 @@ func (n *netbox) fetchAddrs(tenant string, status string) ([]address, error) {
 	return body.Results, nil
 }
 
+// countAddrs returns the number of addresses that match query. We
+// ask Netbox for at most one result since we only care about the
+// count, which Netbox reports regardless of the page size.
+func (n *netbox) countAddrs(query url.Values) (int, error) {
+	req, err := n.newGetRequest("api/ipam/ip-addresses/")
+	if err != nil {
+		return 0, err
+	}
+	query.Set("limit", "1")
+	req.URL.RawQuery = query.Encode()
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("Netbox query failed: %s", resp.Status)
+	}
+
+	var body addressQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, err
+	}
+
+	return body.Count, nil
+}
+
 func (n *netbox) allocateAddr(addr address) error {
 	// mark the address as "in use" by sending an HTTP PATCH request to
 	// set the Netbox address status to "active"
@@ -145,3 +176,71 @@ func (n *netbox) Fetch() (string, error) {
 
 	return first.Address, err
 }
+
+// Release returns ip to Netbox by setting its status back to
+// "reserved" so it can be fetched again. ip is an address without a
+// prefix length, e.g., "192.168.1.1".
+func (n *netbox) Release(ip string) error {
+	req, err := n.newGetRequest("api/ipam/ip-addresses/")
+	if err != nil {
+		return err
+	}
+	req.URL.RawQuery = url.Values{"tenant": []string{n.tenant}, "address": []string{ip}}.Encode()
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Netbox query failed: %s", resp.Status)
+	}
+
+	var body addressQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+
+	for _, addr := range body.Results {
+		if err := n.releaseAddr(addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseAddr marks addr as available by sending an HTTP PATCH
+// request to set its Netbox status to "reserved".
+func (n *netbox) releaseAddr(addr address) error {
+	url := fmt.Sprintf("api/ipam/ip-addresses/%d/", addr.ID)
+	req, err := n.newPatchRequest(url, []byte("{\"status\": \"reserved\"}"))
+	if err != nil {
+		return err
+	}
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Netbox release of %s failed: %s", addr.Address, resp.Status)
+	}
+
+	return nil
+}
+
+// Count returns the number of addresses that belong to our tenant
+// and whose status matches the status parameter, e.g., "reserved" or
+// "active".
+func (n *netbox) Count(status string) (int, error) {
+	return n.countAddrs(url.Values{"tenant": []string{n.tenant}, "status": []string{status}})
+}
+
+// PrefixUsage returns the number of addresses that Netbox has
+// recorded within prefix, regardless of their tenant or status.
+// prefix is in CIDR notation, e.g., "192.168.1.0/24".
+func (n *netbox) PrefixUsage(prefix string) (int, error) {
+	return n.countAddrs(url.Values{"parent": []string{prefix}})
+}
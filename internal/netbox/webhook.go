@@ -0,0 +1,125 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netbox
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+)
+
+const (
+	// SignatureHeader is the HTTP header in which Netbox sends the
+	// webhook's HMAC signature.
+	SignatureHeader = "X-Hook-Signature"
+
+	// EventDeleted is the event type that Netbox sends when an object
+	// has been deleted.
+	EventDeleted = "deleted"
+
+	// StatusActive is the status of addresses that PureLB has
+	// allocated.
+	StatusActive = "active"
+
+	// maxWebhookBody is the largest webhook request body that we'll
+	// read.
+	maxWebhookBody = 1 << 20
+)
+
+// ErrBadSignature indicates that a webhook request's signature was
+// missing or didn't match the request.
+var ErrBadSignature = errors.New("bad webhook signature")
+
+// WebhookEvent is a Netbox webhook notification about an IP address.
+type WebhookEvent struct {
+	Event string `json:"event"`
+	Model string `json:"model"`
+	Data  struct {
+		ID      int    `json:"id"`
+		Address string `json:"address"`
+		Status  struct {
+			Value string `json:"value"`
+		} `json:"status"`
+		Tenant *struct {
+			Slug string `json:"slug"`
+		} `json:"tenant"`
+	} `json:"data"`
+}
+
+// IP returns the address that this event is about, without its mask.
+func (e *WebhookEvent) IP() (net.IP, error) {
+	ip, _, err := net.ParseCIDR(e.Data.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q", e.Data.Address)
+	}
+	return ip, nil
+}
+
+// TenantSlug returns the slug of the tenant that owns the address, or
+// "" if the address has no tenant.
+func (e *WebhookEvent) TenantSlug() string {
+	if e.Data.Tenant == nil {
+		return ""
+	}
+	return e.Data.Tenant.Slug
+}
+
+// ParseWebhook reads a Netbox webhook request, checks its HMAC
+// signature using secret, and decodes its body. If error is non-nil
+// then the request should be rejected.
+func ParseWebhook(r *http.Request, secret []byte) (*WebhookEvent, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSignature(body, r.Header.Get(SignatureHeader), secret); err != nil {
+		return nil, err
+	}
+
+	event := WebhookEvent{}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// checkSignature checks that signature is the hex-encoded HMAC-SHA512
+// of body, keyed with secret, which is how Netbox signs its webhooks.
+func checkSignature(body []byte, signature string, secret []byte) error {
+	if signature == "" {
+		return fmt.Errorf("%w: %s header missing", ErrBadSignature, SignatureHeader)
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: %s header malformed", ErrBadSignature, SignatureHeader)
+	}
+
+	mac := hmac.New(sha512.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return fmt.Errorf("%w: %s header does not match", ErrBadSignature, SignatureHeader)
+	}
+
+	return nil
+}
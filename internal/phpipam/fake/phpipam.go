@@ -0,0 +1,66 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"fmt"
+
+	"purelb.io/internal/phpipam"
+)
+
+// Address is an address record in the imaginary phpIPAM.
+type Address struct {
+	Hostname    string
+	Description string
+}
+
+// PhpIPAM is an imaginary phpIPAM system with one subnet.
+type PhpIPAM struct {
+	addresses []string
+	// Records contains the addresses that have been allocated.
+	Records map[string]Address
+}
+
+// NewPhpIPAM returns an imaginary phpIPAM whose subnet contains
+// addresses.
+func NewPhpIPAM(addresses ...string) *PhpIPAM {
+	return &PhpIPAM{addresses: addresses, Records: map[string]Address{}}
+}
+
+// FirstFree allocates the first free address in the imaginary
+// subnet.
+func (p *PhpIPAM) FirstFree(subnetID int, hostname string, description string) (string, error) {
+	for _, addr := range p.addresses {
+		if _, used := p.Records[addr]; !used {
+			p.Records[addr] = Address{Hostname: hostname, Description: description}
+			return addr, nil
+		}
+	}
+	return "", fmt.Errorf("No free addresses found")
+}
+
+// Release frees addr.
+func (p *PhpIPAM) Release(subnetID int, addr string) error {
+	if _, used := p.Records[addr]; !used {
+		return fmt.Errorf("Address does not exist")
+	}
+	delete(p.Records, addr)
+	return nil
+}
+
+// Usage returns the imaginary subnet's usage.
+func (p *PhpIPAM) Usage(subnetID int) (phpipam.Usage, error) {
+	return phpipam.Usage{Used: uint64(len(p.Records)), MaxHosts: uint64(len(p.addresses))}, nil
+}
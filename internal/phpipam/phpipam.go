@@ -0,0 +1,167 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package phpipam
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxResponseBody is the largest response body that we'll read.
+	maxResponseBody = 1 << 20
+)
+
+// PhpIPAM is a connection to a phpIPAM API application.
+type PhpIPAM interface {
+	FirstFree(subnetID int, hostname string, description string) (string, error)
+	Release(subnetID int, addr string) error
+	Usage(subnetID int) (Usage, error)
+}
+
+// Usage is a subnet's address usage.
+type Usage struct {
+	Used     uint64
+	MaxHosts uint64
+}
+
+// phpipam represents a connection to a
+// [phpIPAM](https://phpipam.net/) system.
+type phpipam struct {
+	http http.Client
+	// The base URL of the API application, e.g.,
+	// "https://ipam/api/purelb/".
+	base string
+	// The API application token that PureLB uses to authenticate.
+	token string
+}
+
+// response is the envelope in which phpIPAM wraps every reply.
+type response struct {
+	Code    int             `json:"code"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// usageData is the data in a subnet usage response. Depending on
+// its version, phpIPAM sends the counts as either numbers or strings.
+type usageData struct {
+	Used     json.Number `json:"used"`
+	MaxHosts json.Number `json:"maxhosts"`
+}
+
+// NewPhpIPAM configures a new connection to a phpIPAM system.
+func NewPhpIPAM(base string, token string) PhpIPAM {
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return &phpipam{http: http.Client{Timeout: 30 * time.Second}, base: base, token: token}
+}
+
+// FirstFree creates an address record for the first free address in
+// the subnet and returns the address.
+func (p *phpipam) FirstFree(subnetID int, hostname string, description string) (string, error) {
+	body := map[string]string{"hostname": hostname, "description": description, "owner": "PureLB"}
+	data, err := p.do(http.MethodPost, fmt.Sprintf("addresses/first_free/%d/", subnetID), body)
+	if err != nil {
+		return "", err
+	}
+
+	var addr string
+	if err := json.Unmarshal(data, &addr); err != nil || addr == "" {
+		return "", fmt.Errorf("phpIPAM returned no address")
+	}
+	return addr, nil
+}
+
+// Release deletes the address record for addr in the subnet.
+func (p *phpipam) Release(subnetID int, addr string) error {
+	_, err := p.do(http.MethodDelete, fmt.Sprintf("addresses/%s/%d/", addr, subnetID), nil)
+	return err
+}
+
+// Usage returns the subnet's address usage.
+func (p *phpipam) Usage(subnetID int) (Usage, error) {
+	data, err := p.do(http.MethodGet, fmt.Sprintf("subnets/%d/usage/", subnetID), nil)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	raw := usageData{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Usage{}, fmt.Errorf("subnet usage unparseable: %w", err)
+	}
+	used, err := strconv.ParseUint(raw.Used.String(), 10, 64)
+	if err != nil {
+		return Usage{}, fmt.Errorf("subnet usage unparseable: %w", err)
+	}
+	maxHosts, err := strconv.ParseUint(raw.MaxHosts.String(), 10, 64)
+	if err != nil {
+		return Usage{}, fmt.Errorf("subnet usage unparseable: %w", err)
+	}
+
+	return Usage{Used: used, MaxHosts: maxHosts}, nil
+}
+
+// do sends a request to phpIPAM and returns the data from the
+// response envelope. If in is non-nil then it's sent as the request
+// body.
+func (p *phpipam) do(verb string, path string, in interface{}) (json.RawMessage, error) {
+	var body io.Reader
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(verb, p.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("accept", "application/json")
+	req.Header.Add("token", p.token)
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := response{}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return nil, fmt.Errorf("%s %s failed: %s", verb, path, resp.Status)
+	}
+	if !envelope.Success || resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s failed: %s (%s)", verb, path, envelope.Message, resp.Status)
+	}
+
+	return envelope.Data, nil
+}
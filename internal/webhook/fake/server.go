@@ -0,0 +1,159 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+
+	"purelb.io/internal/webhook"
+)
+
+// Server is a reference implementation of the IPAM side of the
+// webhook protocol. It manages a fixed list of addresses in memory so
+// it's useful for tests, and as an example for people who want to
+// write their own.
+type Server struct {
+	sync.Mutex
+
+	// addresses is the pool, in allocation order.
+	addresses []string
+
+	// allocated maps each allocated address to the namespaced name of
+	// its service.
+	allocated map[string]string
+}
+
+// NewServer returns a Server that allocates addresses from the
+// provided list. Wrap it in an httptest.Server to use it in tests.
+func NewServer(addresses ...string) *Server {
+	return &Server{addresses: addresses, allocated: map[string]string{}}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		reply(w, http.StatusMethodNotAllowed, webhook.Response{Error: "POST only"})
+		return
+	}
+
+	req := webhook.Request{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		reply(w, http.StatusBadRequest, webhook.Response{Error: err.Error()})
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	switch req.Action {
+	case webhook.ActionAllocate:
+		if req.Service == nil {
+			reply(w, http.StatusBadRequest, webhook.Response{Error: "allocate needs a service"})
+			return
+		}
+		addrs, err := s.allocate(req.Service.Namespace+"/"+req.Service.Name, req.Families)
+		if err != nil {
+			reply(w, http.StatusConflict, webhook.Response{Error: err.Error()})
+			return
+		}
+		reply(w, http.StatusOK, webhook.Response{Addresses: addrs})
+	case webhook.ActionRelease:
+		if req.Service == nil {
+			reply(w, http.StatusBadRequest, webhook.Response{Error: "release needs a service"})
+			return
+		}
+		nsName := req.Service.Namespace + "/" + req.Service.Name
+		for _, addr := range req.Addresses {
+			if s.allocated[addr] == nsName {
+				delete(s.allocated, addr)
+			}
+		}
+		reply(w, http.StatusOK, webhook.Response{})
+	case webhook.ActionList:
+		resp := webhook.Response{Size: uint64(len(s.addresses))}
+		for _, addr := range s.addresses {
+			if svc, in := s.allocated[addr]; in {
+				resp.Allocations = append(resp.Allocations, webhook.Allocation{Address: addr, Service: svc})
+			}
+		}
+		reply(w, http.StatusOK, resp)
+	default:
+		reply(w, http.StatusBadRequest, webhook.Response{Error: fmt.Sprintf("unknown action %q", req.Action)})
+	}
+}
+
+// allocate allocates one free address for each of families, or one
+// address of any family if families is empty. Either all of the
+// addresses are allocated or none are. Caller must hold the lock.
+func (s *Server) allocate(nsName string, families []string) ([]string, error) {
+	if len(families) == 0 {
+		families = []string{""}
+	}
+
+	addrs := []string{}
+	for _, family := range families {
+		addr := s.free(family, addrs)
+		if addr == "" {
+			return nil, fmt.Errorf("no %s addresses available", family)
+		}
+		addrs = append(addrs, addr)
+	}
+
+	for _, addr := range addrs {
+		s.allocated[addr] = nsName
+	}
+	return addrs, nil
+}
+
+// free returns the first unallocated address of family that isn't in
+// skip, or "" if there isn't one. An empty family matches any
+// address.
+func (s *Server) free(family string, skip []string) string {
+Addresses:
+	for _, addr := range s.addresses {
+		if _, in := s.allocated[addr]; in {
+			continue
+		}
+		for _, skipped := range skip {
+			if addr == skipped {
+				continue Addresses
+			}
+		}
+		if family == "" || family == addrFamily(addr) {
+			return addr
+		}
+	}
+	return ""
+}
+
+// addrFamily returns the k8s name for addr's IP family.
+func addrFamily(addr string) string {
+	if net.ParseIP(addr).To4() != nil {
+		return string(v1.IPv4Protocol)
+	}
+	return string(v1.IPv6Protocol)
+}
+
+func reply(w http.ResponseWriter, status int, resp webhook.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	// ActionAllocate asks the IPAM for addresses for a service.
+	ActionAllocate = "allocate"
+	// ActionRelease tells the IPAM that a service no longer needs its
+	// addresses.
+	ActionRelease = "release"
+	// ActionList asks the IPAM for the pool's size and allocations.
+	ActionList = "list"
+
+	// maxResponseBody is the largest response body that we'll read.
+	maxResponseBody = 1 << 20
+)
+
+// IPAM is the client side of PureLB's HTTP webhook IPAM protocol. See
+// README.md in this directory for the protocol.
+type IPAM interface {
+	Allocate(pool string, service Service, families []string) ([]string, error)
+	Release(pool string, service Service, addresses []string) error
+	List(pool string) (*Response, error)
+}
+
+// Service describes the service on whose behalf a request is made.
+type Service struct {
+	Namespace   string            `json:"namespace"`
+	Name        string            `json:"name"`
+	UID         string            `json:"uid,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Request is the body of every request that PureLB sends.
+type Request struct {
+	Action    string   `json:"action"`
+	Pool      string   `json:"pool"`
+	Service   *Service `json:"service,omitempty"`
+	Families  []string `json:"families,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// Allocation is one address that the IPAM has allocated, and the
+// namespaced name of the service to which it belongs.
+type Allocation struct {
+	Address string `json:"address"`
+	Service string `json:"service"`
+}
+
+// Response is the body of every response that PureLB expects. Which
+// fields are used depends on the request's action.
+type Response struct {
+	Addresses   []string     `json:"addresses,omitempty"`
+	Size        uint64       `json:"size,omitempty"`
+	Allocations []Allocation `json:"allocations,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// webhook represents a connection to an IPAM system that implements
+// the webhook protocol.
+type webhook struct {
+	http http.Client
+	// The URL to which we POST requests.
+	url string
+	// The optional bearer token that PureLB uses to authenticate.
+	token string
+}
+
+// NewIPAM configures a new connection to the IPAM system at url. If
+// token is non-empty then it's sent as a bearer token with each
+// request.
+func NewIPAM(url string, token string) IPAM {
+	return &webhook{http: http.Client{Timeout: 10 * time.Second}, url: url, token: token}
+}
+
+// Allocate asks the IPAM to allocate addresses for service, one for
+// each of families. If families is empty then the IPAM should
+// allocate one address of whichever family it prefers.
+func (w *webhook) Allocate(pool string, service Service, families []string) ([]string, error) {
+	resp, err := w.do(Request{Action: ActionAllocate, Pool: pool, Service: &service, Families: families})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Addresses) == 0 {
+		return nil, fmt.Errorf("no addresses allocated")
+	}
+	return resp.Addresses, nil
+}
+
+// Release tells the IPAM that service no longer needs addresses.
+func (w *webhook) Release(pool string, service Service, addresses []string) error {
+	_, err := w.do(Request{Action: ActionRelease, Pool: pool, Service: &service, Addresses: addresses})
+	return err
+}
+
+// List asks the IPAM for the pool's size and current allocations.
+func (w *webhook) List(pool string) (*Response, error) {
+	return w.do(Request{Action: ActionList, Pool: pool})
+}
+
+// do POSTs req to the IPAM and decodes the response. Any non-2xx
+// status is an error, and if the response includes an error message
+// then we return it.
+func (w *webhook) do(req Request) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Add("Content-Type", "application/json")
+	httpReq.Header.Add("accept", "application/json")
+	if w.token != "" {
+		httpReq.Header.Add("Authorization", "Bearer "+w.token)
+	}
+
+	httpResp, err := w.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	raw, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxResponseBody))
+	if err != nil {
+		return nil, err
+	}
+	resp := Response{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &resp); err != nil && httpResp.StatusCode/100 == 2 {
+			return nil, fmt.Errorf("%s response unparseable: %w", req.Action, err)
+		}
+	}
+
+	if httpResp.StatusCode/100 != 2 {
+		if resp.Error != "" {
+			return nil, fmt.Errorf("%s failed: %s (%s)", req.Action, resp.Error, httpResp.Status)
+		}
+		return nil, fmt.Errorf("%s failed: %s", req.Action, httpResp.Status)
+	}
+
+	return &resp, nil
+}
@@ -29,6 +29,19 @@ const (
 	// allocate this service's IP address.
 	DesiredGroupAnnotation string = "purelb.io/service-group"
 
+	// AddressCountAnnotation is the key for the annotation that
+	// indicates how many addresses of each IP family the service
+	// needs. If it's not set then the service gets one address per
+	// family. Only local pools can allocate more than one.
+	AddressCountAnnotation string = "purelb.io/address-count"
+
+	// LeaseDurationAnnotation is the key for the annotation that
+	// indicates how long the service needs its address, e.g.,
+	// "72h". When the lease expires the allocator releases the
+	// address. The ServiceGroup's lease.maxDuration, if any, limits
+	// the lease.
+	LeaseDurationAnnotation string = "purelb.io/lease-duration"
+
 	// Annotations that PureLB sets that might be useful to users.
 
 	// BrandAnnotation is the key for the PureLB "brand" annotation.
@@ -49,4 +62,38 @@ const (
 	// family name will be appended because in a dual-stack service we
 	// might announce different IP addresses on different hosts.
 	AnnounceAnnotation string = "purelb.io/announcing"
+
+	// DHCPServerAnnotation is the key for the annotation that records
+	// the address of the DHCP server that leased a service's address,
+	// if the address came from a DHCP ServiceGroup. It lets the
+	// allocator renew and release the lease after a restart.
+	DHCPServerAnnotation string = "purelb.io/dhcp-server"
+
+	// PrefixAnnotation is the key for the annotation that records the
+	// address blocks allocated to a service from a ServiceGroup with a
+	// prefixLength. The value is a comma-separated list of CIDRs, one
+	// per IP family. The first address of each block is the service's
+	// ingress address.
+	PrefixAnnotation string = "purelb.io/allocated-prefix"
+
+	// LeaseExpiresAnnotation is the key for the annotation that
+	// records when a service's address lease expires, in RFC 3339
+	// format. Users can edit it to change the lease, but not to
+	// extend it past the ServiceGroup's lease.maxDuration from when
+	// the address was allocated.
+	LeaseExpiresAnnotation string = "purelb.io/lease-expires"
+
+	// LeaseExpiredAnnotation is the key for the annotation that
+	// records when a service's address lease expired. The allocator
+	// doesn't allocate addresses to services with this annotation, so
+	// users can remove it to get a new address.
+	LeaseExpiredAnnotation string = "purelb.io/lease-expired"
+)
+
+const (
+	// LeaseCondition is the type of the service status condition that
+	// records when PureLB allocated a leased address to the service.
+	// It's in the status so users who can edit the service can't
+	// change it.
+	LeaseCondition string = "purelb.io/AddressLease"
 )
@@ -21,3 +21,14 @@ const (
 	// PureLB will respond to the service.
 	ServiceLBClass string = "purelb.io/purelb"
 )
+
+// InClass indicates whether a resource whose lbClass is
+// resourceClass belongs to the instance of PureLB that handles
+// class. Resources with no lbClass belong to the ServiceLBClass
+// instance.
+func InClass(resourceClass string, class string) bool {
+	if resourceClass == "" {
+		resourceClass = ServiceLBClass
+	}
+	return resourceClass == class
+}
@@ -25,8 +25,24 @@ type Config struct {
 	// announcer.
 	DefaultAnnouncer bool
 
+	// LBClass is the Spec.LoadBalancerClass that this instance of
+	// PureLB handles. Only the resources in this class are included in
+	// the Config.
+	LBClass string
+
+	// DefaultGroup is the name of the ServiceGroup from which services
+	// that don't ask for a group get their addresses.
+	DefaultGroup string
+
+	// Memberlist configures the node agents' memberlist.
+	Memberlist PureLBConfigMemberlistSpec
+
 	// Service Groups from which to allocate load balancer IP addresses
 	Groups []*ServiceGroup
 	// Node agent configurations
 	Agents []*LBNodeAgent
+	// Per-namespace address quotas
+	Quotas []*PureLBQuota
+	// Addresses reserved for namespaces or services
+	Reservations []*AddressReservation
 }
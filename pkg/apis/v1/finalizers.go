@@ -0,0 +1,24 @@
+// Copyright 2021 Acnodal Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+const (
+	// AddressFinalizer is the finalizer that the allocator adds to the
+	// services to which it allocates addresses. It removes the
+	// finalizer once the service's pool has released the addresses,
+	// so a service can't go away while its addresses are still
+	// allocated in an external IPAM system.
+	AddressFinalizer string = "purelb.io/address"
+)
@@ -49,6 +49,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&LBNodeAgentList{},
 		&ServiceGroup{},
 		&ServiceGroupList{},
+		&PureLBQuota{},
+		&PureLBQuotaList{},
+		&AddressReservation{},
+		&AddressReservationList{},
+		&PureLBConfig{},
+		&PureLBConfigList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -29,6 +29,7 @@ import (
 // service groups. It contains the usual CRD metadata, and the service
 // group spec and status.
 // +kubebuilder:resource:shortName=sg;sgs
+// +kubebuilder:subresource:status
 type ServiceGroup struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -40,14 +41,73 @@ type ServiceGroup struct {
 
 // ServiceGroupSpec configures the allocator.  It will have one of
 // either a Local configuration (to allocate service addresses from a
-// local pool) or a Netbox configuration (to get addresses from the
-// Netbox IPAM). For examples, see the "config/" directory in the
-// PureLB source tree.
+// local pool), a Netbox configuration (to get addresses from the
+// Netbox IPAM), an Infoblox configuration (to get addresses from an
+// Infoblox grid), a PhpIPAM configuration (to get addresses from a
+// phpIPAM system), a DHCP configuration (to lease addresses from a
+// DHCP server), a Nodes configuration (to use the addresses of the
+// cluster's nodes), or a Webhook configuration (to get addresses from
+// an IPAM system that speaks PureLB's HTTP webhook protocol). For
+// examples, see the "config/" directory in the PureLB source tree.
 type ServiceGroupSpec struct {
 	// +optional
 	Local *ServiceGroupLocalSpec `json:"local,omitempty"`
 	// +optional
 	Netbox *ServiceGroupNetboxSpec `json:"netbox,omitempty"`
+	// +optional
+	Infoblox *ServiceGroupInfobloxSpec `json:"infoblox,omitempty"`
+	// +optional
+	PhpIPAM *ServiceGroupPhpIPAMSpec `json:"phpipam,omitempty"`
+	// +optional
+	DHCP *ServiceGroupDHCPSpec `json:"dhcp,omitempty"`
+	// +optional
+	Nodes *ServiceGroupNodesSpec `json:"nodes,omitempty"`
+	// +optional
+	Webhook *ServiceGroupWebhookSpec `json:"webhook,omitempty"`
+
+	// RequireAuthorization, if true, limits this group to namespaces
+	// that are allowed to "use" it by Kubernetes RBAC. PureLB checks
+	// with a SubjectAccessReview for the namespace's "default" service
+	// account, so a Role that allows the "use" verb on this group's
+	// "servicegroups" resource, bound to that service account or to the
+	// namespace's service account group, grants access.
+	// +optional
+	RequireAuthorization bool `json:"requireAuthorization,omitempty"`
+
+	// Lease limits how long services can keep the addresses that they
+	// get from this group.
+	// +optional
+	Lease *ServiceGroupLeaseSpec `json:"lease,omitempty"`
+
+	// LBClass is the LoadBalancerClass of the PureLB instance that
+	// uses this group. If it's empty then the group belongs to the
+	// instance whose class is "purelb.io/purelb".
+	// +optional
+	LBClass string `json:"lbClass,omitempty"`
+}
+
+// ServiceGroupLeaseSpec configures expiring addresses. Services can
+// ask for a lease with a "purelb.io/lease-duration" annotation, and
+// when the lease expires the allocator releases the service's address.
+type ServiceGroupLeaseSpec struct {
+	// MaxDuration is the longest lease that services can have, e.g.,
+	// "168h". Services that don't ask for a lease get this one. If
+	// it's not set then leases are limited only by the annotation.
+	// +optional
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+
+	// WarningPeriod is how long before a lease expires that the
+	// allocator starts sending Warning events to the service. The
+	// default is one hour.
+	// +optional
+	WarningPeriod *metav1.Duration `json:"warningPeriod,omitempty"`
+
+	// SwitchToClusterIP, if true, changes services whose leases have
+	// expired to type ClusterIP. If it's false then the services stay
+	// LoadBalancers, but they don't get new addresses until the user
+	// removes their "purelb.io/lease-expired" annotation.
+	// +optional
+	SwitchToClusterIP bool `json:"switchToClusterIP,omitempty"`
 }
 
 // ServiceGroupLocalSpec configures the allocator to manage pools of
@@ -77,6 +137,55 @@ type ServiceGroupLocalSpec struct {
 	V4Pool *ServiceGroupAddressPool `json:"v4pool,omitempty"`
 	// +optional
 	V6Pool *ServiceGroupAddressPool `json:"v6pool,omitempty"`
+
+	// AutoShare lets PureLB share addresses between services once the
+	// pool is full. Services that don't have a
+	// "purelb.io/allow-shared-ip" annotation are packed onto the
+	// least-used addresses whose ports don't clash with theirs. Blocks
+	// allocated with a prefixLength are never shared.
+	// +optional
+	AutoShare bool `json:"autoShare,omitempty"`
+
+	// Sharing controls which namespaces can share addresses with each
+	// other. By default services can share addresses only with
+	// services in the same namespace.
+	// +optional
+	Sharing *ServiceGroupSharingSpec `json:"sharing,omitempty"`
+
+	// StaticAssignments maps services, by namespace/name, to the
+	// addresses that they always get from this group, e.g.,
+	// {"ingress/nginx": ["192.168.1.240", "fd53::240"]}. These
+	// addresses are never allocated to other services, and the
+	// services get them even if they ask for another group.
+	// +optional
+	StaticAssignments map[string][]string `json:"staticAssignments,omitempty"`
+
+	// StickyWindow is how long an address stays reserved for a
+	// deleted service, e.g., "10m". If a service with the same
+	// namespace and name is created within the window then it gets
+	// the same address. Addresses that are shared with other services
+	// aren't kept.
+	// +optional
+	StickyWindow *metav1.Duration `json:"stickyWindow,omitempty"`
+
+	// ReallocateConflicts tells the allocator what to do when its
+	// audit finds services that hold the same address but can't share
+	// it. The allocator always emits Warning events on the
+	// services. If ReallocateConflicts is true then it also moves the
+	// newer service to a new address.
+	// +optional
+	ReallocateConflicts bool `json:"reallocateConflicts,omitempty"`
+}
+
+// ServiceGroupSharingSpec configures address sharing between
+// namespaces.
+type ServiceGroupSharingSpec struct {
+	// NamespaceGroups lists groups of namespaces. Services in any of
+	// the namespaces in a group can share addresses with services in
+	// the other namespaces in that group if they have the same sharing
+	// key, e.g., [["team-a", "team-a-canary"], ["ingress", "monitoring"]].
+	// +optional
+	NamespaceGroups [][]string `json:"namespaceGroups,omitempty"`
 }
 
 // FamilyAggregation returns this Spec's aggregation value that
@@ -173,6 +282,140 @@ type ServiceGroupNetboxSpec struct {
 	URL         string `json:"url"`
 	Tenant      string `json:"tenant"`
 	Aggregation string `json:"aggregation"`
+
+	// Prefix is an optional CIDR, e.g., "192.168.1.0/24". If it's set
+	// then the pool's capacity metrics report the size and the
+	// utilization of this prefix. If it's not set then they report
+	// the tenant's reserved and active addresses.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// PollInterval is how often the allocator asks Netbox for the
+	// pool's capacity. The default is one minute.
+	// +optional
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+
+	// Reallocate tells the allocator what to do when Netbox notifies
+	// it (via a webhook) that an address that belongs to a service has
+	// been deleted, changed status, or moved to a different tenant. The
+	// allocator always emits a Warning event on the service. If
+	// Reallocate is true then it also moves the service to a new
+	// address.
+	// +optional
+	Reallocate bool `json:"reallocate,omitempty"`
+}
+
+// ServiceGroupInfobloxSpec configures the allocator to request
+// addresses from an Infoblox grid using the WAPI REST API. The
+// allocator asks Infoblox for the next available address in V4Pool
+// and/or V6Pool and creates a record for the service that holds the
+// address. Each pool can be a network in CIDR notation,
+// e.g. '192.168.1.0/24', or a from-to range of addresses,
+// e.g. '192.168.1.100-192.168.1.199'. If both pools are configured
+// then the group can allocate dual-stack addresses.
+//
+// The WAPI credentials come from the allocator's INFOBLOX_USERNAME
+// and INFOBLOX_PASSWORD environment variables.
+type ServiceGroupInfobloxSpec struct {
+	// URL is the base URL of the grid master's WAPI,
+	// e.g. 'https://gm.example.com/wapi/v2.11/'.
+	URL string `json:"url"`
+
+	// NetworkView is the Infoblox network view that contains the
+	// pools. The default is "default".
+	// +optional
+	NetworkView string `json:"networkView,omitempty"`
+
+	// +optional
+	V4Pool string `json:"v4pool,omitempty"`
+	// +optional
+	V6Pool string `json:"v6pool,omitempty"`
+
+	// RecordType is the type of record that holds each service's
+	// addresses: "fixedaddress" (the default) or "host". Host records
+	// are named <service>.<namespace>.<Domain> and aren't added to
+	// DNS.
+	// +optional
+	RecordType string `json:"recordType,omitempty"`
+	// +optional
+	Domain string `json:"domain,omitempty"`
+
+	// ExtAttrs are extensible attributes to add to each record. The
+	// attributes must already be defined in Infoblox.
+	// +optional
+	ExtAttrs map[string]string `json:"extAttrs,omitempty"`
+
+	Aggregation string `json:"aggregation"`
+}
+
+// ServiceGroupPhpIPAMSpec configures the allocator to request
+// addresses from a phpIPAM system. The allocator asks phpIPAM for the
+// first free address in the subnet with ID SubnetID. The API token
+// comes from the allocator's PHPIPAM_TOKEN environment variable.
+type ServiceGroupPhpIPAMSpec struct {
+	// URL is the base URL of the phpIPAM API application,
+	// e.g. 'https://ipam.example.com/api/purelb/'.
+	URL      string `json:"url"`
+	SubnetID int    `json:"subnetID"`
+
+	// PollInterval is how often the allocator asks phpIPAM for the
+	// subnet's usage. The default is one minute.
+	// +optional
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+
+	Aggregation string `json:"aggregation"`
+}
+
+// ServiceGroupDHCPSpec configures the allocator to lease one IPV4
+// address per service from the DHCP servers on a network
+// interface. Each service's lease uses a client ID derived from the
+// service, and the allocator renews the leases in the background.
+// The allocator has to run on the host network so it can reach the
+// DHCP servers (the Helm chart's allocator.hostNetwork value), and
+// DHCP groups can allocate only IPV4 addresses. The allocator leases
+// the address in the background so the service gets it on its next
+// sync.
+type ServiceGroupDHCPSpec struct {
+	// Interface is the name of the allocator host's network interface
+	// on which to send DHCP requests. It should be connected to the
+	// same network as the interface that announces the addresses.
+	Interface string `json:"interface"`
+
+	Aggregation string `json:"aggregation"`
+}
+
+// ServiceGroupNodesSpec configures the allocator to give services
+// the addresses of the cluster's nodes instead of virtual addresses,
+// which is useful in small clusters that have no spare addresses.
+// Each service gets the addresses of all of the selected nodes on
+// which its ports are free, so services can share node addresses if
+// they use different ports. The node agents don't add these addresses
+// to any interface since the nodes already have them.
+type ServiceGroupNodesSpec struct {
+	// NodeSelector selects the nodes whose addresses the group
+	// uses. The default is all nodes.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+
+	// AddressType is the type of node address to use: "InternalIP"
+	// (the default) or "ExternalIP".
+	// +optional
+	AddressType string `json:"addressType,omitempty"`
+}
+
+// ServiceGroupWebhookSpec configures the allocator to request
+// addresses from an external IPAM system using PureLB's HTTP webhook
+// protocol. The allocator POSTs allocate, release, and list requests
+// to URL. The protocol is documented in internal/webhook/README.md in
+// the PureLB source tree.
+type ServiceGroupWebhookSpec struct {
+	URL         string `json:"url"`
+	Aggregation string `json:"aggregation"`
+
+	// PollInterval is how often the allocator sends a list request to
+	// read the pool's capacity. The default is one minute.
+	// +optional
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
 }
 
 // ServiceGroupAddressPool specifies a pool of addresses that belong
@@ -193,10 +436,55 @@ type ServiceGroupAddressPool struct {
 	// from the subnet mask to the specified mask. It can be "default"
 	// or an integer in the range 8-128.
 	Aggregation string `json:"aggregation"`
+
+	// PrefixLength, if set, tells PureLB to allocate a whole block of
+	// addresses to each service instead of a single address, e.g., 120
+	// gives each service an aligned IPV6 /120. The block is recorded in
+	// the "purelb.io/allocated-prefix" annotation and the node agent
+	// announces it on the dummy interface with this mask. Blocks can't
+	// be shared between services.
+	// +optional
+	PrefixLength int `json:"prefixLength,omitempty"`
 }
 
-// ServiceGroupStatus is currently unused.
+// ServiceGroupStatus reports the state of the group's pool.
 type ServiceGroupStatus struct {
+	// Reserved is the number of unexpired AddressReservations for
+	// addresses in this group.
+	// +optional
+	Reserved int `json:"reserved,omitempty"`
+
+	// ReservedInUse is the number of reserved addresses that have been
+	// claimed by the services for which they're reserved.
+	// +optional
+	ReservedInUse int `json:"reservedInUse,omitempty"`
+
+	// Conflicts describes the static assignments that can't be
+	// honored, e.g., because the address is outside the group or is in
+	// use by another service.
+	// +optional
+	Conflicts []string `json:"conflicts,omitempty"`
+
+	// Sticky lists the addresses that the group is keeping for deleted
+	// services until their StickyWindow expires. The allocator
+	// restores them from here when it starts or becomes the leader.
+	// +optional
+	Sticky []ServiceGroupStickyAddresses `json:"sticky,omitempty"`
+}
+
+// ServiceGroupStickyAddresses records the addresses that a deleted
+// service held.
+type ServiceGroupStickyAddresses struct {
+	// Service is the namespaced name of the deleted service,
+	// e.g., "default/web".
+	Service string `json:"service"`
+
+	// Addresses are the addresses that the service held.
+	Addresses []string `json:"addresses"`
+
+	// Expires is when the addresses are freed if the service hasn't
+	// been re-created.
+	Expires metav1.Time `json:"expires"`
 }
 
 // +genclient
@@ -220,6 +508,12 @@ type LBNodeAgent struct {
 // see the "config/" directory in the PureLB source tree.
 type LBNodeAgentSpec struct {
 	Local *LBNodeAgentLocalSpec `json:"local"`
+
+	// LBClass is the LoadBalancerClass of the PureLB instance that
+	// uses this configuration. If it's empty then the configuration
+	// belongs to the instance whose class is "purelb.io/purelb".
+	// +optional
+	LBClass string `json:"lbClass,omitempty"`
 }
 
 // LBNodeAgentLocalSpec configures the announcers to announce service
@@ -249,6 +543,208 @@ type LBNodeAgentLocalSpec struct {
 type LBNodeAgentStatus struct {
 }
 
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PureLBQuota limits the number of addresses that PureLB allocates to
+// the services in its namespace. The allocator refuses to allocate
+// addresses that would take the namespace over any of its limits, and
+// reports the namespace's current usage in the status.
+// +kubebuilder:resource:shortName=plbq
+// +kubebuilder:subresource:status
+type PureLBQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PureLBQuotaSpec `json:"spec"`
+	// +optional
+	Status PureLBQuotaStatus `json:"status"`
+}
+
+// PureLBQuotaSpec configures the limits. A quota can limit the total
+// number of addresses, the number of addresses from each
+// ServiceGroup, or both.
+type PureLBQuotaSpec struct {
+	// Addresses is the maximum number of addresses that services in
+	// this namespace can hold, from all ServiceGroups.
+	// +optional
+	Addresses *int `json:"addresses,omitempty"`
+
+	// Groups is the maximum number of addresses that services in this
+	// namespace can hold from each ServiceGroup, indexed by the
+	// ServiceGroup's name.
+	// +optional
+	Groups map[string]int `json:"groups,omitempty"`
+
+	// LBClass is the LoadBalancerClass of the PureLB instance that
+	// enforces this quota. If it's empty then the quota belongs to the
+	// instance whose class is "purelb.io/purelb". Each instance counts
+	// only the addresses that it allocated.
+	// +optional
+	LBClass string `json:"lbClass,omitempty"`
+}
+
+// PureLBQuotaStatus reports the namespace's usage and limits.
+type PureLBQuotaStatus struct {
+	// Used is the number of addresses that services in this namespace
+	// hold, from all ServiceGroups.
+	Used int `json:"used"`
+
+	// Limit is a copy of the spec's Addresses limit.
+	// +optional
+	Limit *int `json:"limit,omitempty"`
+
+	// Groups reports the usage and limit for each ServiceGroup that
+	// the namespace uses or that has a limit, indexed by the
+	// ServiceGroup's name.
+	// +optional
+	Groups map[string]PureLBQuotaUsage `json:"groups,omitempty"`
+}
+
+// PureLBQuotaUsage reports a namespace's usage of one ServiceGroup.
+type PureLBQuotaUsage struct {
+	Used int `json:"used"`
+	// +optional
+	Limit *int `json:"limit,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AddressReservation sets an address in a ServiceGroup aside for the
+// services in its namespace, or for one service if Service is
+// set. The allocator doesn't allocate reserved addresses to other
+// services. A service in the namespace can claim the address by
+// asking for it with spec.loadBalancerIP, and if Service is set then
+// the named service gets the address automatically.
+// +kubebuilder:resource:shortName=ar;ars
+type AddressReservation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AddressReservationSpec `json:"spec"`
+	// +optional
+	Status AddressReservationStatus `json:"status"`
+}
+
+// AddressReservationSpec configures a reservation.
+type AddressReservationSpec struct {
+	// ServiceGroup is the name of the group that contains the
+	// address. Only local groups support reservations.
+	ServiceGroup string `json:"serviceGroup"`
+
+	// Address is the reserved address.
+	Address string `json:"address"`
+
+	// Service is the name of the service in this reservation's
+	// namespace for which the address is reserved. If it's not set
+	// then any service in the namespace can claim the address.
+	// +optional
+	Service string `json:"service,omitempty"`
+
+	// Expires is the time at which the reservation lapses. If it's
+	// not set then the reservation doesn't expire.
+	// +optional
+	Expires *metav1.Time `json:"expires,omitempty"`
+}
+
+// AddressReservationStatus is currently unused.
+type AddressReservationStatus struct {
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PureLBConfig holds the cluster-wide settings of one instance of
+// PureLB. Each instance reads the PureLBConfig whose lbClass matches
+// its own. Settings that it doesn't set come from the allocator's and
+// node agents' environment variables.
+// +kubebuilder:resource:scope=Cluster,shortName=plc
+type PureLBConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PureLBConfigSpec `json:"spec"`
+}
+
+// PureLBConfigSpec configures PureLB.
+type PureLBConfigSpec struct {
+	// DefaultAnnouncer, if true, tells PureLB to handle services that
+	// don't have a Spec.LoadBalancerClass. It overrides the
+	// DEFAULT_ANNOUNCER environment variable.
+	// +optional
+	DefaultAnnouncer *bool `json:"defaultAnnouncer,omitempty"`
+
+	// DefaultGroup is the name of the ServiceGroup from which services
+	// that don't ask for a group get their addresses. The default is
+	// "default".
+	// +optional
+	DefaultGroup string `json:"defaultGroup,omitempty"`
+
+	// Memberlist configures the node agents' memberlist, which they
+	// use to decide which node announces each address.
+	// +optional
+	Memberlist *PureLBConfigMemberlistSpec `json:"memberlist,omitempty"`
+
+	// LBClass is the LoadBalancerClass of the PureLB instance that
+	// uses this configuration. If it's empty then the configuration
+	// belongs to the instance whose class is "purelb.io/purelb".
+	// +optional
+	LBClass string `json:"lbClass,omitempty"`
+}
+
+// PureLBConfigMemberlistSpec configures the node agents'
+// memberlist. The node agents read Port and SecretKey only when they
+// start, so they need to be restarted to use new values.
+type PureLBConfigMemberlistSpec struct {
+	// Port is the port on which the node agents talk to each
+	// other. The default is 7934.
+	// +optional
+	Port int `json:"port,omitempty"`
+
+	// Labels selects the node agent pods. It overrides the
+	// PURELB_ML_LABELS environment variable.
+	// +optional
+	Labels string `json:"labels,omitempty"`
+
+	// SecretKey encrypts the node agents' messages. It overrides the
+	// ML_GROUP environment variable. Anyone who can read this
+	// PureLBConfig can read the key.
+	// +optional
+	SecretKey string `json:"secretKey,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PureLBConfigList holds a list of PureLBConfig.
+type PureLBConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []PureLBConfig `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AddressReservationList holds a list of AddressReservation.
+type AddressReservationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []AddressReservation `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PureLBQuotaList holds a list of PureLBQuota.
+type PureLBQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []PureLBQuota `json:"items"`
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // ServiceGroupList holds a list of ServiceGroup.
@@ -19,12 +19,111 @@
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressReservation) DeepCopyInto(out *AddressReservation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressReservation.
+func (in *AddressReservation) DeepCopy() *AddressReservation {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressReservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AddressReservation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressReservationList) DeepCopyInto(out *AddressReservationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AddressReservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressReservationList.
+func (in *AddressReservationList) DeepCopy() *AddressReservationList {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressReservationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AddressReservationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressReservationSpec) DeepCopyInto(out *AddressReservationSpec) {
+	*out = *in
+	if in.Expires != nil {
+		in, out := &in.Expires, &out.Expires
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressReservationSpec.
+func (in *AddressReservationSpec) DeepCopy() *AddressReservationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressReservationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressReservationStatus) DeepCopyInto(out *AddressReservationStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressReservationStatus.
+func (in *AddressReservationStatus) DeepCopy() *AddressReservationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressReservationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Config) DeepCopyInto(out *Config) {
 	*out = *in
+	out.Memberlist = in.Memberlist
 	if in.Groups != nil {
 		in, out := &in.Groups, &out.Groups
 		*out = make([]*ServiceGroup, len(*in))
@@ -47,6 +146,28 @@ func (in *Config) DeepCopyInto(out *Config) {
 			}
 		}
 	}
+	if in.Quotas != nil {
+		in, out := &in.Quotas, &out.Quotas
+		*out = make([]*PureLBQuota, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(PureLBQuota)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.Reservations != nil {
+		in, out := &in.Reservations, &out.Reservations
+		*out = make([]*AddressReservation, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(AddressReservation)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
 	return
 }
 
@@ -174,13 +295,253 @@ func (in *LBNodeAgentStatus) DeepCopy() *LBNodeAgentStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PureLBConfig) DeepCopyInto(out *PureLBConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PureLBConfig.
+func (in *PureLBConfig) DeepCopy() *PureLBConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PureLBConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PureLBConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PureLBConfigList) DeepCopyInto(out *PureLBConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PureLBConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PureLBConfigList.
+func (in *PureLBConfigList) DeepCopy() *PureLBConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(PureLBConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PureLBConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PureLBConfigMemberlistSpec) DeepCopyInto(out *PureLBConfigMemberlistSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PureLBConfigMemberlistSpec.
+func (in *PureLBConfigMemberlistSpec) DeepCopy() *PureLBConfigMemberlistSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PureLBConfigMemberlistSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PureLBConfigSpec) DeepCopyInto(out *PureLBConfigSpec) {
+	*out = *in
+	if in.DefaultAnnouncer != nil {
+		in, out := &in.DefaultAnnouncer, &out.DefaultAnnouncer
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Memberlist != nil {
+		in, out := &in.Memberlist, &out.Memberlist
+		*out = new(PureLBConfigMemberlistSpec)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PureLBConfigSpec.
+func (in *PureLBConfigSpec) DeepCopy() *PureLBConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PureLBConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PureLBQuota) DeepCopyInto(out *PureLBQuota) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PureLBQuota.
+func (in *PureLBQuota) DeepCopy() *PureLBQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(PureLBQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PureLBQuota) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PureLBQuotaList) DeepCopyInto(out *PureLBQuotaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PureLBQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PureLBQuotaList.
+func (in *PureLBQuotaList) DeepCopy() *PureLBQuotaList {
+	if in == nil {
+		return nil
+	}
+	out := new(PureLBQuotaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PureLBQuotaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PureLBQuotaSpec) DeepCopyInto(out *PureLBQuotaSpec) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = new(int)
+		**out = **in
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PureLBQuotaSpec.
+func (in *PureLBQuotaSpec) DeepCopy() *PureLBQuotaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PureLBQuotaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PureLBQuotaStatus) DeepCopyInto(out *PureLBQuotaStatus) {
+	*out = *in
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int)
+		**out = **in
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make(map[string]PureLBQuotaUsage, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PureLBQuotaStatus.
+func (in *PureLBQuotaStatus) DeepCopy() *PureLBQuotaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PureLBQuotaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PureLBQuotaUsage) DeepCopyInto(out *PureLBQuotaUsage) {
+	*out = *in
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PureLBQuotaUsage.
+func (in *PureLBQuotaUsage) DeepCopy() *PureLBQuotaUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(PureLBQuotaUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceGroup) DeepCopyInto(out *ServiceGroup) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -218,6 +579,71 @@ func (in *ServiceGroupAddressPool) DeepCopy() *ServiceGroupAddressPool {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceGroupDHCPSpec) DeepCopyInto(out *ServiceGroupDHCPSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceGroupDHCPSpec.
+func (in *ServiceGroupDHCPSpec) DeepCopy() *ServiceGroupDHCPSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceGroupDHCPSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceGroupInfobloxSpec) DeepCopyInto(out *ServiceGroupInfobloxSpec) {
+	*out = *in
+	if in.ExtAttrs != nil {
+		in, out := &in.ExtAttrs, &out.ExtAttrs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceGroupInfobloxSpec.
+func (in *ServiceGroupInfobloxSpec) DeepCopy() *ServiceGroupInfobloxSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceGroupInfobloxSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceGroupLeaseSpec) DeepCopyInto(out *ServiceGroupLeaseSpec) {
+	*out = *in
+	if in.MaxDuration != nil {
+		in, out := &in.MaxDuration, &out.MaxDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.WarningPeriod != nil {
+		in, out := &in.WarningPeriod, &out.WarningPeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceGroupLeaseSpec.
+func (in *ServiceGroupLeaseSpec) DeepCopy() *ServiceGroupLeaseSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceGroupLeaseSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceGroupList) DeepCopyInto(out *ServiceGroupList) {
 	*out = *in
@@ -264,6 +690,31 @@ func (in *ServiceGroupLocalSpec) DeepCopyInto(out *ServiceGroupLocalSpec) {
 		*out = new(ServiceGroupAddressPool)
 		**out = **in
 	}
+	if in.Sharing != nil {
+		in, out := &in.Sharing, &out.Sharing
+		*out = new(ServiceGroupSharingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StaticAssignments != nil {
+		in, out := &in.StaticAssignments, &out.StaticAssignments
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.StickyWindow != nil {
+		in, out := &in.StickyWindow, &out.StickyWindow
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 	return
 }
 
@@ -280,6 +731,11 @@ func (in *ServiceGroupLocalSpec) DeepCopy() *ServiceGroupLocalSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceGroupNetboxSpec) DeepCopyInto(out *ServiceGroupNetboxSpec) {
 	*out = *in
+	if in.PollInterval != nil {
+		in, out := &in.PollInterval, &out.PollInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 	return
 }
 
@@ -293,6 +749,75 @@ func (in *ServiceGroupNetboxSpec) DeepCopy() *ServiceGroupNetboxSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceGroupNodesSpec) DeepCopyInto(out *ServiceGroupNodesSpec) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceGroupNodesSpec.
+func (in *ServiceGroupNodesSpec) DeepCopy() *ServiceGroupNodesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceGroupNodesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceGroupPhpIPAMSpec) DeepCopyInto(out *ServiceGroupPhpIPAMSpec) {
+	*out = *in
+	if in.PollInterval != nil {
+		in, out := &in.PollInterval, &out.PollInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceGroupPhpIPAMSpec.
+func (in *ServiceGroupPhpIPAMSpec) DeepCopy() *ServiceGroupPhpIPAMSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceGroupPhpIPAMSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceGroupSharingSpec) DeepCopyInto(out *ServiceGroupSharingSpec) {
+	*out = *in
+	if in.NamespaceGroups != nil {
+		in, out := &in.NamespaceGroups, &out.NamespaceGroups
+		*out = make([][]string, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceGroupSharingSpec.
+func (in *ServiceGroupSharingSpec) DeepCopy() *ServiceGroupSharingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceGroupSharingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceGroupSpec) DeepCopyInto(out *ServiceGroupSpec) {
 	*out = *in
@@ -304,8 +829,38 @@ func (in *ServiceGroupSpec) DeepCopyInto(out *ServiceGroupSpec) {
 	if in.Netbox != nil {
 		in, out := &in.Netbox, &out.Netbox
 		*out = new(ServiceGroupNetboxSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Infoblox != nil {
+		in, out := &in.Infoblox, &out.Infoblox
+		*out = new(ServiceGroupInfobloxSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PhpIPAM != nil {
+		in, out := &in.PhpIPAM, &out.PhpIPAM
+		*out = new(ServiceGroupPhpIPAMSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DHCP != nil {
+		in, out := &in.DHCP, &out.DHCP
+		*out = new(ServiceGroupDHCPSpec)
 		**out = **in
 	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = new(ServiceGroupNodesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(ServiceGroupWebhookSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Lease != nil {
+		in, out := &in.Lease, &out.Lease
+		*out = new(ServiceGroupLeaseSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -322,6 +877,18 @@ func (in *ServiceGroupSpec) DeepCopy() *ServiceGroupSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceGroupStatus) DeepCopyInto(out *ServiceGroupStatus) {
 	*out = *in
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Sticky != nil {
+		in, out := &in.Sticky, &out.Sticky
+		*out = make([]ServiceGroupStickyAddresses, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -334,3 +901,46 @@ func (in *ServiceGroupStatus) DeepCopy() *ServiceGroupStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceGroupStickyAddresses) DeepCopyInto(out *ServiceGroupStickyAddresses) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Expires.DeepCopyInto(&out.Expires)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceGroupStickyAddresses.
+func (in *ServiceGroupStickyAddresses) DeepCopy() *ServiceGroupStickyAddresses {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceGroupStickyAddresses)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceGroupWebhookSpec) DeepCopyInto(out *ServiceGroupWebhookSpec) {
+	*out = *in
+	if in.PollInterval != nil {
+		in, out := &in.PollInterval, &out.PollInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceGroupWebhookSpec.
+func (in *ServiceGroupWebhookSpec) DeepCopy() *ServiceGroupWebhookSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceGroupWebhookSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+	v1 "purelb.io/pkg/apis/v1"
+	scheme "purelb.io/pkg/generated/clientset/versioned/scheme"
+)
+
+// AddressReservationsGetter has a method to return a AddressReservationInterface.
+// A group's client should implement this interface.
+type AddressReservationsGetter interface {
+	AddressReservations(namespace string) AddressReservationInterface
+}
+
+// AddressReservationInterface has methods to work with AddressReservation resources.
+type AddressReservationInterface interface {
+	Create(ctx context.Context, addressReservation *v1.AddressReservation, opts metav1.CreateOptions) (*v1.AddressReservation, error)
+	Update(ctx context.Context, addressReservation *v1.AddressReservation, opts metav1.UpdateOptions) (*v1.AddressReservation, error)
+	UpdateStatus(ctx context.Context, addressReservation *v1.AddressReservation, opts metav1.UpdateOptions) (*v1.AddressReservation, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.AddressReservation, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.AddressReservationList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.AddressReservation, err error)
+	AddressReservationExpansion
+}
+
+// addressReservations implements AddressReservationInterface
+type addressReservations struct {
+	client rest.Interface
+	ns     string
+}
+
+// newAddressReservations returns a AddressReservations
+func newAddressReservations(c *PurelbV1Client, namespace string) *addressReservations {
+	return &addressReservations{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the addressReservation, and returns the corresponding addressReservation object, and an error if there is any.
+func (c *addressReservations) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.AddressReservation, err error) {
+	result = &v1.AddressReservation{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("addressreservations").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of AddressReservations that match those selectors.
+func (c *addressReservations) List(ctx context.Context, opts metav1.ListOptions) (result *v1.AddressReservationList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.AddressReservationList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("addressreservations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested addressReservations.
+func (c *addressReservations) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("addressreservations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a addressReservation and creates it.  Returns the server's representation of the addressReservation, and an error, if there is any.
+func (c *addressReservations) Create(ctx context.Context, addressReservation *v1.AddressReservation, opts metav1.CreateOptions) (result *v1.AddressReservation, err error) {
+	result = &v1.AddressReservation{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("addressreservations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(addressReservation).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a addressReservation and updates it. Returns the server's representation of the addressReservation, and an error, if there is any.
+func (c *addressReservations) Update(ctx context.Context, addressReservation *v1.AddressReservation, opts metav1.UpdateOptions) (result *v1.AddressReservation, err error) {
+	result = &v1.AddressReservation{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("addressreservations").
+		Name(addressReservation.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(addressReservation).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *addressReservations) UpdateStatus(ctx context.Context, addressReservation *v1.AddressReservation, opts metav1.UpdateOptions) (result *v1.AddressReservation, err error) {
+	result = &v1.AddressReservation{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("addressreservations").
+		Name(addressReservation.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(addressReservation).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the addressReservation and deletes it. Returns an error if one occurs.
+func (c *addressReservations) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("addressreservations").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *addressReservations) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("addressreservations").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched addressReservation.
+func (c *addressReservations) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.AddressReservation, err error) {
+	result = &v1.AddressReservation{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("addressreservations").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -24,7 +24,10 @@ import (
 
 type PurelbV1Interface interface {
 	RESTClient() rest.Interface
+	AddressReservationsGetter
 	LBNodeAgentsGetter
+	PureLBConfigsGetter
+	PureLBQuotasGetter
 	ServiceGroupsGetter
 }
 
@@ -33,10 +36,22 @@ type PurelbV1Client struct {
 	restClient rest.Interface
 }
 
+func (c *PurelbV1Client) AddressReservations(namespace string) AddressReservationInterface {
+	return newAddressReservations(c, namespace)
+}
+
 func (c *PurelbV1Client) LBNodeAgents(namespace string) LBNodeAgentInterface {
 	return newLBNodeAgents(c, namespace)
 }
 
+func (c *PurelbV1Client) PureLBConfigs() PureLBConfigInterface {
+	return newPureLBConfigs(c)
+}
+
+func (c *PurelbV1Client) PureLBQuotas(namespace string) PureLBQuotaInterface {
+	return newPureLBQuotas(c, namespace)
+}
+
 func (c *PurelbV1Client) ServiceGroups(namespace string) ServiceGroupInterface {
 	return newServiceGroups(c, namespace)
 }
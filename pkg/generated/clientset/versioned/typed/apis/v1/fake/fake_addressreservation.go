@@ -0,0 +1,140 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+	apisv1 "purelb.io/pkg/apis/v1"
+)
+
+// FakeAddressReservations implements AddressReservationInterface
+type FakeAddressReservations struct {
+	Fake *FakePurelbV1
+	ns   string
+}
+
+var addressreservationsResource = schema.GroupVersionResource{Group: "purelb.io", Version: "v1", Resource: "addressreservations"}
+
+var addressreservationsKind = schema.GroupVersionKind{Group: "purelb.io", Version: "v1", Kind: "AddressReservation"}
+
+// Get takes name of the addressReservation, and returns the corresponding addressReservation object, and an error if there is any.
+func (c *FakeAddressReservations) Get(ctx context.Context, name string, options v1.GetOptions) (result *apisv1.AddressReservation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(addressreservationsResource, c.ns, name), &apisv1.AddressReservation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.AddressReservation), err
+}
+
+// List takes label and field selectors, and returns the list of AddressReservations that match those selectors.
+func (c *FakeAddressReservations) List(ctx context.Context, opts v1.ListOptions) (result *apisv1.AddressReservationList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(addressreservationsResource, addressreservationsKind, c.ns, opts), &apisv1.AddressReservationList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &apisv1.AddressReservationList{ListMeta: obj.(*apisv1.AddressReservationList).ListMeta}
+	for _, item := range obj.(*apisv1.AddressReservationList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested addressReservations.
+func (c *FakeAddressReservations) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(addressreservationsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a addressReservation and creates it.  Returns the server's representation of the addressReservation, and an error, if there is any.
+func (c *FakeAddressReservations) Create(ctx context.Context, addressReservation *apisv1.AddressReservation, opts v1.CreateOptions) (result *apisv1.AddressReservation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(addressreservationsResource, c.ns, addressReservation), &apisv1.AddressReservation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.AddressReservation), err
+}
+
+// Update takes the representation of a addressReservation and updates it. Returns the server's representation of the addressReservation, and an error, if there is any.
+func (c *FakeAddressReservations) Update(ctx context.Context, addressReservation *apisv1.AddressReservation, opts v1.UpdateOptions) (result *apisv1.AddressReservation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(addressreservationsResource, c.ns, addressReservation), &apisv1.AddressReservation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.AddressReservation), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeAddressReservations) UpdateStatus(ctx context.Context, addressReservation *apisv1.AddressReservation, opts v1.UpdateOptions) (*apisv1.AddressReservation, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(addressreservationsResource, "status", c.ns, addressReservation), &apisv1.AddressReservation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.AddressReservation), err
+}
+
+// Delete takes name of the addressReservation and deletes it. Returns an error if one occurs.
+func (c *FakeAddressReservations) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(addressreservationsResource, c.ns, name), &apisv1.AddressReservation{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeAddressReservations) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(addressreservationsResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &apisv1.AddressReservationList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched addressReservation.
+func (c *FakeAddressReservations) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apisv1.AddressReservation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(addressreservationsResource, c.ns, name, pt, data, subresources...), &apisv1.AddressReservation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.AddressReservation), err
+}
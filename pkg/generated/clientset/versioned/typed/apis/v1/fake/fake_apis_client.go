@@ -26,10 +26,22 @@ type FakePurelbV1 struct {
 	*testing.Fake
 }
 
+func (c *FakePurelbV1) AddressReservations(namespace string) v1.AddressReservationInterface {
+	return &FakeAddressReservations{c, namespace}
+}
+
 func (c *FakePurelbV1) LBNodeAgents(namespace string) v1.LBNodeAgentInterface {
 	return &FakeLBNodeAgents{c, namespace}
 }
 
+func (c *FakePurelbV1) PureLBConfigs() v1.PureLBConfigInterface {
+	return &FakePureLBConfigs{c}
+}
+
+func (c *FakePurelbV1) PureLBQuotas(namespace string) v1.PureLBQuotaInterface {
+	return &FakePureLBQuotas{c, namespace}
+}
+
 func (c *FakePurelbV1) ServiceGroups(namespace string) v1.ServiceGroupInterface {
 	return &FakeServiceGroups{c, namespace}
 }
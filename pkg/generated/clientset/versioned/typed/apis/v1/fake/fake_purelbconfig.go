@@ -0,0 +1,120 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+	apisv1 "purelb.io/pkg/apis/v1"
+)
+
+// FakePureLBConfigs implements PureLBConfigInterface
+type FakePureLBConfigs struct {
+	Fake *FakePurelbV1
+}
+
+var purelbconfigsResource = schema.GroupVersionResource{Group: "purelb.io", Version: "v1", Resource: "purelbconfigs"}
+
+var purelbconfigsKind = schema.GroupVersionKind{Group: "purelb.io", Version: "v1", Kind: "PureLBConfig"}
+
+// Get takes name of the pureLBConfig, and returns the corresponding pureLBConfig object, and an error if there is any.
+func (c *FakePureLBConfigs) Get(ctx context.Context, name string, options v1.GetOptions) (result *apisv1.PureLBConfig, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(purelbconfigsResource, name), &apisv1.PureLBConfig{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.PureLBConfig), err
+}
+
+// List takes label and field selectors, and returns the list of PureLBConfigs that match those selectors.
+func (c *FakePureLBConfigs) List(ctx context.Context, opts v1.ListOptions) (result *apisv1.PureLBConfigList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(purelbconfigsResource, purelbconfigsKind, opts), &apisv1.PureLBConfigList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &apisv1.PureLBConfigList{ListMeta: obj.(*apisv1.PureLBConfigList).ListMeta}
+	for _, item := range obj.(*apisv1.PureLBConfigList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested pureLBConfigs.
+func (c *FakePureLBConfigs) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(purelbconfigsResource, opts))
+}
+
+// Create takes the representation of a pureLBConfig and creates it.  Returns the server's representation of the pureLBConfig, and an error, if there is any.
+func (c *FakePureLBConfigs) Create(ctx context.Context, pureLBConfig *apisv1.PureLBConfig, opts v1.CreateOptions) (result *apisv1.PureLBConfig, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(purelbconfigsResource, pureLBConfig), &apisv1.PureLBConfig{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.PureLBConfig), err
+}
+
+// Update takes the representation of a pureLBConfig and updates it. Returns the server's representation of the pureLBConfig, and an error, if there is any.
+func (c *FakePureLBConfigs) Update(ctx context.Context, pureLBConfig *apisv1.PureLBConfig, opts v1.UpdateOptions) (result *apisv1.PureLBConfig, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(purelbconfigsResource, pureLBConfig), &apisv1.PureLBConfig{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.PureLBConfig), err
+}
+
+// Delete takes name of the pureLBConfig and deletes it. Returns an error if one occurs.
+func (c *FakePureLBConfigs) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(purelbconfigsResource, name), &apisv1.PureLBConfig{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakePureLBConfigs) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(purelbconfigsResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &apisv1.PureLBConfigList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched pureLBConfig.
+func (c *FakePureLBConfigs) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apisv1.PureLBConfig, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(purelbconfigsResource, name, pt, data, subresources...), &apisv1.PureLBConfig{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.PureLBConfig), err
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+	apisv1 "purelb.io/pkg/apis/v1"
+)
+
+// FakePureLBQuotas implements PureLBQuotaInterface
+type FakePureLBQuotas struct {
+	Fake *FakePurelbV1
+	ns   string
+}
+
+var purelbquotasResource = schema.GroupVersionResource{Group: "purelb.io", Version: "v1", Resource: "purelbquotas"}
+
+var purelbquotasKind = schema.GroupVersionKind{Group: "purelb.io", Version: "v1", Kind: "PureLBQuota"}
+
+// Get takes name of the pureLBQuota, and returns the corresponding pureLBQuota object, and an error if there is any.
+func (c *FakePureLBQuotas) Get(ctx context.Context, name string, options v1.GetOptions) (result *apisv1.PureLBQuota, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(purelbquotasResource, c.ns, name), &apisv1.PureLBQuota{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.PureLBQuota), err
+}
+
+// List takes label and field selectors, and returns the list of PureLBQuotas that match those selectors.
+func (c *FakePureLBQuotas) List(ctx context.Context, opts v1.ListOptions) (result *apisv1.PureLBQuotaList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(purelbquotasResource, purelbquotasKind, c.ns, opts), &apisv1.PureLBQuotaList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &apisv1.PureLBQuotaList{ListMeta: obj.(*apisv1.PureLBQuotaList).ListMeta}
+	for _, item := range obj.(*apisv1.PureLBQuotaList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested pureLBQuotas.
+func (c *FakePureLBQuotas) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(purelbquotasResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a pureLBQuota and creates it.  Returns the server's representation of the pureLBQuota, and an error, if there is any.
+func (c *FakePureLBQuotas) Create(ctx context.Context, pureLBQuota *apisv1.PureLBQuota, opts v1.CreateOptions) (result *apisv1.PureLBQuota, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(purelbquotasResource, c.ns, pureLBQuota), &apisv1.PureLBQuota{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.PureLBQuota), err
+}
+
+// Update takes the representation of a pureLBQuota and updates it. Returns the server's representation of the pureLBQuota, and an error, if there is any.
+func (c *FakePureLBQuotas) Update(ctx context.Context, pureLBQuota *apisv1.PureLBQuota, opts v1.UpdateOptions) (result *apisv1.PureLBQuota, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(purelbquotasResource, c.ns, pureLBQuota), &apisv1.PureLBQuota{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.PureLBQuota), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakePureLBQuotas) UpdateStatus(ctx context.Context, pureLBQuota *apisv1.PureLBQuota, opts v1.UpdateOptions) (*apisv1.PureLBQuota, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(purelbquotasResource, "status", c.ns, pureLBQuota), &apisv1.PureLBQuota{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.PureLBQuota), err
+}
+
+// Delete takes name of the pureLBQuota and deletes it. Returns an error if one occurs.
+func (c *FakePureLBQuotas) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(purelbquotasResource, c.ns, name), &apisv1.PureLBQuota{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakePureLBQuotas) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(purelbquotasResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &apisv1.PureLBQuotaList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched pureLBQuota.
+func (c *FakePureLBQuotas) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apisv1.PureLBQuota, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(purelbquotasResource, c.ns, name, pt, data, subresources...), &apisv1.PureLBQuota{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*apisv1.PureLBQuota), err
+}
@@ -16,6 +16,12 @@
 
 package v1
 
+type AddressReservationExpansion interface{}
+
 type LBNodeAgentExpansion interface{}
 
+type PureLBConfigExpansion interface{}
+
+type PureLBQuotaExpansion interface{}
+
 type ServiceGroupExpansion interface{}
@@ -0,0 +1,166 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+	v1 "purelb.io/pkg/apis/v1"
+	scheme "purelb.io/pkg/generated/clientset/versioned/scheme"
+)
+
+// PureLBConfigsGetter has a method to return a PureLBConfigInterface.
+// A group's client should implement this interface.
+type PureLBConfigsGetter interface {
+	PureLBConfigs() PureLBConfigInterface
+}
+
+// PureLBConfigInterface has methods to work with PureLBConfig resources.
+type PureLBConfigInterface interface {
+	Create(ctx context.Context, pureLBConfig *v1.PureLBConfig, opts metav1.CreateOptions) (*v1.PureLBConfig, error)
+	Update(ctx context.Context, pureLBConfig *v1.PureLBConfig, opts metav1.UpdateOptions) (*v1.PureLBConfig, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.PureLBConfig, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.PureLBConfigList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.PureLBConfig, err error)
+	PureLBConfigExpansion
+}
+
+// pureLBConfigs implements PureLBConfigInterface
+type pureLBConfigs struct {
+	client rest.Interface
+}
+
+// newPureLBConfigs returns a PureLBConfigs
+func newPureLBConfigs(c *PurelbV1Client) *pureLBConfigs {
+	return &pureLBConfigs{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the pureLBConfig, and returns the corresponding pureLBConfig object, and an error if there is any.
+func (c *pureLBConfigs) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.PureLBConfig, err error) {
+	result = &v1.PureLBConfig{}
+	err = c.client.Get().
+		Resource("purelbconfigs").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of PureLBConfigs that match those selectors.
+func (c *pureLBConfigs) List(ctx context.Context, opts metav1.ListOptions) (result *v1.PureLBConfigList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.PureLBConfigList{}
+	err = c.client.Get().
+		Resource("purelbconfigs").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested pureLBConfigs.
+func (c *pureLBConfigs) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("purelbconfigs").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a pureLBConfig and creates it.  Returns the server's representation of the pureLBConfig, and an error, if there is any.
+func (c *pureLBConfigs) Create(ctx context.Context, pureLBConfig *v1.PureLBConfig, opts metav1.CreateOptions) (result *v1.PureLBConfig, err error) {
+	result = &v1.PureLBConfig{}
+	err = c.client.Post().
+		Resource("purelbconfigs").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(pureLBConfig).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a pureLBConfig and updates it. Returns the server's representation of the pureLBConfig, and an error, if there is any.
+func (c *pureLBConfigs) Update(ctx context.Context, pureLBConfig *v1.PureLBConfig, opts metav1.UpdateOptions) (result *v1.PureLBConfig, err error) {
+	result = &v1.PureLBConfig{}
+	err = c.client.Put().
+		Resource("purelbconfigs").
+		Name(pureLBConfig.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(pureLBConfig).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the pureLBConfig and deletes it. Returns an error if one occurs.
+func (c *pureLBConfigs) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("purelbconfigs").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *pureLBConfigs) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("purelbconfigs").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched pureLBConfig.
+func (c *pureLBConfigs) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.PureLBConfig, err error) {
+	result = &v1.PureLBConfig{}
+	err = c.client.Patch(pt).
+		Resource("purelbconfigs").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
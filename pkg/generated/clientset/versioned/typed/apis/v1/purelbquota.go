@@ -0,0 +1,193 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+	v1 "purelb.io/pkg/apis/v1"
+	scheme "purelb.io/pkg/generated/clientset/versioned/scheme"
+)
+
+// PureLBQuotasGetter has a method to return a PureLBQuotaInterface.
+// A group's client should implement this interface.
+type PureLBQuotasGetter interface {
+	PureLBQuotas(namespace string) PureLBQuotaInterface
+}
+
+// PureLBQuotaInterface has methods to work with PureLBQuota resources.
+type PureLBQuotaInterface interface {
+	Create(ctx context.Context, pureLBQuota *v1.PureLBQuota, opts metav1.CreateOptions) (*v1.PureLBQuota, error)
+	Update(ctx context.Context, pureLBQuota *v1.PureLBQuota, opts metav1.UpdateOptions) (*v1.PureLBQuota, error)
+	UpdateStatus(ctx context.Context, pureLBQuota *v1.PureLBQuota, opts metav1.UpdateOptions) (*v1.PureLBQuota, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.PureLBQuota, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.PureLBQuotaList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.PureLBQuota, err error)
+	PureLBQuotaExpansion
+}
+
+// pureLBQuotas implements PureLBQuotaInterface
+type pureLBQuotas struct {
+	client rest.Interface
+	ns     string
+}
+
+// newPureLBQuotas returns a PureLBQuotas
+func newPureLBQuotas(c *PurelbV1Client, namespace string) *pureLBQuotas {
+	return &pureLBQuotas{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the pureLBQuota, and returns the corresponding pureLBQuota object, and an error if there is any.
+func (c *pureLBQuotas) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.PureLBQuota, err error) {
+	result = &v1.PureLBQuota{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("purelbquotas").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of PureLBQuotas that match those selectors.
+func (c *pureLBQuotas) List(ctx context.Context, opts metav1.ListOptions) (result *v1.PureLBQuotaList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.PureLBQuotaList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("purelbquotas").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested pureLBQuotas.
+func (c *pureLBQuotas) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("purelbquotas").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a pureLBQuota and creates it.  Returns the server's representation of the pureLBQuota, and an error, if there is any.
+func (c *pureLBQuotas) Create(ctx context.Context, pureLBQuota *v1.PureLBQuota, opts metav1.CreateOptions) (result *v1.PureLBQuota, err error) {
+	result = &v1.PureLBQuota{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("purelbquotas").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(pureLBQuota).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a pureLBQuota and updates it. Returns the server's representation of the pureLBQuota, and an error, if there is any.
+func (c *pureLBQuotas) Update(ctx context.Context, pureLBQuota *v1.PureLBQuota, opts metav1.UpdateOptions) (result *v1.PureLBQuota, err error) {
+	result = &v1.PureLBQuota{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("purelbquotas").
+		Name(pureLBQuota.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(pureLBQuota).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *pureLBQuotas) UpdateStatus(ctx context.Context, pureLBQuota *v1.PureLBQuota, opts metav1.UpdateOptions) (result *v1.PureLBQuota, err error) {
+	result = &v1.PureLBQuota{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("purelbquotas").
+		Name(pureLBQuota.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(pureLBQuota).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the pureLBQuota and deletes it. Returns an error if one occurs.
+func (c *pureLBQuotas) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("purelbquotas").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *pureLBQuotas) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("purelbquotas").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched pureLBQuota.
+func (c *pureLBQuotas) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.PureLBQuota, err error) {
+	result = &v1.PureLBQuota{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("purelbquotas").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	time "time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+	apisv1 "purelb.io/pkg/apis/v1"
+	versioned "purelb.io/pkg/generated/clientset/versioned"
+	internalinterfaces "purelb.io/pkg/generated/informers/externalversions/internalinterfaces"
+	v1 "purelb.io/pkg/generated/listers/apis/v1"
+)
+
+// AddressReservationInformer provides access to a shared informer and lister for
+// AddressReservations.
+type AddressReservationInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1.AddressReservationLister
+}
+
+type addressReservationInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewAddressReservationInformer constructs a new informer for AddressReservation type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewAddressReservationInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredAddressReservationInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredAddressReservationInformer constructs a new informer for AddressReservation type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredAddressReservationInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.PurelbV1().AddressReservations(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.PurelbV1().AddressReservations(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&apisv1.AddressReservation{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *addressReservationInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredAddressReservationInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *addressReservationInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&apisv1.AddressReservation{}, f.defaultInformer)
+}
+
+func (f *addressReservationInformer) Lister() v1.AddressReservationLister {
+	return v1.NewAddressReservationLister(f.Informer().GetIndexer())
+}
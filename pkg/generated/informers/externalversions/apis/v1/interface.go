@@ -22,8 +22,14 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// AddressReservations returns a AddressReservationInformer.
+	AddressReservations() AddressReservationInformer
 	// LBNodeAgents returns a LBNodeAgentInformer.
 	LBNodeAgents() LBNodeAgentInformer
+	// PureLBConfigs returns a PureLBConfigInformer.
+	PureLBConfigs() PureLBConfigInformer
+	// PureLBQuotas returns a PureLBQuotaInformer.
+	PureLBQuotas() PureLBQuotaInformer
 	// ServiceGroups returns a ServiceGroupInformer.
 	ServiceGroups() ServiceGroupInformer
 }
@@ -39,11 +45,26 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// AddressReservations returns a AddressReservationInformer.
+func (v *version) AddressReservations() AddressReservationInformer {
+	return &addressReservationInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // LBNodeAgents returns a LBNodeAgentInformer.
 func (v *version) LBNodeAgents() LBNodeAgentInformer {
 	return &lBNodeAgentInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// PureLBConfigs returns a PureLBConfigInformer.
+func (v *version) PureLBConfigs() PureLBConfigInformer {
+	return &pureLBConfigInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// PureLBQuotas returns a PureLBQuotaInformer.
+func (v *version) PureLBQuotas() PureLBQuotaInformer {
+	return &pureLBQuotaInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // ServiceGroups returns a ServiceGroupInformer.
 func (v *version) ServiceGroups() ServiceGroupInformer {
 	return &serviceGroupInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
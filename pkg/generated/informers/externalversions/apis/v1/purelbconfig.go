@@ -0,0 +1,87 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	time "time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+	apisv1 "purelb.io/pkg/apis/v1"
+	versioned "purelb.io/pkg/generated/clientset/versioned"
+	internalinterfaces "purelb.io/pkg/generated/informers/externalversions/internalinterfaces"
+	v1 "purelb.io/pkg/generated/listers/apis/v1"
+)
+
+// PureLBConfigInformer provides access to a shared informer and lister for
+// PureLBConfigs.
+type PureLBConfigInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1.PureLBConfigLister
+}
+
+type pureLBConfigInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+}
+
+// NewPureLBConfigInformer constructs a new informer for PureLBConfig type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewPureLBConfigInformer(client versioned.Interface, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredPureLBConfigInformer(client, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredPureLBConfigInformer constructs a new informer for PureLBConfig type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredPureLBConfigInformer(client versioned.Interface, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.PurelbV1().PureLBConfigs().List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.PurelbV1().PureLBConfigs().Watch(context.TODO(), options)
+			},
+		},
+		&apisv1.PureLBConfig{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *pureLBConfigInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredPureLBConfigInformer(client, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *pureLBConfigInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&apisv1.PureLBConfig{}, f.defaultInformer)
+}
+
+func (f *pureLBConfigInformer) Lister() v1.PureLBConfigLister {
+	return v1.NewPureLBConfigLister(f.Informer().GetIndexer())
+}
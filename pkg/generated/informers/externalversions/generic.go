@@ -51,8 +51,14 @@ func (f *genericInformer) Lister() cache.GenericLister {
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
 	// Group=purelb.io, Version=v1
+	case v1.SchemeGroupVersion.WithResource("addressreservations"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Purelb().V1().AddressReservations().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("lbnodeagents"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Purelb().V1().LBNodeAgents().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("purelbconfigs"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Purelb().V1().PureLBConfigs().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("purelbquotas"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Purelb().V1().PureLBQuotas().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("servicegroups"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Purelb().V1().ServiceGroups().Informer()}, nil
 
@@ -0,0 +1,97 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	v1 "purelb.io/pkg/apis/v1"
+)
+
+// AddressReservationLister helps list AddressReservations.
+// All objects returned here must be treated as read-only.
+type AddressReservationLister interface {
+	// List lists all AddressReservations in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.AddressReservation, err error)
+	// AddressReservations returns an object that can list and get AddressReservations.
+	AddressReservations(namespace string) AddressReservationNamespaceLister
+	AddressReservationListerExpansion
+}
+
+// addressReservationLister implements the AddressReservationLister interface.
+type addressReservationLister struct {
+	indexer cache.Indexer
+}
+
+// NewAddressReservationLister returns a new AddressReservationLister.
+func NewAddressReservationLister(indexer cache.Indexer) AddressReservationLister {
+	return &addressReservationLister{indexer: indexer}
+}
+
+// List lists all AddressReservations in the indexer.
+func (s *addressReservationLister) List(selector labels.Selector) (ret []*v1.AddressReservation, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.AddressReservation))
+	})
+	return ret, err
+}
+
+// AddressReservations returns an object that can list and get AddressReservations.
+func (s *addressReservationLister) AddressReservations(namespace string) AddressReservationNamespaceLister {
+	return addressReservationNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// AddressReservationNamespaceLister helps list and get AddressReservations.
+// All objects returned here must be treated as read-only.
+type AddressReservationNamespaceLister interface {
+	// List lists all AddressReservations in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.AddressReservation, err error)
+	// Get retrieves the AddressReservation from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1.AddressReservation, error)
+	AddressReservationNamespaceListerExpansion
+}
+
+// addressReservationNamespaceLister implements the AddressReservationNamespaceLister
+// interface.
+type addressReservationNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all AddressReservations in the indexer for a given namespace.
+func (s addressReservationNamespaceLister) List(selector labels.Selector) (ret []*v1.AddressReservation, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.AddressReservation))
+	})
+	return ret, err
+}
+
+// Get retrieves the AddressReservation from the indexer for a given namespace and name.
+func (s addressReservationNamespaceLister) Get(name string) (*v1.AddressReservation, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1.Resource("addressreservation"), name)
+	}
+	return obj.(*v1.AddressReservation), nil
+}
@@ -16,6 +16,14 @@
 
 package v1
 
+// AddressReservationListerExpansion allows custom methods to be added to
+// AddressReservationLister.
+type AddressReservationListerExpansion interface{}
+
+// AddressReservationNamespaceListerExpansion allows custom methods to be added to
+// AddressReservationNamespaceLister.
+type AddressReservationNamespaceListerExpansion interface{}
+
 // LBNodeAgentListerExpansion allows custom methods to be added to
 // LBNodeAgentLister.
 type LBNodeAgentListerExpansion interface{}
@@ -24,6 +32,18 @@ type LBNodeAgentListerExpansion interface{}
 // LBNodeAgentNamespaceLister.
 type LBNodeAgentNamespaceListerExpansion interface{}
 
+// PureLBConfigListerExpansion allows custom methods to be added to
+// PureLBConfigLister.
+type PureLBConfigListerExpansion interface{}
+
+// PureLBQuotaListerExpansion allows custom methods to be added to
+// PureLBQuotaLister.
+type PureLBQuotaListerExpansion interface{}
+
+// PureLBQuotaNamespaceListerExpansion allows custom methods to be added to
+// PureLBQuotaNamespaceLister.
+type PureLBQuotaNamespaceListerExpansion interface{}
+
 // ServiceGroupListerExpansion allows custom methods to be added to
 // ServiceGroupLister.
 type ServiceGroupListerExpansion interface{}
@@ -0,0 +1,66 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	v1 "purelb.io/pkg/apis/v1"
+)
+
+// PureLBConfigLister helps list PureLBConfigs.
+// All objects returned here must be treated as read-only.
+type PureLBConfigLister interface {
+	// List lists all PureLBConfigs in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.PureLBConfig, err error)
+	// Get retrieves the PureLBConfig from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1.PureLBConfig, error)
+	PureLBConfigListerExpansion
+}
+
+// pureLBConfigLister implements the PureLBConfigLister interface.
+type pureLBConfigLister struct {
+	indexer cache.Indexer
+}
+
+// NewPureLBConfigLister returns a new PureLBConfigLister.
+func NewPureLBConfigLister(indexer cache.Indexer) PureLBConfigLister {
+	return &pureLBConfigLister{indexer: indexer}
+}
+
+// List lists all PureLBConfigs in the indexer.
+func (s *pureLBConfigLister) List(selector labels.Selector) (ret []*v1.PureLBConfig, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.PureLBConfig))
+	})
+	return ret, err
+}
+
+// Get retrieves the PureLBConfig from the index for a given name.
+func (s *pureLBConfigLister) Get(name string) (*v1.PureLBConfig, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1.Resource("purelbconfig"), name)
+	}
+	return obj.(*v1.PureLBConfig), nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Acnodal, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	v1 "purelb.io/pkg/apis/v1"
+)
+
+// PureLBQuotaLister helps list PureLBQuotas.
+// All objects returned here must be treated as read-only.
+type PureLBQuotaLister interface {
+	// List lists all PureLBQuotas in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.PureLBQuota, err error)
+	// PureLBQuotas returns an object that can list and get PureLBQuotas.
+	PureLBQuotas(namespace string) PureLBQuotaNamespaceLister
+	PureLBQuotaListerExpansion
+}
+
+// pureLBQuotaLister implements the PureLBQuotaLister interface.
+type pureLBQuotaLister struct {
+	indexer cache.Indexer
+}
+
+// NewPureLBQuotaLister returns a new PureLBQuotaLister.
+func NewPureLBQuotaLister(indexer cache.Indexer) PureLBQuotaLister {
+	return &pureLBQuotaLister{indexer: indexer}
+}
+
+// List lists all PureLBQuotas in the indexer.
+func (s *pureLBQuotaLister) List(selector labels.Selector) (ret []*v1.PureLBQuota, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.PureLBQuota))
+	})
+	return ret, err
+}
+
+// PureLBQuotas returns an object that can list and get PureLBQuotas.
+func (s *pureLBQuotaLister) PureLBQuotas(namespace string) PureLBQuotaNamespaceLister {
+	return pureLBQuotaNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// PureLBQuotaNamespaceLister helps list and get PureLBQuotas.
+// All objects returned here must be treated as read-only.
+type PureLBQuotaNamespaceLister interface {
+	// List lists all PureLBQuotas in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.PureLBQuota, err error)
+	// Get retrieves the PureLBQuota from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1.PureLBQuota, error)
+	PureLBQuotaNamespaceListerExpansion
+}
+
+// pureLBQuotaNamespaceLister implements the PureLBQuotaNamespaceLister
+// interface.
+type pureLBQuotaNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all PureLBQuotas in the indexer for a given namespace.
+func (s pureLBQuotaNamespaceLister) List(selector labels.Selector) (ret []*v1.PureLBQuota, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.PureLBQuota))
+	})
+	return ret, err
+}
+
+// Get retrieves the PureLBQuota from the indexer for a given namespace and name.
+func (s pureLBQuotaNamespaceLister) Get(name string) (*v1.PureLBQuota, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1.Resource("purelbquota"), name)
+	}
+	return obj.(*v1.PureLBQuota), nil
+}